@@ -18,22 +18,84 @@ type cData[T any] struct {
 // AnyCache 泛型结构缓存
 type AnyCache[T any] struct {
 	cache        *mapfx.StructMap[string, cData[T]]
+	bound        *bounder
+	sizeFunc     func(key string, value T) int64
+	evictFunc    func(map[string]T, EvictReason)
+	flight       *flightGroup[T]
+	errs         *mapfx.StructMap[string, cErr]
+	errExpire    time.Duration
+	refreshAhead time.Duration
+	stats        counters
 	cacheCleanup *time.Ticker
 	cacheExpire  time.Duration
 	closed       atomic.Bool
 	closeChan    chan bool
 }
 
+// Opt 缓存配置
+type Opt[T any] struct {
+	// 缓存有效期
+	Expire time.Duration
+	// 最大缓存数量，0表示不限制
+	MaxEntries int
+	// 近似内存预算，单位字节，需要同时设置SizeFunc，0表示不限制
+	MaxBytes int64
+	// 计算单个缓存内容的大小，单位字节
+	SizeFunc func(key string, value T) int64
+	// 超出容量限制时的淘汰策略，默认LRU
+	Policy EvictPolicy
+	// 缓存过期或被淘汰时执行
+	ExpireFunc func(map[string]T)
+	// 缓存过期或被淘汰时执行，可获取移除原因，设置后ExpireFunc不再执行
+	EvictFunc func(map[string]T, EvictReason)
+	// GetOrLoad 加载失败时，错误的缓存时间，0表示不缓存错误
+	ErrorExpire time.Duration
+	// GetOrLoad 命中的缓存剩余有效期小于该值时，在后台重新加载，0表示不提前加载
+	RefreshAhead time.Duration
+}
+
 // NewAnyCacheWithExpireFunc 初始化一个新的缓存,在缓存过期时，会执行expireFunc函数
 //
 //	 这个新缓存会创建一个线程检查内容是否过期，因此，当不再使用该缓存时，应该调用Close()方法关闭缓存
 //		默认每分钟清理一次过期缓存
 func NewAnyCacheWithExpireFunc[T any](expire time.Duration, expireFunc func(map[string]T)) *AnyCache[T] {
+	return NewAnyCacheWithOption(&Opt[T]{
+		Expire:     expire,
+		ExpireFunc: expireFunc,
+	})
+}
+
+// NewAnyCacheWithOption 依据配置初始化一个新的缓存
+//
+//	 设置了MaxEntries或MaxBytes时，缓存超出限制后会按Policy淘汰内容，被淘汰的内容和过期内容一样交给EvictFunc或ExpireFunc处理
+//	 这个新缓存会创建一个线程检查内容是否过期，因此，当不再使用该缓存时，应该调用Close()方法关闭缓存
+//		默认每分钟清理一次过期缓存
+func NewAnyCacheWithOption[T any](opt *Opt[T]) *AnyCache[T] {
+	if opt == nil {
+		opt = &Opt[T]{}
+	}
 	x := &AnyCache[T]{
-		cacheExpire:  expire,
+		cacheExpire:  opt.Expire,
 		cache:        mapfx.NewStructMap[string, cData[T]](),
 		cacheCleanup: time.NewTicker(time.Second * 60),
 		closeChan:    make(chan bool, 1),
+		evictFunc:    opt.EvictFunc,
+		flight:       &flightGroup[T]{},
+		errs:         mapfx.NewStructMap[string, cErr](),
+		errExpire:    opt.ErrorExpire,
+		refreshAhead: opt.RefreshAhead,
+	}
+	if x.evictFunc == nil && opt.ExpireFunc != nil {
+		x.evictFunc = func(m map[string]T, _ EvictReason) {
+			opt.ExpireFunc(m)
+		}
+	}
+	if opt.SizeFunc == nil {
+		opt.MaxBytes = 0
+	}
+	if opt.MaxEntries > 0 || opt.MaxBytes > 0 {
+		x.bound = newBounder(opt.Policy, opt.MaxEntries, opt.MaxBytes)
+		x.sizeFunc = opt.SizeFunc
 	}
 	x.closed.Store(false)
 	go loopfunc.LoopFunc(func(params ...interface{}) {
@@ -43,6 +105,7 @@ func NewAnyCacheWithExpireFunc[T any](expire time.Duration, expireFunc func(map[
 				return
 			case <-x.cacheCleanup.C:
 				tnow := time.Now()
+				x.cleanErrs(tnow)
 				keys := make([]string, 0, x.cache.Len())
 				ex := make(map[string]T)
 				for k, v := range x.cache.Clone() {
@@ -52,12 +115,16 @@ func NewAnyCacheWithExpireFunc[T any](expire time.Duration, expireFunc func(map[
 					}
 				}
 				if len(keys) > 0 {
-					x.cache.DeleteMore(keys...)
-					if expireFunc != nil {
-						loopfunc.GoFunc(func(params ...interface{}) {
-							expireFunc(ex)
-						}, "expire func", logger.NewConsoleWriter())
+					if x.bound != nil {
+						x.bound.Lock()
+						x.cache.DeleteMore(keys...)
+						x.bound.remove(keys...)
+						x.bound.Unlock()
+					} else {
+						x.cache.DeleteMore(keys...)
 					}
+					x.stats.expirations.Add(uint64(len(keys)))
+					x.evicted(ex, ReasonExpired)
 				}
 			}
 		}
@@ -95,7 +162,13 @@ func (ac *AnyCache[T]) Clean() {
 	if ac.closed.Load() {
 		return
 	}
+	if ac.bound != nil {
+		ac.bound.Lock()
+		defer ac.bound.Unlock()
+		ac.bound.reset()
+	}
 	ac.cache.Clean()
+	ac.errs.Clean()
 }
 
 // Len 返回缓存内容数量
@@ -108,8 +181,12 @@ func (ac *AnyCache[T]) Len() int {
 
 // Extension 将指定缓存延期
 func (ac *AnyCache[T]) Extension(key string) {
-	if x, ok := ac.cache.LoadForUpdate(key); ok {
-		x.expire = time.Now().Add(ac.cacheExpire)
+	if x, ok := ac.cache.Load(key); ok {
+		// 整体替换，不修改map内的值
+		ac.cache.Store(key, &cData[T]{
+			expire: time.Now().Add(ac.cacheExpire),
+			data:   x.data,
+		})
 	}
 }
 
@@ -123,15 +200,16 @@ func (ac *AnyCache[T]) StoreWithExpire(key string, value T, expire time.Duration
 	if ac.closed.Load() {
 		return fmt.Errorf("cache is closed")
 	}
-	if v, ok := ac.cache.LoadForUpdate(key); ok {
-		v.expire = time.Now().Add(expire)
-		v.data = value
-	} else {
-		ac.cache.Store(key, &cData[T]{
-			expire: time.Now().Add(expire),
-			data:   value,
-		})
+	ac.stats.stores.Add(1)
+	if ac.bound != nil {
+		ac.storeBounded(key, value, expire)
+		return nil
 	}
+	// 整体替换，避免修改map内的值时产生竞争
+	ac.cache.Store(key, &cData[T]{
+		expire: time.Now().Add(expire),
+		data:   value,
+	})
 	return nil
 }
 
@@ -143,12 +221,20 @@ func (ac *AnyCache[T]) Load(key string) (T, bool) {
 	}
 	v, ok := ac.cache.Load(key)
 	if !ok {
+		ac.stats.hit(false)
 		return *x, false
 	}
 	if time.Now().After(v.expire) {
 		// ac.cache.Delete(key) // 删除会有锁操作，因此还是放在清理方法里一次性做
+		ac.stats.hit(false)
 		return *x, false
 	}
+	ac.stats.hit(true)
+	if ac.bound != nil {
+		ac.bound.Lock()
+		ac.bound.touch(key)
+		ac.bound.Unlock()
+	}
 	return v.data, true
 }
 
@@ -163,10 +249,7 @@ func (ac *AnyCache[T]) LoadOrStore(key string, value T) (T, bool) {
 	}
 	v, ok := ac.Load(key)
 	if !ok {
-		ac.cache.Store(key, &cData[T]{
-			expire: time.Now().Add(ac.cacheExpire),
-			data:   value,
-		})
+		ac.StoreWithExpire(key, value, ac.cacheExpire)
 		return value, false
 	}
 	return v, true
@@ -177,7 +260,29 @@ func (ac *AnyCache[T]) Delete(key string) {
 	if ac.closed.Load() {
 		return
 	}
+	if ac.bound != nil {
+		ac.bound.Lock()
+		defer ac.bound.Unlock()
+		ac.bound.remove(key)
+	}
 	ac.cache.Delete(key)
+	ac.errs.Delete(key)
+}
+
+// Stats 返回缓存统计信息快照
+func (ac *AnyCache[T]) Stats() Stats {
+	return Stats{
+		Hits:        ac.stats.hits.Load(),
+		Misses:      ac.stats.misses.Load(),
+		Stores:      ac.stats.stores.Load(),
+		Expirations: ac.stats.expirations.Load(),
+		Evictions:   ac.stats.evictions.Load(),
+		Loads:       ac.stats.loads.Load(),
+		LoadErrors:  ac.stats.loadErrors.Load(),
+		ErrorHits:   ac.stats.errorHits.Load(),
+		LoadTime:    time.Duration(ac.stats.loadTime.Load()),
+		Len:         ac.Len(),
+	}
 }
 
 // ForEach 遍历所有缓存内容
@@ -189,3 +294,46 @@ func (ac *AnyCache[T]) ForEach(f func(key string, value T) bool) {
 		return f(key, value.data)
 	})
 }
+
+// storeBounded 在有容量限制时添加缓存内容，并淘汰超出限制的内容
+func (ac *AnyCache[T]) storeBounded(key string, value T, expire time.Duration) {
+	var size int64
+	if ac.sizeFunc != nil {
+		size = ac.sizeFunc(key, value)
+	}
+	ac.bound.Lock()
+	ac.cache.Store(key, &cData[T]{
+		expire: time.Now().Add(expire),
+		data:   value,
+	})
+	keys, reasons := ac.bound.put(key, size)
+	if len(keys) == 0 {
+		ac.bound.Unlock()
+		return
+	}
+	ex := make(map[EvictReason]map[string]T)
+	for i, k := range keys {
+		if v, ok := ac.cache.LoadForUpdate(k); ok {
+			if _, ok := ex[reasons[i]]; !ok {
+				ex[reasons[i]] = make(map[string]T)
+			}
+			ex[reasons[i]][k] = v.data
+		}
+	}
+	ac.cache.DeleteMore(keys...)
+	ac.bound.Unlock()
+	ac.stats.evictions.Add(uint64(len(keys)))
+	for reason, m := range ex {
+		ac.evicted(m, reason)
+	}
+}
+
+// evicted 将过期或被淘汰的内容交给回调函数处理
+func (ac *AnyCache[T]) evicted(ex map[string]T, reason EvictReason) {
+	if ac.evictFunc == nil || len(ex) == 0 {
+		return
+	}
+	loopfunc.GoFunc(func(params ...interface{}) {
+		ac.evictFunc(ex, reason)
+	}, "expire func", logger.NewConsoleWriter())
+}
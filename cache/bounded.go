@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// EvictPolicy 缓存超出容量时的淘汰策略
+type EvictPolicy byte
+
+const (
+	// EvictLRU 淘汰最久未使用的缓存
+	EvictLRU EvictPolicy = iota
+	// EvictLFU 淘汰使用次数最少的缓存，次数相同时淘汰最久未使用的
+	EvictLFU
+)
+
+// EvictReason 缓存被移除的原因
+type EvictReason byte
+
+const (
+	// ReasonExpired 缓存过期
+	ReasonExpired EvictReason = iota
+	// ReasonCapacity 超出缓存数量上限
+	ReasonCapacity
+	// ReasonMemory 超出内存预算
+	ReasonMemory
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonMemory:
+		return "memory"
+	default:
+		return "unknown"
+	}
+}
+
+// evictor 记录缓存的使用顺序，用于选出淘汰对象
+type evictor interface {
+	add(key string)
+	touch(key string)
+	remove(key string)
+	victim() (string, bool)
+	reset()
+}
+
+// bounder 有容量限制的缓存的淘汰管理
+type bounder struct {
+	sync.Mutex
+	policy     evictor
+	sizes      map[string]int64
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+}
+
+func newBounder(policy EvictPolicy, maxEntries int, maxBytes int64) *bounder {
+	b := &bounder{
+		sizes:      make(map[string]int64),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+	switch policy {
+	case EvictLFU:
+		b.policy = newLFU()
+	default:
+		b.policy = newLRU()
+	}
+	return b
+}
+
+// put 记录一个新增或更新的缓存，返回需要淘汰的key及原因，调用前需加锁
+//
+//	新的key会先淘汰其他缓存再加入，因此不会被立即淘汰，除非其自身大小就超出了内存预算
+func (b *bounder) put(key string, size int64) ([]string, []EvictReason) {
+	keys := make([]string, 0)
+	reasons := make([]EvictReason, 0)
+	evict := func(count int, bytes int64) {
+		for {
+			var reason EvictReason
+			switch {
+			case b.maxEntries > 0 && count > b.maxEntries:
+				reason = ReasonCapacity
+			case b.maxBytes > 0 && bytes > b.maxBytes:
+				reason = ReasonMemory
+			default:
+				return
+			}
+			k, ok := b.policy.victim()
+			if !ok {
+				return
+			}
+			count--
+			bytes -= b.sizes[k]
+			b.remove(k)
+			keys = append(keys, k)
+			reasons = append(reasons, reason)
+		}
+	}
+	if old, ok := b.sizes[key]; ok {
+		b.bytes += size - old
+		b.sizes[key] = size
+		b.policy.touch(key)
+		evict(len(b.sizes), b.bytes)
+		return keys, reasons
+	}
+	if b.maxBytes > 0 && size > b.maxBytes {
+		return append(keys, key), append(reasons, ReasonMemory)
+	}
+	evict(len(b.sizes)+1, b.bytes+size)
+	b.sizes[key] = size
+	b.bytes += size
+	b.policy.add(key)
+	return keys, reasons
+}
+
+// touch 记录一次缓存访问，调用前需加锁
+func (b *bounder) touch(key string) {
+	if _, ok := b.sizes[key]; ok {
+		b.policy.touch(key)
+	}
+}
+
+// remove 移除缓存记录，调用前需加锁
+func (b *bounder) remove(keys ...string) {
+	for _, key := range keys {
+		if size, ok := b.sizes[key]; ok {
+			b.bytes -= size
+			delete(b.sizes, key)
+			b.policy.remove(key)
+		}
+	}
+}
+
+// reset 清空所有记录，调用前需加锁
+func (b *bounder) reset() {
+	b.sizes = make(map[string]int64)
+	b.bytes = 0
+	b.policy.reset()
+}
+
+// lru 最近最少使用，链表头部为最近使用的缓存
+type lru struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) add(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.ll.PushFront(key)
+}
+
+func (l *lru) touch(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+func (l *lru) victim() (string, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+func (l *lru) reset() {
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+type lfuItem struct {
+	key  string
+	freq int
+}
+
+// lfu 最不经常使用，按访问次数分组，每组内按lru排序，所有操作均为O(1)
+type lfu struct {
+	items   map[string]*list.Element
+	freqs   map[int]*list.List
+	minFreq int
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		items: make(map[string]*list.Element),
+		freqs: make(map[int]*list.List),
+	}
+}
+
+func (l *lfu) bucket(freq int) *list.List {
+	ll, ok := l.freqs[freq]
+	if !ok {
+		ll = list.New()
+		l.freqs[freq] = ll
+	}
+	return ll
+}
+
+func (l *lfu) add(key string) {
+	if _, ok := l.items[key]; ok {
+		l.touch(key)
+		return
+	}
+	l.items[key] = l.bucket(1).PushFront(&lfuItem{key: key, freq: 1})
+	l.minFreq = 1
+}
+
+func (l *lfu) touch(key string) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+	it := e.Value.(*lfuItem)
+	ll := l.freqs[it.freq]
+	ll.Remove(e)
+	if ll.Len() == 0 {
+		delete(l.freqs, it.freq)
+		if l.minFreq == it.freq {
+			l.minFreq++
+		}
+	}
+	it.freq++
+	l.items[key] = l.bucket(it.freq).PushFront(it)
+}
+
+func (l *lfu) remove(key string) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+	it := e.Value.(*lfuItem)
+	ll := l.freqs[it.freq]
+	ll.Remove(e)
+	if ll.Len() == 0 {
+		delete(l.freqs, it.freq)
+	}
+	delete(l.items, key)
+}
+
+func (l *lfu) victim() (string, bool) {
+	if len(l.items) == 0 {
+		return "", false
+	}
+	ll, ok := l.freqs[l.minFreq]
+	if !ok {
+		// minFreq 所在分组已被remove清空，重新查找
+		l.minFreq = 0
+		for f := range l.freqs {
+			if l.minFreq == 0 || f < l.minFreq {
+				l.minFreq = f
+			}
+		}
+		ll = l.freqs[l.minFreq]
+	}
+	return ll.Back().Value.(*lfuItem).key, true
+}
+
+func (l *lfu) reset() {
+	l.items = make(map[string]*list.Element)
+	l.freqs = make(map[int]*list.List)
+	l.minFreq = 0
+}
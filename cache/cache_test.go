@@ -1,9 +1,15 @@
 package cache
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/xyzj/gopsu/logger"
 )
 
 type bbb struct {
@@ -24,3 +30,330 @@ func BenchmarkCache(t *testing.B) {
 	// 	a.Load(strconv.Itoa(i + 1))
 	// }
 }
+
+func BenchmarkShardedCache(t *testing.B) {
+	a := NewShardedCache[*bbb](time.Hour, 0)
+	t.ResetTimer()
+	for i := 0; i < 1000000; i++ {
+		a.Store(strconv.Itoa(i+1), &bbb{BBB: "string"})
+	}
+}
+
+func benchmarkParallel(b *testing.B, c Cache[*bbb]) {
+	for i := 0; i < 10000; i++ {
+		c.Store(strconv.Itoa(i), &bbb{BBB: "string"})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := strconv.Itoa(i % 10000)
+			if i%4 == 0 {
+				c.Store(k, &bbb{BBB: "string"})
+			} else {
+				c.Load(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheParallel(b *testing.B) {
+	a := NewAnyCache[*bbb](time.Hour)
+	defer a.Close()
+	benchmarkParallel(b, a)
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	a := NewShardedCache[*bbb](time.Hour, 0)
+	defer a.Close()
+	benchmarkParallel(b, a)
+}
+
+func TestBoundedCache(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		evicted := make(chan EvictReason, 10)
+		a := NewAnyCacheWithOption(&Opt[int]{
+			Expire:     time.Hour,
+			MaxEntries: 2,
+			EvictFunc: func(m map[string]int, reason EvictReason) {
+				for range m {
+					evicted <- reason
+				}
+			},
+		})
+		defer a.Close()
+		a.Store("a", 1)
+		a.Store("b", 2)
+		a.Load("a")
+		a.Store("c", 3)
+		if _, ok := a.Load("b"); ok {
+			t.Fatal("b should be evicted")
+		}
+		if _, ok := a.Load("a"); !ok {
+			t.Fatal("a should be kept")
+		}
+		if r := <-evicted; r != ReasonCapacity {
+			t.Fatalf("unexpected reason %s", r)
+		}
+	})
+	t.Run("lfu", func(t *testing.T) {
+		a := NewAnyCacheWithOption(&Opt[int]{
+			Expire:     time.Hour,
+			MaxEntries: 2,
+			Policy:     EvictLFU,
+		})
+		defer a.Close()
+		a.Store("a", 1)
+		a.Store("b", 2)
+		a.Load("a")
+		a.Load("a")
+		a.Load("b")
+		a.Store("c", 3)
+		if _, ok := a.Load("b"); ok {
+			t.Fatal("b should be evicted")
+		}
+		if a.Len() != 2 {
+			t.Fatalf("len should be 2, got %d", a.Len())
+		}
+	})
+	t.Run("memory", func(t *testing.T) {
+		a := NewAnyCacheWithOption(&Opt[string]{
+			Expire:   time.Hour,
+			MaxBytes: 10,
+			SizeFunc: func(key string, value string) int64 {
+				return int64(len(value))
+			},
+		})
+		defer a.Close()
+		a.Store("a", "12345")
+		a.Store("b", "12345")
+		a.Store("c", "123")
+		if _, ok := a.Load("a"); ok {
+			t.Fatal("a should be evicted")
+		}
+		a.Store("d", "12345678901")
+		if _, ok := a.Load("d"); ok {
+			t.Fatal("d is larger than the budget")
+		}
+		if a.Len() != 2 {
+			t.Fatalf("len should be 2, got %d", a.Len())
+		}
+	})
+}
+
+func TestDiskCache(t *testing.T) {
+	fn := t.TempDir() + "/cache.db"
+	a, err := NewDiskCache[*bbb](fn, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Store("a", &bbb{BBB: "aaa"})
+	a.StoreWithExpire("b", &bbb{BBB: "bbb"}, time.Millisecond*100)
+	a.Close()
+	time.Sleep(time.Millisecond * 200)
+
+	a, err = NewDiskCache[*bbb](fn, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	v, ok := a.Load("a")
+	if !ok || v.BBB != "aaa" {
+		t.Fatal("a should be reloaded")
+	}
+	if _, ok := a.Load("b"); ok {
+		t.Fatal("b should be expired")
+	}
+	if a.Len() != 1 {
+		t.Fatalf("len should be 1, got %d", a.Len())
+	}
+}
+
+type errLogger struct {
+	logger.NilLogger
+	locker sync.Mutex
+	msgs   []string
+}
+
+func (l *errLogger) Error(msg string) {
+	l.locker.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.locker.Unlock()
+}
+
+func TestDiskCacheExpire(t *testing.T) {
+	fn := t.TempDir() + "/cache.db"
+	a, err := NewDiskCache[int](fn, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	// 内存中过期后又重新写入文件的内容不删除
+	a.write("new", 1, time.Hour)
+	a.write("old", 2, -time.Second)
+	if err = a.removeExpired("new", "old"); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	a, err = NewDiskCache[int](fn, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, ok := a.Load("new"); !ok || a.Len() != 1 {
+		t.Fatal("new entry should be kept")
+	}
+
+	// 无法返回的文件错误记录日志
+	l := &errLogger{}
+	a.SetLogger(l)
+	a.db.Close()
+	a.Delete("new")
+	a.LoadOrStore("x", 1)
+	a.Extension("x")
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if len(l.msgs) != 3 || !strings.Contains(l.msgs[0], "delete new") {
+		t.Fatalf("logged %v", l.msgs)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	t.Run("singleflight", func(t *testing.T) {
+		a := NewAnyCache[int](time.Hour)
+		defer a.Close()
+		var calls atomic.Int32
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := a.GetOrLoad("a", func() (int, error) {
+					calls.Add(1)
+					time.Sleep(time.Millisecond * 50)
+					return 7, nil
+				})
+				if err != nil || v != 7 {
+					t.Error("unexpected load result")
+				}
+			}()
+		}
+		wg.Wait()
+		if calls.Load() != 1 {
+			t.Fatalf("loader should be called once, got %d", calls.Load())
+		}
+	})
+	t.Run("negative", func(t *testing.T) {
+		a := NewAnyCacheWithOption(&Opt[int]{Expire: time.Hour, ErrorExpire: time.Millisecond * 100})
+		defer a.Close()
+		var calls atomic.Int32
+		loader := func() (int, error) {
+			calls.Add(1)
+			return 0, errors.New("not found")
+		}
+		a.GetOrLoad("a", loader)
+		if _, err := a.GetOrLoad("a", loader); err == nil {
+			t.Fatal("error should be cached")
+		}
+		if calls.Load() != 1 {
+			t.Fatalf("loader should be called once, got %d", calls.Load())
+		}
+		if st := a.Stats(); st.Hits != 0 || st.Misses != 2 || st.ErrorHits != 1 {
+			t.Fatalf("cached error should not be a hit %+v", st)
+		}
+		time.Sleep(time.Millisecond * 150)
+		a.GetOrLoad("a", loader)
+		if calls.Load() != 2 {
+			t.Fatalf("loader should be called again, got %d", calls.Load())
+		}
+	})
+	t.Run("refresh", func(t *testing.T) {
+		a := NewAnyCacheWithOption(&Opt[int]{Expire: time.Millisecond * 200, RefreshAhead: time.Millisecond * 150})
+		defer a.Close()
+		var calls atomic.Int32
+		loader := func() (int, error) {
+			return int(calls.Add(1)), nil
+		}
+		a.GetOrLoad("a", loader)
+		time.Sleep(time.Millisecond * 100)
+		if v, _ := a.GetOrLoad("a", loader); v != 1 {
+			t.Fatalf("stale value should be returned, got %d", v)
+		}
+		time.Sleep(time.Millisecond * 50)
+		if v, _ := a.GetOrLoad("a", loader); v != 2 {
+			t.Fatalf("value should be refreshed, got %d", v)
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	a := NewAnyCacheWithOption(&Opt[int]{Expire: time.Hour, MaxEntries: 1})
+	defer a.Close()
+	a.Store("a", 1)
+	a.Load("a")
+	a.Load("b")
+	a.Store("b", 2)
+	a.GetOrLoad("c", func() (int, error) { return 3, nil })
+	st := a.Stats()
+	if st.Hits != 1 || st.Misses != 2 || st.Stores != 3 || st.Evictions != 2 || st.Loads != 1 || st.Len != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	s := st.Prometheus("test")
+	if !strings.Contains(s, `gopsu_cache_hits_total{cache="test"} 1`) ||
+		!strings.Contains(s, "# TYPE gopsu_cache_entries gauge") {
+		t.Fatal(s)
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	expired := make(chan map[string]int, 1)
+	a := NewShardedCacheWithExpireFunc(time.Hour, 4, func(m map[string]int) {
+		expired <- m
+	})
+	defer a.Close()
+	a.SetCleanUp(time.Second)
+	a.Store("a", 1)
+	a.StoreWithExpire("b", 2, time.Millisecond*10)
+	if v, ok := a.LoadOrStore("a", 3); !ok || v != 1 {
+		t.Fatal("a should exist")
+	}
+	select {
+	case m := <-expired:
+		if _, ok := m["b"]; !ok || len(m) != 1 {
+			t.Fatalf("unexpected expired items %v", m)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("b should expire")
+	}
+	if a.Len() != 1 {
+		t.Fatalf("len should be 1, got %d", a.Len())
+	}
+}
+
+func TestSyncCache(t *testing.T) {
+	bus := NewMemoryBus()
+	a := NewSyncCache[int](NewAnyCache[int](time.Hour), bus)
+	defer a.Close()
+	b := NewSyncCache[int](NewShardedCache[int](time.Hour, 0), bus)
+	defer b.Close()
+	a.Store("a", 1)
+	b.Store("a", 2)
+	if _, ok := a.Load("a"); ok {
+		t.Fatal("a should be invalidated")
+	}
+	if v, ok := b.Load("a"); !ok || v != 2 {
+		t.Fatal("b should keep its own value")
+	}
+	if s := a.Stats(); s.Stores != 1 || s.Misses != 1 {
+		t.Fatalf("a stats %+v", s)
+	}
+	if s := b.Stats(); s.Hits != 1 || s.Len != 1 {
+		t.Fatalf("b stats %+v", s)
+	}
+	a.Store("c", 3)
+	a.Clean()
+	if b.Len() != 0 {
+		t.Fatal("b should be cleaned")
+	}
+}
@@ -0,0 +1,305 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/xyzj/gopsu/json"
+	"github.com/xyzj/gopsu/logger"
+	"go.etcd.io/bbolt"
+)
+
+var diskBucket = []byte("cache")
+
+// DiskCache 可持久化的泛型结构缓存
+//
+//	缓存内容同时保存在内存和bolt数据文件中，重启后会自动载入未过期的内容，过期内容会从文件中清除
+//	T 需要能够被json序列化和反序列化
+type DiskCache[T any] struct {
+	mem    *AnyCache[T]
+	db     *bbolt.DB
+	closed atomic.Bool
+	logg   atomic.Pointer[logger.Logger]
+}
+
+// NewDiskCache 初始化一个新的可持久化缓存
+//
+//	filename: bolt数据文件路径，不存在时会自动创建
+//	expire: 缓存有效期
+//	 这个新缓存会创建一个线程检查内容是否过期，因此，当不再使用该缓存时，应该调用Close()方法关闭缓存
+//		默认每分钟清理一次过期缓存
+func NewDiskCache[T any](filename string, expire time.Duration) (*DiskCache[T], error) {
+	db, err := bbolt.Open(filename, 0o664, &bbolt.Options{Timeout: time.Second * 2})
+	if err != nil {
+		return nil, err
+	}
+	dc := &DiskCache[T]{
+		db: db,
+	}
+	dc.SetLogger(logger.NewConsoleLogger())
+	dc.mem = NewAnyCacheWithExpireFunc(expire, func(m map[string]T) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		if err := dc.removeExpired(keys...); err != nil {
+			dc.logError("remove expired", err)
+		}
+	})
+	if err = dc.load(); err != nil {
+		dc.mem.Close()
+		db.Close()
+		return nil, err
+	}
+	return dc, nil
+}
+
+// load 从文件载入未过期的缓存，并清除过期内容
+func (dc *DiskCache[T]) load() error {
+	return dc.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(diskBucket)
+		if err != nil {
+			return err
+		}
+		tnow := time.Now()
+		expired := make([][]byte, 0)
+		err = b.ForEach(func(k, v []byte) error {
+			expire, data, ok := decodeDiskItem[T](v)
+			if !ok || !tnow.Before(expire) {
+				expired = append(expired, bytes.Clone(k))
+				return nil
+			}
+			return dc.mem.StoreWithExpire(string(k), data, expire.Sub(tnow))
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// write 将缓存内容写入文件
+func (dc *DiskCache[T]) write(key string, value T, expire time.Duration) error {
+	v, err := encodeDiskItem(value, time.Now().Add(expire))
+	if err != nil {
+		return err
+	}
+	return dc.db.Batch(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(diskBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(json.Bytes(key), v)
+	})
+}
+
+// remove 从文件删除缓存内容
+func (dc *DiskCache[T]) remove(keys ...string) error {
+	if len(keys) == 0 || dc.closed.Load() {
+		return nil
+	}
+	return dc.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(diskBucket)
+		if b == nil {
+			return nil
+		}
+		for _, k := range keys {
+			if err := b.Delete(json.Bytes(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeExpired 从文件删除已过期的缓存内容
+//
+//	在事务内按文件中的过期时间再次检查，过期后又被重新写入的内容不删除
+func (dc *DiskCache[T]) removeExpired(keys ...string) error {
+	if len(keys) == 0 || dc.closed.Load() {
+		return nil
+	}
+	return dc.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(diskBucket)
+		if b == nil {
+			return nil
+		}
+		tnow := time.Now()
+		for _, k := range keys {
+			v := b.Get(json.Bytes(k))
+			if len(v) >= 8 && tnow.Before(time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))) {
+				continue
+			}
+			if err := b.Delete(json.Bytes(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetLogger 设置日志，用于记录无法返回的文件读写错误，默认输出到控制台
+func (dc *DiskCache[T]) SetLogger(l logger.Logger) {
+	if l == nil {
+		l = &logger.NilLogger{}
+	}
+	dc.logg.Store(&l)
+}
+
+func (dc *DiskCache[T]) logError(op string, err error) {
+	(*dc.logg.Load()).Error("[DiskCache] " + op + " failed: " + err.Error())
+}
+
+// SetCleanUp 设置清理周期，不低于1秒
+func (dc *DiskCache[T]) SetCleanUp(cleanup time.Duration) {
+	dc.mem.SetCleanUp(cleanup)
+}
+
+// Close 关闭这个缓存，已缓存的内容会保留在文件中，如果需要再次使用，应调用NewDiskCache方法重新初始化
+func (dc *DiskCache[T]) Close() {
+	if dc.closed.Swap(true) {
+		return
+	}
+	dc.mem.Close()
+	dc.db.Close()
+}
+
+// Clean 清空这个缓存，同时清空文件内容
+func (dc *DiskCache[T]) Clean() {
+	if dc.closed.Load() {
+		return
+	}
+	dc.mem.Clean()
+	err := dc.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(diskBucket) != nil {
+			if err := tx.DeleteBucket(diskBucket); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucket(diskBucket)
+		return err
+	})
+	if err != nil {
+		dc.logError("clean", err)
+	}
+}
+
+// Len 返回缓存内容数量
+func (dc *DiskCache[T]) Len() int {
+	if dc.closed.Load() {
+		return 0
+	}
+	return dc.mem.Len()
+}
+
+// Extension 将指定缓存延期，写入文件失败时记录日志
+func (dc *DiskCache[T]) Extension(key string) {
+	if dc.closed.Load() {
+		return
+	}
+	if v, ok := dc.mem.Load(key); ok {
+		dc.mem.Extension(key)
+		if err := dc.write(key, v, dc.mem.cacheExpire); err != nil {
+			dc.logError("extension "+key, err)
+		}
+	}
+}
+
+// Store 添加缓存内容，如果缓存已关闭或写入文件失败，会返回错误
+func (dc *DiskCache[T]) Store(key string, value T) error {
+	return dc.StoreWithExpire(key, value, dc.mem.cacheExpire)
+}
+
+// StoreWithExpire 添加缓存内容，设置自定义的有效时间，如果缓存已关闭或写入文件失败，会返回错误
+func (dc *DiskCache[T]) StoreWithExpire(key string, value T, expire time.Duration) error {
+	if dc.closed.Load() {
+		return fmt.Errorf("cache is closed")
+	}
+	if err := dc.write(key, value, expire); err != nil {
+		return err
+	}
+	return dc.mem.StoreWithExpire(key, value, expire)
+}
+
+// Load 读取一个缓存内容，如果不存在，返回false
+func (dc *DiskCache[T]) Load(key string) (T, bool) {
+	if dc.closed.Load() {
+		x := new(T)
+		return *x, false
+	}
+	return dc.mem.Load(key)
+}
+
+// LoadOrStore 读取或者设置一个缓存内如
+//
+//	当key存在时，返回缓存内容，并设置true
+//	当key不存在时，将内容加入缓存，返回设置内容，并设置false，写入文件失败时记录日志
+func (dc *DiskCache[T]) LoadOrStore(key string, value T) (T, bool) {
+	if dc.closed.Load() {
+		x := new(T)
+		return *x, false
+	}
+	v, ok := dc.mem.LoadOrStore(key, value)
+	if !ok {
+		if err := dc.write(key, value, dc.mem.cacheExpire); err != nil {
+			dc.logError("store "+key, err)
+		}
+	}
+	return v, ok
+}
+
+// Delete 删除一个缓存内容，从文件删除失败时记录日志
+func (dc *DiskCache[T]) Delete(key string) {
+	if dc.closed.Load() {
+		return
+	}
+	dc.mem.Delete(key)
+	if err := dc.remove(key); err != nil {
+		dc.logError("delete "+key, err)
+	}
+}
+
+// Stats 返回缓存统计信息快照
+func (dc *DiskCache[T]) Stats() Stats {
+	return dc.mem.Stats()
+}
+
+// ForEach 遍历所有缓存内容
+func (dc *DiskCache[T]) ForEach(f func(key string, value T) bool) {
+	if dc.closed.Load() {
+		return
+	}
+	dc.mem.ForEach(f)
+}
+
+// encodeDiskItem 文件内容格式：8字节过期时间(unix纳秒)+json数据
+func encodeDiskItem[T any](value T, expire time.Time) ([]byte, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	v := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint64(v, uint64(expire.UnixNano()))
+	return append(v, b...), nil
+}
+
+func decodeDiskItem[T any](v []byte) (time.Time, T, bool) {
+	var data T
+	if len(v) < 8 {
+		return time.Time{}, data, false
+	}
+	expire := time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))
+	// bolt返回的数据仅在事务内有效，需要复制后再反序列化
+	if err := json.Unmarshal(bytes.Clone(v[8:]), &data); err != nil {
+		return time.Time{}, data, false
+	}
+	return expire, data, true
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xyzj/gopsu/logger"
+	"github.com/xyzj/gopsu/loopfunc"
+)
+
+type cErr struct {
+	expire time.Time
+	err    error
+}
+
+type flightCall[T any] struct {
+	wg  sync.WaitGroup
+	val T
+	err error
+}
+
+// flightGroup 合并同一个key的并发加载请求，同一时间只执行一次加载
+type flightGroup[T any] struct {
+	locker sync.Mutex
+	calls  map[string]*flightCall[T]
+}
+
+// do 执行加载，若该key已经在加载中，则等待并共享其结果
+func (g *flightGroup[T]) do(key string, fn func() (T, error)) (T, error) {
+	g.locker.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.locker.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &flightCall[T]{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.locker.Unlock()
+
+	func() {
+		defer func() {
+			if err := recover(); err != nil {
+				c.err = fmt.Errorf("loader panic: %v", err)
+			}
+		}()
+		c.val, c.err = fn()
+	}()
+	c.wg.Done()
+
+	g.locker.Lock()
+	delete(g.calls, key)
+	g.locker.Unlock()
+	return c.val, c.err
+}
+
+// loading 判断该key是否正在加载
+func (g *flightGroup[T]) loading(key string) bool {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+// GetOrLoad 读取一个缓存内容，不存在时调用loader加载并写入缓存
+//
+//	同一个key的并发请求只会调用一次loader，其他请求等待并共享结果
+//	设置了ErrorExpire时，loader返回的错误会被缓存，有效期内的请求直接返回该错误
+//	设置了RefreshAhead时，命中的缓存剩余有效期不足时会在后台重新加载，本次请求仍返回当前内容
+func (ac *AnyCache[T]) GetOrLoad(key string, loader func() (T, error)) (T, error) {
+	x := new(T)
+	if ac.closed.Load() {
+		return *x, fmt.Errorf("cache is closed")
+	}
+	tnow := time.Now()
+	if v, ok := ac.cache.Load(key); ok && tnow.Before(v.expire) {
+		ac.stats.hit(true)
+		if ac.bound != nil {
+			ac.bound.Lock()
+			ac.bound.touch(key)
+			ac.bound.Unlock()
+		}
+		if ac.refreshAhead > 0 && v.expire.Sub(tnow) < ac.refreshAhead && !ac.flight.loading(key) {
+			loopfunc.GoFunc(func(params ...interface{}) {
+				ac.load(key, loader, true)
+			}, "cache refresh", logger.NewConsoleWriter())
+		}
+		return v.data, nil
+	}
+	if e, ok := ac.errs.Load(key); ok && tnow.Before(e.expire) {
+		// 缓存的错误不算命中
+		ac.stats.hit(false)
+		ac.stats.errorHits.Add(1)
+		return *x, e.err
+	}
+	ac.stats.hit(false)
+	return ac.load(key, loader, false)
+}
+
+// load 调用loader加载内容并写入缓存
+//
+//	refresh: 是否为后台刷新，后台刷新失败时保留原有内容，不缓存错误
+func (ac *AnyCache[T]) load(key string, loader func() (T, error), refresh bool) (T, error) {
+	return ac.flight.do(key, func() (T, error) {
+		start := time.Now()
+		v, err := loader()
+		ac.stats.load(time.Since(start), err)
+		if ac.closed.Load() {
+			return v, err
+		}
+		if err != nil {
+			if !refresh && ac.errExpire > 0 {
+				ac.errs.Store(key, &cErr{
+					expire: time.Now().Add(ac.errExpire),
+					err:    err,
+				})
+			}
+			return v, err
+		}
+		ac.errs.Delete(key)
+		ac.StoreWithExpire(key, v, ac.cacheExpire)
+		return v, nil
+	})
+}
+
+// cleanErrs 清理过期的错误缓存
+func (ac *AnyCache[T]) cleanErrs(tnow time.Time) {
+	if ac.errs.Len() == 0 {
+		return
+	}
+	keys := make([]string, 0)
+	ac.errs.ForEachWithRLocker(func(key string, value *cErr) bool {
+		if tnow.After(value.expire) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	ac.errs.DeleteMore(keys...)
+}
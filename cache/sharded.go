@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xyzj/gopsu/logger"
+	"github.com/xyzj/gopsu/loopfunc"
+)
+
+type shardItem[T any] struct {
+	key    string
+	data   T
+	expire time.Time
+	index  int // 在过期堆中的位置
+}
+
+// expiryHeap 按过期时间排序的最小堆
+type expiryHeap[T any] []*shardItem[T]
+
+func (h expiryHeap[T]) Len() int           { return len(h) }
+func (h expiryHeap[T]) Less(i, j int) bool { return h[i].expire.Before(h[j].expire) }
+func (h expiryHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap[T]) Push(x any) {
+	it := x.(*shardItem[T])
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap[T]) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*h = old[:n-1]
+	return it
+}
+
+type shard[T any] struct {
+	sync.RWMutex
+	data    map[string]*shardItem[T]
+	expires expiryHeap[T]
+}
+
+func (s *shard[T]) store(key string, value T, expire time.Time) {
+	if it, ok := s.data[key]; ok {
+		it.data = value
+		it.expire = expire
+		heap.Fix(&s.expires, it.index)
+		return
+	}
+	it := &shardItem[T]{key: key, data: value, expire: expire}
+	s.data[key] = it
+	heap.Push(&s.expires, it)
+}
+
+func (s *shard[T]) remove(key string) {
+	if it, ok := s.data[key]; ok {
+		heap.Remove(&s.expires, it.index)
+		delete(s.data, key)
+	}
+}
+
+// cleanup 从堆顶开始移除过期内容，只访问已过期的内容
+func (s *shard[T]) cleanup(tnow time.Time, ex map[string]T) {
+	s.Lock()
+	defer s.Unlock()
+	for len(s.expires) > 0 && tnow.After(s.expires[0].expire) {
+		it := heap.Pop(&s.expires).(*shardItem[T])
+		delete(s.data, it.key)
+		ex[it.key] = it.data
+	}
+}
+
+// ShardedCache 分片的泛型结构缓存，适用于高并发场景
+//
+//	每个分片拥有独立的锁，并按过期时间维护最小堆，清理时只处理已过期的内容
+type ShardedCache[T any] struct {
+	shards       []*shard[T]
+	mask         uint32
+	stats        counters
+	cacheCleanup *time.Ticker
+	cacheExpire  time.Duration
+	closed       atomic.Bool
+	closeChan    chan bool
+}
+
+// NewShardedCacheWithExpireFunc 初始化一个新的分片缓存,在缓存过期时，会执行expireFunc函数
+//
+//	shards: 分片数量，会向上取整为2的幂，小于等于0时使用cpu核数*4
+//	 这个新缓存会创建一个线程检查内容是否过期，因此，当不再使用该缓存时，应该调用Close()方法关闭缓存
+//		默认每分钟清理一次过期缓存
+func NewShardedCacheWithExpireFunc[T any](expire time.Duration, shards int, expireFunc func(map[string]T)) *ShardedCache[T] {
+	if shards <= 0 {
+		shards = runtime.NumCPU() * 4
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	x := &ShardedCache[T]{
+		shards:       make([]*shard[T], n),
+		mask:         uint32(n - 1),
+		cacheCleanup: time.NewTicker(time.Second * 60),
+		cacheExpire:  expire,
+		closeChan:    make(chan bool, 1),
+	}
+	for i := range x.shards {
+		x.shards[i] = &shard[T]{
+			data: make(map[string]*shardItem[T]),
+		}
+	}
+	go loopfunc.LoopFunc(func(params ...interface{}) {
+		for {
+			select {
+			case <-x.closeChan:
+				return
+			case <-x.cacheCleanup.C:
+				tnow := time.Now()
+				ex := make(map[string]T)
+				for _, s := range x.shards {
+					s.cleanup(tnow, ex)
+				}
+				if len(ex) > 0 {
+					x.stats.expirations.Add(uint64(len(ex)))
+					if expireFunc != nil {
+						loopfunc.GoFunc(func(params ...interface{}) {
+							expireFunc(ex)
+						}, "expire func", logger.NewConsoleWriter())
+					}
+				}
+			}
+		}
+	}, "sharded cache", logger.NewConsoleWriter())
+	return x
+}
+
+// NewShardedCache 初始化一个新的分片缓存
+//
+//	shards: 分片数量，会向上取整为2的幂，小于等于0时使用cpu核数*4
+//	 这个新缓存会创建一个线程检查内容是否过期，因此，当不再使用该缓存时，应该调用Close()方法关闭缓存
+//		默认每分钟清理一次过期缓存
+func NewShardedCache[T any](expire time.Duration, shards int) *ShardedCache[T] {
+	return NewShardedCacheWithExpireFunc[T](expire, shards, nil)
+}
+
+// getShard fnv-1a
+func (sc *ShardedCache[T]) getShard(key string) *shard[T] {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return sc.shards[h&sc.mask]
+}
+
+// SetCleanUp 设置清理周期，不低于1秒
+func (sc *ShardedCache[T]) SetCleanUp(cleanup time.Duration) {
+	if cleanup < time.Second {
+		cleanup = time.Second
+	}
+	sc.cacheCleanup.Reset(cleanup)
+}
+
+// Close 关闭这个缓存，如果需要再次使用，应调用NewShardedCache方法重新初始化
+func (sc *ShardedCache[T]) Close() {
+	if sc.closed.Swap(true) {
+		return
+	}
+	sc.cacheCleanup.Stop()
+	sc.closeChan <- true
+	sc.clean()
+}
+
+// Clean 清空这个缓存
+func (sc *ShardedCache[T]) Clean() {
+	if sc.closed.Load() {
+		return
+	}
+	sc.clean()
+}
+
+func (sc *ShardedCache[T]) clean() {
+	for _, s := range sc.shards {
+		s.Lock()
+		s.data = make(map[string]*shardItem[T])
+		s.expires = nil
+		s.Unlock()
+	}
+}
+
+// Len 返回缓存内容数量
+func (sc *ShardedCache[T]) Len() int {
+	if sc.closed.Load() {
+		return 0
+	}
+	l := 0
+	for _, s := range sc.shards {
+		s.RLock()
+		l += len(s.data)
+		s.RUnlock()
+	}
+	return l
+}
+
+// Extension 将指定缓存延期
+func (sc *ShardedCache[T]) Extension(key string) {
+	if sc.closed.Load() {
+		return
+	}
+	s := sc.getShard(key)
+	s.Lock()
+	defer s.Unlock()
+	if it, ok := s.data[key]; ok {
+		it.expire = time.Now().Add(sc.cacheExpire)
+		heap.Fix(&s.expires, it.index)
+	}
+}
+
+// Store 添加缓存内容，如果缓存已关闭，会返回错误
+func (sc *ShardedCache[T]) Store(key string, value T) error {
+	return sc.StoreWithExpire(key, value, sc.cacheExpire)
+}
+
+// StoreWithExpire 添加缓存内容，设置自定义的有效时间，如果缓存已关闭，会返回错误
+func (sc *ShardedCache[T]) StoreWithExpire(key string, value T, expire time.Duration) error {
+	if sc.closed.Load() {
+		return fmt.Errorf("cache is closed")
+	}
+	sc.stats.stores.Add(1)
+	s := sc.getShard(key)
+	s.Lock()
+	s.store(key, value, time.Now().Add(expire))
+	s.Unlock()
+	return nil
+}
+
+// Load 读取一个缓存内容，如果不存在，返回false
+func (sc *ShardedCache[T]) Load(key string) (T, bool) {
+	x := new(T)
+	if sc.closed.Load() {
+		return *x, false
+	}
+	s := sc.getShard(key)
+	s.RLock()
+	it, ok := s.data[key]
+	if !ok || time.Now().After(it.expire) {
+		s.RUnlock()
+		sc.stats.hit(false)
+		return *x, false
+	}
+	v := it.data
+	s.RUnlock()
+	sc.stats.hit(true)
+	return v, true
+}
+
+// LoadOrStore 读取或者设置一个缓存内如
+//
+//	当key存在时，返回缓存内容，并设置true
+//	当key不存在时，将内容加入缓存，返回设置内容，并设置false
+func (sc *ShardedCache[T]) LoadOrStore(key string, value T) (T, bool) {
+	x := new(T)
+	if sc.closed.Load() {
+		return *x, false
+	}
+	s := sc.getShard(key)
+	tnow := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	if it, ok := s.data[key]; ok && !tnow.After(it.expire) {
+		sc.stats.hit(true)
+		return it.data, true
+	}
+	sc.stats.hit(false)
+	sc.stats.stores.Add(1)
+	s.store(key, value, tnow.Add(sc.cacheExpire))
+	return value, false
+}
+
+// Delete 删除一个缓存内容
+func (sc *ShardedCache[T]) Delete(key string) {
+	if sc.closed.Load() {
+		return
+	}
+	s := sc.getShard(key)
+	s.Lock()
+	s.remove(key)
+	s.Unlock()
+}
+
+// Stats 返回缓存统计信息快照
+func (sc *ShardedCache[T]) Stats() Stats {
+	return Stats{
+		Hits:        sc.stats.hits.Load(),
+		Misses:      sc.stats.misses.Load(),
+		Stores:      sc.stats.stores.Load(),
+		Expirations: sc.stats.expirations.Load(),
+		Len:         sc.Len(),
+	}
+}
+
+// ForEach 遍历所有缓存内容
+//
+//	逐个分片复制后遍历，遍历过程中可安全读写缓存
+func (sc *ShardedCache[T]) ForEach(f func(key string, value T) bool) {
+	if sc.closed.Load() {
+		return
+	}
+	for _, s := range sc.shards {
+		tnow := time.Now()
+		s.RLock()
+		items := make([]shardItem[T], 0, len(s.data))
+		for _, it := range s.data {
+			if !tnow.After(it.expire) {
+				items = append(items, *it)
+			}
+		}
+		s.RUnlock()
+		for _, it := range items {
+			if !f(it.key, it.data) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// counters 缓存计数器
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	stores      atomic.Uint64
+	expirations atomic.Uint64
+	evictions   atomic.Uint64
+	loads       atomic.Uint64
+	loadErrors  atomic.Uint64
+	errorHits   atomic.Uint64
+	loadTime    atomic.Int64
+}
+
+func (c *counters) hit(ok bool) {
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *counters) load(dur time.Duration, err error) {
+	c.loads.Add(1)
+	c.loadTime.Add(int64(dur))
+	if err != nil {
+		c.loadErrors.Add(1)
+	}
+}
+
+// Stats 缓存统计信息快照
+type Stats struct {
+	// 命中次数
+	Hits uint64 `json:"hits"`
+	// 未命中次数
+	Misses uint64 `json:"misses"`
+	// 写入次数
+	Stores uint64 `json:"stores"`
+	// 过期清理的数量
+	Expirations uint64 `json:"expirations"`
+	// 超出容量限制被淘汰的数量
+	Evictions uint64 `json:"evictions"`
+	// GetOrLoad 调用loader的次数
+	Loads uint64 `json:"loads"`
+	// GetOrLoad loader返回错误的次数
+	LoadErrors uint64 `json:"load_errors"`
+	// GetOrLoad 直接返回缓存的错误的次数，同时计入未命中次数
+	ErrorHits uint64 `json:"error_hits"`
+	// GetOrLoad loader累计耗时
+	LoadTime time.Duration `json:"load_time"`
+	// 当前缓存数量
+	Len int `json:"len"`
+}
+
+// HitRate 命中率，没有读取时返回0
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AvgLoadTime loader平均耗时
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// Prometheus 返回Prometheus文本格式的统计信息
+//
+//	name: 缓存名称，作为cache标签的值
+func (s Stats) Prometheus(name string) string {
+	return PrometheusText(map[string]Stats{name: s})
+}
+
+type promMetric struct {
+	name  string
+	help  string
+	mtype string
+	value func(s Stats) string
+}
+
+var promMetrics = []promMetric{
+	{"gopsu_cache_hits_total", "Number of cache hits.", "counter", func(s Stats) string { return strconv.FormatUint(s.Hits, 10) }},
+	{"gopsu_cache_misses_total", "Number of cache misses.", "counter", func(s Stats) string { return strconv.FormatUint(s.Misses, 10) }},
+	{"gopsu_cache_stores_total", "Number of cache stores.", "counter", func(s Stats) string { return strconv.FormatUint(s.Stores, 10) }},
+	{"gopsu_cache_expirations_total", "Number of expired entries removed.", "counter", func(s Stats) string { return strconv.FormatUint(s.Expirations, 10) }},
+	{"gopsu_cache_evictions_total", "Number of entries evicted by capacity limits.", "counter", func(s Stats) string { return strconv.FormatUint(s.Evictions, 10) }},
+	{"gopsu_cache_loads_total", "Number of loader calls.", "counter", func(s Stats) string { return strconv.FormatUint(s.Loads, 10) }},
+	{"gopsu_cache_load_errors_total", "Number of loader calls that returned an error.", "counter", func(s Stats) string { return strconv.FormatUint(s.LoadErrors, 10) }},
+	{"gopsu_cache_error_hits_total", "Number of cached loader errors returned.", "counter", func(s Stats) string { return strconv.FormatUint(s.ErrorHits, 10) }},
+	{"gopsu_cache_load_duration_seconds_total", "Total time spent in loader calls.", "counter", func(s Stats) string { return strconv.FormatFloat(s.LoadTime.Seconds(), 'g', -1, 64) }},
+	{"gopsu_cache_entries", "Number of entries in the cache.", "gauge", func(s Stats) string { return strconv.Itoa(s.Len) }},
+}
+
+// PrometheusText 返回多个缓存的Prometheus文本格式统计信息
+//
+//	stats: key为缓存名称，作为cache标签的值
+func PrometheusText(stats map[string]Stats) string {
+	names := make([]string, 0, len(stats))
+	for k := range stats {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, m := range promMetrics {
+		sb.WriteString("# HELP " + m.name + " " + m.help + "\n")
+		sb.WriteString("# TYPE " + m.name + " " + m.mtype + "\n")
+		for _, name := range names {
+			sb.WriteString(m.name + `{cache="` + promEscape(name) + `"} ` + m.value(stats[name]) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+var promReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string {
+	return promReplacer.Replace(s)
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xyzj/gopsu/json"
+)
+
+// InvalidationBus 缓存失效消息的传输接口，可使用mqtt，rabbitmq等实现
+type InvalidationBus interface {
+	// Publish 广播一条失效消息
+	Publish(body []byte) error
+	// Subscribe 设置收到失效消息时的处理方法
+	Subscribe(f func(body []byte))
+}
+
+const (
+	invalidDelete = "del"
+	invalidClean  = "clean"
+)
+
+type invalidMsg struct {
+	Node string   `json:"node"`
+	Op   string   `json:"op"`
+	Keys []string `json:"keys,omitempty"`
+}
+
+// SyncCache 多实例间同步失效的缓存
+//
+//	本地缓存写入或删除内容时，会通过InvalidationBus广播失效消息，其他实例收到后删除本地对应的内容，下次读取时重新加载
+type SyncCache[T any] struct {
+	local Cache[T]
+	bus   InvalidationBus
+	node  string
+}
+
+// NewSyncCache 使用本地缓存和失效消息通道创建一个多实例同步的缓存
+//
+//	local: 本地缓存，如AnyCache，ShardedCache
+//	bus: 失效消息通道，所有实例应使用相同的通道
+func NewSyncCache[T any](local Cache[T], bus InvalidationBus) *SyncCache[T] {
+	sc := &SyncCache[T]{
+		local: local,
+		bus:   bus,
+		node:  uuid.NewString(),
+	}
+	bus.Subscribe(sc.recv)
+	return sc
+}
+
+// recv 处理其他实例的失效消息
+func (sc *SyncCache[T]) recv(body []byte) {
+	msg := &invalidMsg{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return
+	}
+	if msg.Node == sc.node {
+		return
+	}
+	switch msg.Op {
+	case invalidDelete:
+		for _, k := range msg.Keys {
+			sc.local.Delete(k)
+		}
+	case invalidClean:
+		sc.local.Clean()
+	}
+}
+
+func (sc *SyncCache[T]) publish(op string, keys ...string) error {
+	b, err := json.Marshal(&invalidMsg{
+		Node: sc.node,
+		Op:   op,
+		Keys: keys,
+	})
+	if err != nil {
+		return err
+	}
+	return sc.bus.Publish(b)
+}
+
+// Close 关闭本地缓存，InvalidationBus需要自行关闭
+func (sc *SyncCache[T]) Close() {
+	sc.local.Close()
+}
+
+// Clean 清空本地缓存，并通知其他实例清空
+func (sc *SyncCache[T]) Clean() {
+	sc.local.Clean()
+	sc.publish(invalidClean)
+}
+
+// Len 返回本地缓存内容数量
+func (sc *SyncCache[T]) Len() int {
+	return sc.local.Len()
+}
+
+// Extension 将指定缓存延期，仅对本地缓存有效
+func (sc *SyncCache[T]) Extension(key string) {
+	sc.local.Extension(key)
+}
+
+// Store 添加缓存内容，并通知其他实例删除该内容，如果缓存已关闭或广播失败，会返回错误
+func (sc *SyncCache[T]) Store(key string, value T) error {
+	if err := sc.local.Store(key, value); err != nil {
+		return err
+	}
+	return sc.publish(invalidDelete, key)
+}
+
+// StoreWithExpire 添加缓存内容，设置自定义的有效时间，并通知其他实例删除该内容，如果缓存已关闭或广播失败，会返回错误
+func (sc *SyncCache[T]) StoreWithExpire(key string, value T, expire time.Duration) error {
+	if err := sc.local.StoreWithExpire(key, value, expire); err != nil {
+		return err
+	}
+	return sc.publish(invalidDelete, key)
+}
+
+// Load 读取一个本地缓存内容，如果不存在，返回false
+func (sc *SyncCache[T]) Load(key string) (T, bool) {
+	return sc.local.Load(key)
+}
+
+// LoadOrStore 读取或者设置一个缓存内如
+//
+//	当key存在时，返回缓存内容，并设置true
+//	当key不存在时，将内容加入缓存，通知其他实例删除该内容，返回设置内容，并设置false
+func (sc *SyncCache[T]) LoadOrStore(key string, value T) (T, bool) {
+	v, ok := sc.local.LoadOrStore(key, value)
+	if !ok {
+		sc.publish(invalidDelete, key)
+	}
+	return v, ok
+}
+
+// Delete 删除一个缓存内容，并通知其他实例删除
+func (sc *SyncCache[T]) Delete(key string) {
+	sc.local.Delete(key)
+	sc.publish(invalidDelete, key)
+}
+
+// Stats 返回本地缓存的统计信息快照，本地缓存不支持统计时只返回内容数量
+func (sc *SyncCache[T]) Stats() Stats {
+	if s, ok := sc.local.(interface{ Stats() Stats }); ok {
+		return s.Stats()
+	}
+	return Stats{Len: sc.local.Len()}
+}
+
+// ForEach 遍历所有本地缓存内容
+func (sc *SyncCache[T]) ForEach(f func(key string, value T) bool) {
+	sc.local.ForEach(f)
+}
+
+// MemoryBus 进程内的失效消息通道，用于测试或同一进程内的多个缓存
+type MemoryBus struct {
+	locker sync.RWMutex
+	subs   []func(body []byte)
+}
+
+// NewMemoryBus 创建一个进程内的失效消息通道
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subs: make([]func(body []byte), 0),
+	}
+}
+
+// Publish 将消息同步发送给所有订阅者
+func (b *MemoryBus) Publish(body []byte) error {
+	b.locker.RLock()
+	subs := b.subs
+	b.locker.RUnlock()
+	for _, f := range subs {
+		f(body)
+	}
+	return nil
+}
+
+// Subscribe 添加一个订阅者
+func (b *MemoryBus) Subscribe(f func(body []byte)) {
+	b.locker.Lock()
+	b.subs = append(b.subs, f)
+	b.locker.Unlock()
+}
@@ -94,5 +94,10 @@ func (ac *EmptyCache[T]) LoadOrStore(key string, value T) (T, bool) {
 // Delete 删除一个缓存内容
 func (ac *EmptyCache[T]) Delete(key string) {}
 
+// Stats 返回缓存统计信息快照
+func (ac *EmptyCache[T]) Stats() Stats {
+	return Stats{}
+}
+
 // ForEach 遍历所有缓存内容
 func (ac *EmptyCache[T]) ForEach(f func(key string, value T) bool) {}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 结构体绑定使用的tag
+//
+//	config: 配置项名称，`-`表示忽略该字段，默认为小写的字段名，嵌套结构体的字段名称为 父名称.子名称
+//	default: 默认值，配置不存在时使用
+//	required: 为true时配置必须存在或有默认值
+//	min,max: 数值的范围，字符串和切片的长度范围
+//	enum: 可选值，使用`,`分割
+//	regexp: 值需要满足的正则表达式
+//	comment: 生成配置文件时的注释
+//
+// 支持的字段类型：string，bool，int*，uint*，float*，time.Duration，PwdString，以及这些类型的切片（配置值使用`,`分割）
+// time.Duration支持time.ParseDuration的格式，以及单位为秒的数字，和Value.TryDuration相同
+const (
+	tagKey      = "config"
+	tagDefault  = "default"
+	tagRequired = "required"
+	tagMin      = "min"
+	tagMax      = "max"
+	tagEnum     = "enum"
+	tagRegexp   = "regexp"
+	tagComment  = "comment"
+)
+
+var (
+	typeDuration  = reflect.TypeOf(time.Duration(0))
+	typePwdString = reflect.TypeOf(PwdString(""))
+)
+
+// FieldError 单个配置项的校验错误
+type FieldError struct {
+	Key   string
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Msg
+}
+
+// BindErrors 绑定结构体时的所有校验错误
+type BindErrors []*FieldError
+
+func (e BindErrors) Error() string {
+	ss := make([]string, 0, len(e))
+	for _, fe := range e {
+		ss = append(ss, "  - "+fe.Error())
+	}
+	return "config validate failed:\n" + strings.Join(ss, "\n")
+}
+
+// structField 结构体字段及其配置信息
+type structField struct {
+	value reflect.Value
+	field reflect.StructField
+	key   string
+}
+
+func (sf *structField) tag(name string) (string, bool) {
+	return sf.field.Tag.Lookup(name)
+}
+
+// walkStruct 遍历结构体的所有可绑定字段
+func walkStruct(v reflect.Value, prefix string, do func(sf *structField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, ok := field.Tag.Lookup(tagKey)
+		if name == "-" {
+			continue
+		}
+		if !ok || name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != typeDuration {
+			walkStruct(fv, name, do)
+			continue
+		}
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				fv.Set(reflect.New(field.Type.Elem()))
+			}
+			walkStruct(fv.Elem(), name, do)
+			continue
+		}
+		do(&structField{value: fv, field: field, key: name})
+	}
+}
+
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return rv, fmt.Errorf("config bind: need a non-nil pointer to struct, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+// Bind 将配置绑定到结构体，并依据tag进行校验
+//
+//	v: 结构体指针
+//	配置不存在时使用default设置的默认值，都不存在时保留字段原值
+//	所有字段的类型错误和校验错误会汇总为BindErrors返回，有错误时不会修改任何字段（为nil的结构体指针字段仍会被初始化）
+func (f *File) Bind(v any) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	errs := make(BindErrors, 0)
+	// 全部校验通过后再赋值
+	sets := make([]func(), 0)
+	walkStruct(rv, "", func(sf *structField) {
+		fail := func(format string, a ...any) {
+			errs = append(errs, &FieldError{Key: sf.key, Field: sf.field.Name, Msg: fmt.Sprintf(format, a...)})
+		}
+		var s string
+		if val, l := f.GetItemLayer(sf.key); l != LayerNone {
+			s = val.String()
+		} else if d, ok := sf.tag(tagDefault); ok {
+			s = d
+		} else {
+			if r, _ := sf.tag(tagRequired); r == "true" {
+				fail("is required")
+			}
+			return
+		}
+		if err := validateString(sf, s); err != nil {
+			fail("%s", err.Error())
+			return
+		}
+		nv := reflect.New(sf.field.Type).Elem()
+		if err := setValue(nv, s); err != nil {
+			fail("%s", err.Error())
+			return
+		}
+		if err := validateRange(sf, nv); err != nil {
+			fail("%s", err.Error())
+			return
+		}
+		sets = append(sets, func() { sf.value.Set(nv) })
+	})
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, set := range sets {
+		set()
+	}
+	return nil
+}
+
+// validateString 校验enum和regexp
+func validateString(sf *structField, s string) error {
+	if e, ok := sf.tag(tagEnum); ok && e != "" {
+		found := false
+		for _, x := range strings.Split(e, ",") {
+			if strings.TrimSpace(x) == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value %q is not one of [%s]", s, e)
+		}
+	}
+	if r, ok := sf.tag(tagRegexp); ok && r != "" {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return fmt.Errorf("bad regexp %q: %s", r, err.Error())
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("value %q does not match %q", s, r)
+		}
+	}
+	return nil
+}
+
+// validateRange 校验min和max，数值比较大小，字符串和切片比较长度
+func validateRange(sf *structField, v reflect.Value) error {
+	var n float64
+	what := "value"
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+		if v.Type() == typeDuration {
+			n = time.Duration(v.Int()).Seconds()
+			what = "duration(seconds)"
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String, reflect.Slice:
+		n = float64(v.Len())
+		what = "length"
+	default:
+		return nil
+	}
+	if s, ok := sf.tag(tagMin); ok && s != "" {
+		m, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("bad min tag %q", s)
+		}
+		if n < m {
+			return fmt.Errorf("%s %v is less than min %s", what, n, s)
+		}
+	}
+	if s, ok := sf.tag(tagMax); ok && s != "" {
+		m, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("bad max tag %q", s)
+		}
+		if n > m {
+			return fmt.Errorf("%s %v is greater than max %s", what, n, s)
+		}
+	}
+	return nil
+}
+
+// setValue 将字符串转换为字段类型并赋值，转换失败时返回错误
+func setValue(v reflect.Value, s string) error {
+	switch v.Type() {
+	case typeDuration:
+		d, err := parseDuration(s)
+		if err != nil {
+			return fmt.Errorf("value %q is not a duration", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	case typePwdString:
+		v.SetString(decodeSecret(s))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("value %q is not a bool", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("value %q is not a %s", s, v.Type().String())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("value %q is not a %s", s, v.Type().String())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("value %q is not a %s", s, v.Type().String())
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		ss := make([]string, 0)
+		for _, x := range strings.Split(s, ",") {
+			if x = strings.TrimSpace(x); x != "" {
+				ss = append(ss, x)
+			}
+		}
+		sv := reflect.MakeSlice(v.Type(), len(ss), len(ss))
+		for i, x := range ss {
+			if err := setValue(sv.Index(i), x); err != nil {
+				return err
+			}
+		}
+		v.Set(sv)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type().String())
+	}
+	return nil
+}
+
+// formatValue 将字段值转换为配置字符串
+func formatValue(v reflect.Value) string {
+	switch v.Type() {
+	case typeDuration:
+		return time.Duration(v.Int()).String()
+	case typePwdString:
+		if v.String() == "" {
+			return ""
+		}
+		return encodeSecret(v.String())
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		ss := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ss[i] = formatValue(v.Index(i))
+		}
+		return strings.Join(ss, ",")
+	default:
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
+
+// FromStruct 依据结构体添加配置项，已存在的配置项不会被修改，可用于生成带注释的默认配置文件
+//
+//	v: 结构体或结构体指针
+//	字段为非零值时使用字段值，否则使用default设置的默认值
+//	注释来自comment，同时会附加enum，min，max的说明
+func (f *File) FromStruct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("config bind: need a struct, got %T", v)
+	}
+	// 复制一份，避免修改传入的结构体中的nil指针
+	x := reflect.New(rv.Type()).Elem()
+	x.Set(rv)
+	walkStruct(x, "", func(sf *structField) {
+		if f.items.Has(sf.key) {
+			return
+		}
+		s := ""
+		if !sf.value.IsZero() {
+			s = formatValue(sf.value)
+		} else if d, ok := sf.tag(tagDefault); ok {
+			s = d
+		}
+		comments := make([]string, 0, 3)
+		if c, ok := sf.tag(tagComment); ok && c != "" {
+			comments = append(comments, c)
+		}
+		if e, ok := sf.tag(tagEnum); ok && e != "" {
+			comments = append(comments, "可选值: "+e)
+		}
+		min, _ := sf.tag(tagMin)
+		max, _ := sf.tag(tagMax)
+		if min != "" || max != "" {
+			comments = append(comments, "范围: ["+min+", "+max+"]")
+		}
+		f.PutItem(&Item{
+			Key:     sf.key,
+			Value:   NewValue(s),
+			Comment: strings.Join(comments, "\n"),
+		})
+	})
+	return nil
+}
+
+// WriteDefault 依据结构体生成带注释的默认配置文件，依据文件扩展名判断写入格式
+func WriteDefault(v any, filename string) error {
+	f := NewConfig("")
+	if err := f.FromStruct(v); err != nil {
+		return err
+	}
+	return f.SaveTo(filename)
+}
@@ -3,10 +3,14 @@ package config
 
 import (
 	"bytes"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -15,12 +19,25 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// ErrPatch 启用SetStrictPatch后，无法在原文件内容上更新配置项，可使用Rewrite重新生成整个文件
+var ErrPatch = errors.New("config: can not patch the original file")
+
 // File 配置文件
 type File struct {
 	items      *mapfx.StructMap[string, Item]
 	data       *bytes.Buffer
+	envMapper  func(key string) string
+	flags      *flag.FlagSet
+	flagMapper func(key string) string
+	watch      *watcher
+	watchOnce  sync.Once
+	// locker 保护raw，data，formatType，以及重新载入时配置项的整体替换
+	locker     sync.RWMutex
+	raw        []byte
 	filepath   string
 	formatType FormatType
+	// strict 无法在原文件内容上更新时返回ErrPatch，不重新生成
+	strict bool
 }
 
 // Item 配置内容，包含注释，key,value,是否加密value
@@ -29,6 +46,7 @@ type Item struct {
 	Comment string `json:"comment" yaml:"comment"`
 	Key     string `json:"-" yaml:"-"`
 	// EncryptValue bool   `json:"-" yaml:"-"`
+	layer Layer
 }
 
 // String 把配置项格式化成字符串
@@ -40,9 +58,9 @@ func (i *Item) String() string {
 	xcom := ""
 	for _, v := range ss {
 		if strings.HasPrefix(v, "#") {
-			xcom = v + "\n"
+			xcom += v + "\n"
 		} else {
-			xcom = "# " + v + "\n"
+			xcom += "# " + v + "\n"
 		}
 	}
 	return "\n" + xcom + i.Key + "=" + i.Value.String() + "\n" // fmt.Sprintf("\n%s%s=%s\n", xcom, i.Key, i.Value)
@@ -73,8 +91,11 @@ func (f *File) Keys() []string {
 
 // Clean 清空配置项
 func (f *File) Clean() {
+	f.locker.Lock()
+	defer f.locker.Unlock()
 	f.items.Clean()
 	f.data.Reset()
+	f.raw = nil
 }
 
 // DelItem 删除配置项
@@ -96,25 +117,30 @@ func (f *File) PutItem(item *Item) {
 }
 
 // GetDefault 读取一个配置，若不存在，则添加这个配置
+//
+//	启用了环境变量或命令行参数时，返回覆盖后的值
 func (f *File) GetDefault(item *Item) *Value {
-	if v, ok := f.items.Load(item.Key); ok {
-		return v.Value
+	if !f.items.Has(item.Key) {
+		item.layer = LayerDefault
+		f.PutItem(item)
 	}
-	f.PutItem(item)
-	return item.Value
+	return f.GetItem(item.Key)
 }
 
 // GetItem 获取一个配置值
+//
+//	优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
 func (f *File) GetItem(key string) *Value {
-	if v, ok := f.items.Load(key); ok {
-		return v.Value
-	}
-	return EmptyValue
+	v, _ := f.GetItemLayer(key)
+	return v
 }
 
-// ForEach 遍历所有值
+// ForEach 遍历所有值，启用了环境变量或命令行参数时，返回覆盖后的值
 func (f *File) ForEach(do func(key string, value *Value) bool) {
 	f.items.ForEach(func(key string, value *Item) bool {
+		if v, l := f.overlay(key); l != LayerNone {
+			return do(key, v)
+		}
 		return do(key, value.Value)
 	})
 }
@@ -124,26 +150,39 @@ func (f *File) Len() int {
 	return f.items.Len()
 }
 
-// Has 判断key是否存在
+// Has 判断key是否存在，包括环境变量，命令行参数和嵌套的值
 func (f *File) Has(key string) bool {
-	return f.items.Has(key)
+	if f.items.Has(key) {
+		return true
+	}
+	_, l := f.GetItemLayer(key)
+	return l != LayerNone
 }
 
 // Print 返回所有配置项
 func (f *File) Print() string {
-	x := make([]*Item, 0, f.items.Len())
-	f.items.ForEach(func(key string, value *Item) bool {
-		x = append(x, value)
-		return true
-	})
+	s := printItems(f.items.Clone())
+	f.locker.Lock()
+	f.data.Reset()
+	f.data.WriteString(s)
+	f.locker.Unlock()
+	return s
+}
+
+// printItems 按key排序，格式化为key=value格式
+func printItems(items map[string]*Item) string {
+	x := make([]*Item, 0, len(items))
+	for _, v := range items {
+		x = append(x, v)
+	}
 	sort.Slice(x, func(i, j int) bool {
 		return x[i].Key < x[j].Key
 	})
-	f.data.Reset()
+	var b strings.Builder
 	for _, v := range x {
-		f.data.WriteString(v.String())
+		b.WriteString(v.String())
 	}
-	return f.data.String()
+	return b.String()
 }
 
 func (f *File) PrintJSON() string {
@@ -176,6 +215,9 @@ func (f *File) FromFile(configfile string) error {
 	if f.filepath == "" {
 		return nil
 	}
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.raw = nil
 	if f.data == nil {
 		f.data = &bytes.Buffer{}
 	} else {
@@ -194,15 +236,76 @@ func (f *File) FromFile(configfile string) error {
 		return nil
 	}
 	f.data.Write(b)
+	x, ft, err := parseItems(b, formatFromExt(f.filepath))
+	if err != nil {
+		return err
+	}
+	f.items.Replace(x)
+	f.raw = b
+	f.formatType = ft
+	return nil
+}
+
+// formatFromExt 依据文件扩展名判断格式
+func formatFromExt(filename string) FormatType {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".json":
+		return JSON
+	case ".toml":
+		return TOML
+	case ".ini":
+		return INI
+	}
+	return KeyValue
+}
+
+// parseItems 解析配置文件内容，返回实际的格式
+//
+//	ft: 依据扩展名判断的格式，toml和ini格式直接解析，其他格式依次尝试json，yaml，key=value格式
+func parseItems(b []byte, ft FormatType) (map[string]*Item, FormatType, error) {
+	switch ft {
+	case TOML:
+		x, err := fromTOML(b)
+		return x, TOML, err
+	case INI:
+		x, err := fromINI(b)
+		return x, INI, err
+	}
 	if b[0] == '{' {
-		if f.fromJSON(b) == nil {
-			return nil
+		if x, err := fromJSON(b); err == nil {
+			return x, JSON, nil
 		}
 	}
-	if f.fromYAML(b) == nil {
-		return nil
+	// 只有注释的key=value文件也能作为yaml解析
+	if x, err := fromYAML(b); err == nil && (len(x) > 0 || ft == YAML) {
+		return x, YAML, nil
 	}
-	ss := strings.Split(f.data.String(), "\n")
+	return fromKeyValue(b), KeyValue, nil
+}
+
+// parseAs 按指定格式解析配置文件内容
+func parseAs(b []byte, ft FormatType) (map[string]*Item, error) {
+	if len(b) == 0 {
+		return make(map[string]*Item), nil
+	}
+	switch ft {
+	case JSON:
+		return fromJSON(b)
+	case YAML:
+		return fromYAML(b)
+	case TOML:
+		return fromTOML(b)
+	case INI:
+		return fromINI(b)
+	}
+	return fromKeyValue(b), nil
+}
+
+func fromKeyValue(b []byte) map[string]*Item {
+	x := make(map[string]*Item)
+	ss := strings.Split(string(b), "\n")
 	tip := make([]string, 0)
 	for _, v := range ss {
 		s := strings.TrimSpace(v)
@@ -216,10 +319,10 @@ func (f *File) FromFile(configfile string) error {
 		if len(it) != 2 {
 			continue
 		}
-		f.items.Store(it[0], &Item{Key: it[0], Value: NewValue(it[1]), Comment: strings.Join(tip, "\n")})
+		x[it[0]] = &Item{Key: it[0], Value: NewValue(it[1]), Comment: strings.Join(tip, "\n")}
 		tip = []string{}
 	}
-	return nil
+	return x
 }
 
 // SaveTo 将配置写入指定文件，依据文件扩展名判断写入格式
@@ -235,54 +338,128 @@ func (f *File) Save() error {
 
 // ToFile 将配置写入文件，依据文件扩展名判断写入格式
 func (f *File) ToFile() error {
-	switch strings.ToLower(filepath.Ext(f.filepath)) {
-	case ".yaml":
-		return f.ToYAML()
-	case ".json":
-		return f.ToJSON()
-	}
-	f.Print()
-	return os.WriteFile(f.filepath, f.data.Bytes(), 0o644)
+	return f.writeFile(formatFromExt(f.filepath))
+}
+
+// Rewrite 重新生成整个文件，不保留原文件的顺序，注释和空行，依据文件扩展名判断写入格式
+//
+//	用于启用SetStrictPatch后Save返回ErrPatch时
+func (f *File) Rewrite() error {
+	f.locker.Lock()
+	f.raw = nil
+	f.locker.Unlock()
+	return f.writeFile(formatFromExt(f.filepath))
+}
+
+// SetStrictPatch 设置为true时，无法在原文件内容上更新配置项的保存会返回ErrPatch，不写入文件，
+// 默认重新生成整个文件，此时原文件的顺序，注释和空行不会保留
+func (f *File) SetStrictPatch(strict bool) {
+	f.locker.Lock()
+	f.strict = strict
+	f.locker.Unlock()
 }
 
 // ToYAML 保存为yaml格式文件
 func (f *File) ToYAML() error {
-	b, err := yaml.Marshal(f.items.Clone())
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(f.filepath, b, 0o644)
+	return f.writeFile(YAML)
 }
 
 // ToJSON 保存为json格式文件
 func (f *File) ToJSON() error {
-	b, err := json.MarshalIndent(f.items.Clone(), "", "  ")
+	return f.writeFile(JSON)
+}
+
+// ToTOML 保存为toml格式文件
+func (f *File) ToTOML() error {
+	return f.writeFile(TOML)
+}
+
+// ToINI 保存为ini格式文件
+func (f *File) ToINI() error {
+	return f.writeFile(INI)
+}
+
+// PrintFormat 以指定格式返回所有配置项
+func (f *File) PrintFormat(ft FormatType) string {
+	f.locker.RLock()
+	b, err := f.marshal(ft)
+	f.locker.RUnlock()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (f *File) writeFile(ft FormatType) error {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	b, err := f.marshal(ft)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(f.filepath, b, 0o644)
+	if err := os.WriteFile(f.filepath, b, 0o644); err != nil {
+		return err
+	}
+	f.raw = b
+	f.formatType = ft
+	return nil
 }
 
-func (f *File) fromYAML(b []byte) error {
+// marshal 将配置格式化为指定格式，调用方需要持有f.locker
+//
+//	格式和原文件相同时，在原文件内容上更新，保持原有的顺序，注释和空行，
+//	无法更新时重新生成，启用SetStrictPatch时返回ErrPatch
+func (f *File) marshal(ft FormatType) ([]byte, error) {
+	if len(f.raw) > 0 && ft == f.formatType {
+		b, err := patchFile(f.raw, ft, f.items.Clone())
+		if err == nil {
+			return b, nil
+		}
+		if f.strict {
+			return nil, fmt.Errorf("%w: %v", ErrPatch, err)
+		}
+	}
+	switch ft {
+	case YAML:
+		return yaml.Marshal(f.items.Clone())
+	case JSON:
+		return json.MarshalIndent(f.items.Clone(), "", "  ")
+	case TOML:
+		return toTOML(f.items.Clone()), nil
+	case INI:
+		return toINI(f.items.Clone()), nil
+	}
+	return []byte(printItems(f.items.Clone())), nil
+}
+
+func fromYAML(b []byte) (map[string]*Item, error) {
 	x := make(map[string]*Item)
 	err := yaml.Unmarshal(b, &x)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for k, v := range x {
-		f.items.Store(k, &Item{Key: k, Value: v.Value, Comment: v.Comment})
+		if v == nil || v.Value == nil {
+			x[k] = &Item{Key: k, Value: NewValue("")}
+			continue
+		}
+		x[k] = &Item{Key: k, Value: v.Value, Comment: v.Comment}
 	}
-	return nil
+	return x, nil
 }
 
-func (f *File) fromJSON(b []byte) error {
+func fromJSON(b []byte) (map[string]*Item, error) {
 	x := make(map[string]*Item)
 	err := json.Unmarshal(b, &x)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for k, v := range x {
-		f.items.Store(k, &Item{Key: k, Value: v.Value, Comment: v.Comment})
+		if v == nil || v.Value == nil {
+			x[k] = &Item{Key: k, Value: NewValue("")}
+			continue
+		}
+		x[k] = &Item{Key: k, Value: v.Value, Comment: v.Comment}
 	}
-	return nil
+	return x, nil
 }
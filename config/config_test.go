@@ -0,0 +1,563 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xyzj/gopsu"
+	"github.com/xyzj/gopsu/crypto"
+	"github.com/xyzj/gopsu/json"
+)
+
+func TestLayer(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.yaml")
+	os.WriteFile(fn, []byte("port:\n  value: 8080\n  comment: listen port\nhost:\n  value: 127.0.0.1\n"), 0o644)
+	f := NewConfig(fn)
+	f.GetDefault(&Item{Key: "debug", Value: NewBoolValue(false)})
+	f.SetEnv("TEST", nil)
+	t.Setenv("TEST_HOST", "0.0.0.0")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.DefineFlags(fs, nil)
+	f.SetFlags(fs, nil)
+	fs.Parse([]string{"-port=9090"})
+
+	for _, x := range []struct {
+		key   string
+		value string
+		layer Layer
+	}{
+		{"port", "9090", LayerFlag},
+		{"host", "0.0.0.0", LayerEnv},
+		{"debug", "false", LayerDefault},
+		{"none", "", LayerNone},
+	} {
+		v, l := f.GetItemLayer(x.key)
+		if v.String() != x.value || l != x.layer {
+			t.Fatalf("%s: got %s from %s, want %s from %s", x.key, v.String(), l, x.value, x.layer)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.conf")
+	os.WriteFile(fn, []byte("level=info\nport=80\n"), 0o644)
+	f := NewConfig(fn)
+	f.GetDefault(&Item{Key: "debug", Value: NewValue("false")})
+	f.Watch(&WatchOpt{
+		Validate: func(nf *File) error {
+			if nf.GetItem("port").TryInt() == 0 {
+				return errors.New("port is required")
+			}
+			return nil
+		},
+	})
+	defer f.StopWatch()
+	changes := make(map[string][2]*Value)
+	f.OnChange(func(key string, old, new *Value) {
+		changes[key] = [2]*Value{old, new}
+	})
+
+	os.WriteFile(fn, []byte("level=debug\n"), 0o644)
+	if err := f.Reload(); err == nil {
+		t.Fatal("validate should fail")
+	}
+	// 编辑器先清空文件再写入
+	os.WriteFile(fn, []byte{}, 0o644)
+	if err := f.Reload(); err == nil {
+		t.Fatal("empty file should fail")
+	}
+	if f.GetItem("level").String() != "info" {
+		t.Fatal("config should not be replaced")
+	}
+
+	os.WriteFile(fn, []byte("level=debug\nport=80\nname=test\n"), 0o644)
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes["level"][1].String() != "debug" || changes["name"][0] != nil {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	if !f.Has("debug") {
+		t.Fatal("default value should be kept")
+	}
+}
+
+func TestReloadConcurrent(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.yaml")
+	os.WriteFile(fn, []byte("a:\n  value: \"1\"\n"), 0o644)
+	f := NewConfig(fn)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			os.WriteFile(fn, []byte("a:\n  value: \""+strconv.Itoa(i)+"\"\n"), 0o644)
+			f.Reload()
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		f.PrintFormat(YAML)
+		f.Print()
+	}
+	<-done
+}
+
+type testConf struct {
+	Level   string        `config:"level" default:"info" enum:"debug,info,warn" comment:"日志等级"`
+	Port    int           `config:"port" required:"true" min:"1" max:"65535" comment:"监听端口"`
+	Name    string        `config:"name" regexp:"^[a-z]+$"`
+	Timeout time.Duration `config:"timeout" default:"30s"`
+	Hosts   []string      `config:"hosts" default:"a,b"`
+	DB      struct {
+		User string `config:"user" required:"true"`
+		Pool uint8  `config:"pool" default:"10" max:"100"`
+	} `config:"db"`
+	Skip string `config:"-"`
+}
+
+func TestBind(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.conf")
+	os.WriteFile(fn, []byte("level=trace\nport=70000\nname=Abc\ndb.pool=x\n"), 0o644)
+	f := NewConfig(fn)
+	c := &testConf{}
+	err := f.Bind(c)
+	var errs BindErrors
+	if !errors.As(err, &errs) || len(errs) != 5 {
+		t.Fatalf("want 5 errors, got %v", err)
+	}
+	// 有错误时不修改任何字段
+	if c.Level != "" || c.Timeout != 0 || c.Hosts != nil {
+		t.Fatalf("fields changed on error %+v", c)
+	}
+
+	os.WriteFile(fn, []byte("port=8080\nname=abc\ndb.user=root\n"), 0o644)
+	f.FromFile(fn)
+	c = &testConf{}
+	if err := f.Bind(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "info" || c.Port != 8080 || c.Timeout != time.Second*30 || len(c.Hosts) != 2 || c.DB.User != "root" || c.DB.Pool != 10 {
+		t.Fatalf("unexpected result %+v", c)
+	}
+	// 时长和TryDuration一样支持单位为秒的数字
+	f.PutItem(&Item{Key: "timeout", Value: NewValue("90")})
+	if err := f.Bind(c); err != nil || c.Timeout != f.GetItem("timeout").TryDuration() || c.Timeout != 90*time.Second {
+		t.Fatalf("duration %v %v", c.Timeout, err)
+	}
+
+	fn = filepath.Join(t.TempDir(), "default.conf")
+	if err := WriteDefault(&testConf{Port: 80}, fn); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(fn)
+	s := string(b)
+	if !strings.Contains(s, "# 日志等级\n# 可选值: debug,info,warn\nlevel=info") || !strings.Contains(s, "port=80") {
+		t.Fatalf("unexpected default file:\n%s", s)
+	}
+}
+
+func TestTOMLINI(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.toml")
+	os.WriteFile(fn, []byte("# 名称\nname = \"demo\"\nport = 8080\n\n[db]\n# 数据库地址\nhost = \"127.0.0.1\"\nratio = 1.0\nhosts = [\"a\", \"b\"]\n"), 0o644)
+	f := NewConfig(fn)
+	if f.GetItem("db.host").String() != "127.0.0.1" || f.GetItem("port").TryInt() != 8080 || f.GetItem("db.hosts").String() != "a,b" {
+		t.Fatalf("unexpected toml items %s", f.Print())
+	}
+	for _, fn := range []string{filepath.Join(dir, "test2.toml"), filepath.Join(dir, "test.ini")} {
+		if err := f.SaveTo(fn); err != nil {
+			t.Fatal(err)
+		}
+		nf := NewConfig(fn)
+		if nf.Len() != f.Len() {
+			t.Fatalf("%s: want %d items, got %d", fn, f.Len(), nf.Len())
+		}
+		nf.ForEach(func(key string, value *Value) bool {
+			if f.GetItem(key).String() != value.String() {
+				t.Fatalf("%s: %s want %s, got %s", fn, key, f.GetItem(key).String(), value.String())
+			}
+			return true
+		})
+		if c, _ := nf.items.Load("db.host"); c.Comment != "数据库地址" {
+			t.Fatalf("%s: comment lost", fn)
+		}
+	}
+
+	type server struct {
+		Host  string   `yaml:"host"`
+		Port  int      `yaml:"port"`
+		Code  string   `yaml:"code"`
+		Tags  []string `yaml:"tags"`
+		Debug bool     `yaml:"debug"`
+	}
+	ff := NewFormatFile[server](filepath.Join(dir, "servers.ini"), INI)
+	ff.PutItem("main", &server{Host: "localhost", Port: 80, Code: "001", Tags: []string{"a", "b"}, Debug: true})
+	ff.PutItem("backup", &server{Host: "10.0.0.1 #2", Port: 81})
+	for _, ft := range []FormatType{JSON, YAML, TOML, INI} {
+		s := ff.PrintFormat(ft)
+		fn := filepath.Join(dir, "servers")
+		os.WriteFile(fn, []byte(s), 0o644)
+		nf := NewFormatFile[server](fn, ft)
+		for _, k := range []string{"main", "backup"} {
+			a, _ := ff.GetItem(k)
+			b, ok := nf.GetItem(k)
+			if !ok || a.Host != b.Host || a.Port != b.Port || a.Code != b.Code || len(a.Tags) != len(b.Tags) || a.Debug != b.Debug {
+				t.Fatalf("format %d: %s want %+v, got %+v\n%s", ft, k, a, b, s)
+			}
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	dir := t.TempDir()
+	keyfile := filepath.Join(dir, "master.key")
+	if _, err := GenerateSecretKey(crypto.AES256GCM, keyfile); err != nil {
+		t.Fatal(err)
+	}
+	box, err := LoadSecretBox(crypto.AES256GCM, keyfile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, _ := GenerateSecretKey(crypto.SM4GCM, "")
+	t.Setenv("TEST_MASTER_KEY", key2)
+	box2, err := LoadSecretBox(crypto.SM4GCM, keyfile, "TEST_MASTER_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := box.Encrypt("p@ssw0rd")
+	if !IsSecret(s) {
+		t.Fatalf("bad secret %s", s)
+	}
+	if x, err := box.Decrypt(s); err != nil || x != "p@ssw0rd" {
+		t.Fatalf("decrypt failed %s %v", x, err)
+	}
+	if _, err := box2.Decrypt(s); err == nil {
+		t.Fatal("decrypt with wrong key should fail")
+	}
+	if _, err := box.Decrypt(s[:len(s)-2] + "AA"); err == nil {
+		t.Fatal("decrypt tampered secret should fail")
+	}
+	if x, _ := box.Decrypt(gopsu.CodeString("legacy")); x != "legacy" {
+		t.Fatal("legacy value should be decoded")
+	}
+
+	SetSecretBox(box)
+	defer SetSecretBox(nil)
+	b, _ := json.Marshal(&struct{ P PwdString }{"secret"})
+	var p struct{ P PwdString }
+	if !strings.Contains(string(b), secretPrefix) || json.Unmarshal(b, &p) != nil || p.P != "secret" {
+		t.Fatalf("pwdstring failed %s", b)
+	}
+
+	f := NewConfig(filepath.Join(dir, "test.conf"))
+	f.PutItem(&Item{Key: "a", Value: NewCodeValue("aaa")})
+	f.PutItem(&Item{Key: "b", Value: NewValue(gopsu.CodeString("bbb"))})
+	f.PutItem(&Item{Key: "c", Value: NewValue("ccc")})
+	if n, err := f.RotateSecrets(box2, box2, "b"); err == nil || n != 0 {
+		t.Fatal("rotate with wrong old key should fail")
+	}
+	if n, err := f.RotateSecrets(box, box2, "b"); err != nil || n != 2 {
+		t.Fatalf("rotate failed %d %v", n, err)
+	}
+	SetSecretBox(box2)
+	for k, v := range map[string]string{"a": "aaa", "b": "bbb"} {
+		if x := f.GetItem(k).TryDecode(); x != v {
+			t.Fatalf("%s: want %s, got %s", k, v, x)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, x := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"test.conf",
+			"# 手工注释\n\n# 端口\nport=80\n\n# 废弃\nold=1\nhost=a\n",
+			"# 手工注释\n\n# 端口\nport=8080\n\nhost=a\n\n# 新增\nname=demo\n",
+		},
+		{
+			"test.ini",
+			"; 文件说明\nport = 80 ; 行尾注释\nold=1\n\n[db]\n# 地址\nhost = \"a b\"\n",
+			"; 文件说明\nport = 8080 ; 行尾注释\n# 新增\nname=demo\n\n[db]\n# 地址\nhost = \"c d\"\n",
+		},
+		{
+			"test.toml",
+			"# 文件说明\nport = 80 # 行尾注释\nold = 1\n\n[db]\n# 地址\nhost = \"a\"\nlist = [\n  1,\n  2,\n]\n",
+			"# 文件说明\nport = 8080 # 行尾注释\n# 新增\nname = \"demo\"\n\n[db]\n# 地址\nhost = \"c d\"\nlist = [\n  1,\n  2,\n]\n",
+		},
+		{
+			"test.yaml",
+			"# 文件说明\nport:\n  value: 80 # 行尾注释\n\n# 废弃\nold:\n  value: 1\ndb.host:\n  value: 'a'\n  comment: 地址\n",
+			"# 文件说明\nport:\n  value: 8080 # 行尾注释\n\ndb.host:\n  value: c d\n  comment: 地址\nname:\n  value: demo\n  comment: 新增\n",
+		},
+		{
+			"test.json",
+			"{\n  \"port\": {\"value\": 80, \"comment\": \"\"},\n  \"old\": {\"value\": 1},\n  \"db.host\": {\"value\": \"a\"}\n}\n",
+			"{\n  \"port\": {\"value\": 8080, \"comment\": \"\"},\n  \"db.host\": {\"value\": \"c d\"},\n  \"name\": {\"value\":\"demo\",\"comment\":\"新增\"}\n}\n",
+		},
+	} {
+		fn := filepath.Join(dir, x.name)
+		os.WriteFile(fn, []byte(x.src), 0o644)
+		f := NewConfig(fn)
+		f.PutItem(&Item{Key: "port", Value: NewInt64Value(8080)})
+		if f.Has("db.host") {
+			f.PutItem(&Item{Key: "db.host", Value: NewValue("c d")})
+		}
+		f.DelItem("old")
+		f.PutItem(&Item{Key: "name", Value: NewValue("demo"), Comment: "新增"})
+		if err := f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(fn)
+		if string(b) != x.want {
+			t.Fatalf("%s: unexpected content:\n%s", x.name, b)
+		}
+	}
+}
+
+func TestTOMLKeyConflict(t *testing.T) {
+	items := map[string]*Item{
+		"x":     {Key: "x", Value: NewInt64Value(1)},
+		"x.y":   {Key: "x.y", Value: NewInt64Value(2), Comment: "嵌套"},
+		"x.y.z": {Key: "x.y.z", Value: NewValue("3")},
+		"a.b":   {Key: "a.b", Value: NewMapValue(map[string]*Value{"c": NewInt64Value(4)})},
+		"a.b.d": {Key: "a.b.d", Value: NewBoolValue(true)},
+		"m.n":   {Key: "m.n", Value: NewValue("5")},
+	}
+	b := toTOML(items)
+	x, err := fromTOML(b)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, b)
+	}
+	for k, v := range items {
+		if v.Value.t == tmap {
+			// toml的inline table按表展开
+			k, v = k+".c", &Item{Value: NewInt64Value(4)}
+		}
+		if nv, ok := x[k]; !ok || !equalValue(nv.Value, v.Value) || nv.Comment != v.Comment {
+			t.Fatalf("%s mismatch\n%s", k, b)
+		}
+	}
+	if !strings.Contains(string(b), "[m]\n") {
+		t.Fatalf("table without conflict should be kept\n%s", b)
+	}
+
+	// 在原文件上新增冲突的配置项
+	fn := filepath.Join(t.TempDir(), "test.toml")
+	os.WriteFile(fn, []byte("x = 1\n\n[m]\nn = 5\n"), 0o644)
+	f := NewConfig(fn)
+	f.PutItem(&Item{Key: "x.y", Value: NewInt64Value(2)})
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if nf := NewConfig(fn); nf.GetItem("x.y").TryInt() != 2 || nf.GetItem("x").TryInt() != 1 {
+		t.Fatal(nf.Print())
+	}
+}
+
+func TestININested(t *testing.T) {
+	items := map[string]*Item{
+		"servers": {Key: "servers", Value: NewListValue(NewValue("a b"), NewMapValue(map[string]*Value{"port": NewInt64Value(80)}))},
+		"db.pool": {Key: "db.pool", Value: NewMapValue(map[string]*Value{"max": NewInt64Value(10), "hosts": NewListValue(NewValue("x"), NewValue("y"))})},
+		"db.name": {Key: "db.name", Value: NewValue("[not a list]")},
+		"hosts":   {Key: "hosts", Value: NewValue("a,b")},
+	}
+	check := func(x map[string]*Item, b []byte) {
+		t.Helper()
+		if len(x) != len(items) {
+			t.Fatalf("want %d items, got %d\n%s", len(items), len(x), b)
+		}
+		for k, v := range items {
+			if nv, ok := x[k]; !ok || nv.Value.t != v.Value.t || !equalValue(nv.Value, v.Value) {
+				t.Fatalf("%s mismatch\n%s", k, b)
+			}
+		}
+	}
+	b := toINI(items)
+	x, err := fromINI(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(x, b)
+
+	// 在原文件上更新
+	fn := filepath.Join(t.TempDir(), "test.ini")
+	os.WriteFile(fn, []byte("servers = \"x\"\n\n[db]\nname = a\n"), 0o644)
+	f := NewConfig(fn)
+	for _, it := range items {
+		f.PutItem(it)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = os.ReadFile(fn)
+	x, err = fromINI(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(x, b)
+}
+
+func TestPatchComment(t *testing.T) {
+	dir := t.TempDir()
+	for _, x := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"test.conf",
+			"# 端口\nport=80\nhost=a\n",
+			"# 监听端口\nport=80\n# 主机\nhost=a\n",
+		},
+		{
+			"test.toml",
+			"# 端口\nport = 80\nhost = \"a\"\n",
+			"# 监听端口\nport = 80\n# 主机\nhost = \"a\"\n",
+		},
+		{
+			"test.yaml",
+			"port:\n    value: 80 # 行尾注释\n    comment: 端口\nhost:\n    value: a\n\n# 结尾\n",
+			"port:\n    value: 80 # 行尾注释\n    comment: 监听端口\nhost:\n    value: a\n    comment: 主机\n\n# 结尾\nname:\n    value: demo\n    comment: \"\"\n",
+		},
+		{
+			"test.json",
+			"{\n  \"port\": {\"value\": 80, \"comment\": \"端口\"},\n  \"host\": {\"value\": \"a\"}\n}\n",
+			"{\n  \"port\": {\"value\": 80, \"comment\": \"监听端口\"},\n  \"host\": {\"value\": \"a\",\"comment\":\"主机\"}\n}\n",
+		},
+	} {
+		fn := filepath.Join(dir, x.name)
+		os.WriteFile(fn, []byte(x.src), 0o644)
+		f := NewConfig(fn)
+		f.PutItem(&Item{Key: "port", Value: NewValue("80"), Comment: "监听端口"})
+		f.PutItem(&Item{Key: "host", Value: NewValue("a"), Comment: "主机"})
+		if x.name == "test.yaml" {
+			f.PutItem(&Item{Key: "name", Value: NewValue("demo")})
+		}
+		if err := f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(fn)
+		if string(b) != x.want {
+			t.Fatalf("%s: unexpected content:\n%s", x.name, b)
+		}
+	}
+
+	// 启用SetStrictPatch后无法更新时返回错误，不会重新生成文件
+	fn := filepath.Join(dir, "flow.yaml")
+	os.WriteFile(fn, []byte("{port: {value: 80}}\n"), 0o644)
+	f := NewConfig(fn)
+	f.SetStrictPatch(true)
+	f.PutItem(&Item{Key: "port", Value: NewValue("8080")})
+	if err := f.Save(); !errors.Is(err, ErrPatch) {
+		t.Fatalf("want ErrPatch, got %v", err)
+	}
+	if b, _ := os.ReadFile(fn); string(b) != "{port: {value: 80}}\n" {
+		t.Fatalf("file should not be changed: %s", b)
+	}
+	if err := f.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	if nf := NewConfig(fn); nf.GetItem("port").String() != "8080" {
+		t.Fatal("rewrite failed")
+	}
+}
+
+// TestPatchFallback 无法在原文件上更新时默认重新生成整个文件
+func TestPatchFallback(t *testing.T) {
+	dir := t.TempDir()
+	for _, x := range []struct {
+		name, src, key, value string
+	}{
+		// 重复的配置项
+		{"dup.conf", "a=1\na=2\n", "a", "3"},
+		// 根节点为flow格式
+		{"flow.yaml", "{a: {value: 1}, b: {value: x}}\n", "b", "y"},
+		// 值中包含`=`，读取时会被忽略，无法校验
+		{"eq.conf", "a=1\n", "url", "http://h/?a=1&b=2"},
+	} {
+		fn := filepath.Join(dir, x.name)
+		os.WriteFile(fn, []byte(x.src), 0o644)
+		f := NewConfig(fn)
+		f.PutItem(&Item{Key: x.key, Value: NewValue(x.value)})
+		if err := f.Save(); err != nil {
+			t.Fatalf("%s: %v", x.name, err)
+		}
+		f.PutItem(&Item{Key: "new", Value: NewValue("v")})
+		if err := f.Save(); err != nil {
+			t.Fatalf("%s: %v", x.name, err)
+		}
+		b, _ := os.ReadFile(fn)
+		nf := NewConfig(fn)
+		if nf.GetItem("new").String() != "v" || nf.GetItem("a").String() == "" {
+			t.Fatalf("%s: unexpected content:\n%s", x.name, b)
+		}
+		if x.name != "eq.conf" && nf.GetItem(x.key).String() != x.value {
+			t.Fatalf("%s: unexpected content:\n%s", x.name, b)
+		}
+		if x.name == "eq.conf" && !strings.Contains(string(b), "url="+x.value+"\n") {
+			t.Fatalf("%s: unexpected content:\n%s", x.name, b)
+		}
+	}
+}
+
+func TestNested(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.yaml")
+	os.WriteFile(fn, []byte(`db:
+  value:
+    master:
+      host: 10.0.0.1
+      ports: [3306, 3307]
+    timeout: 1m30s
+  comment: 数据库
+blacklist:
+  value: 1.1.1.1,2.2.2.2
+`), 0o644)
+	f := NewConfig(fn)
+	if v := f.GetItem("db.master.host"); v.String() != "10.0.0.1" {
+		t.Fatalf("db.master.host: got %s", v.String())
+	}
+	if v := f.GetItem("db.master.ports.1"); v.TryInt() != 3307 {
+		t.Fatalf("db.master.ports.1: got %s", v.String())
+	}
+	if ns := f.GetItem("db.master.ports").TryIntSlice(); len(ns) != 2 || ns[0] != 3306 {
+		t.Fatalf("ports: got %v", ns)
+	}
+	if d := f.GetItem("db.timeout").TryDuration(); d != time.Second*90 {
+		t.Fatalf("timeout: got %v", d)
+	}
+	if m := f.GetItem("db.master").TryMap(); len(m) != 2 || m["host"].String() != "10.0.0.1" {
+		t.Fatalf("master: got %v", m)
+	}
+	if ss := f.GetItem("blacklist").TryStringSlice(); len(ss) != 2 || ss[1] != "2.2.2.2" {
+		t.Fatalf("blacklist: got %v", ss)
+	}
+	if f.Has("db.master.user") || !f.Has("db.master") {
+		t.Fatal("has nested failed")
+	}
+	if NewValue("90").TryDuration() != time.Second*90 {
+		t.Fatal("duration in seconds failed")
+	}
+
+	f.PutItem(&Item{Key: "servers", Value: NewListValue(NewValue("a"), NewMapValue(map[string]*Value{"b": NewInt64Value(1)}))})
+	for _, name := range []string{"copy.yaml", "copy.json", "copy.toml"} {
+		if err := f.SaveTo(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		nf := NewConfig(filepath.Join(dir, name))
+		if nf.GetItem("db.master.ports.0").TryInt() != 3306 || nf.GetItem("servers.1.b").TryInt() != 1 {
+			t.Fatalf("%s: nested shape lost\n%s", name, nf.PrintFormat(JSON))
+		}
+	}
+}
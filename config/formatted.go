@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 
+	"github.com/pelletier/go-toml/v2"
 	"github.com/xyzj/gopsu/json"
 	"github.com/xyzj/gopsu/mapfx"
 	"gopkg.in/yaml.v3"
@@ -15,9 +16,15 @@ const (
 	KeyValue FormatType = iota
 	JSON
 	YAML
+	// TOML 表中的配置项名称为 表名称.配置项名称
+	TOML
+	// INI 配置段中的配置项名称为 段名称.配置项名称
+	INI
 )
 
-// Formatted yaml/json 格式化配置文件
+// Formatted yaml/json/toml/ini 格式化配置文件
+//
+//	toml和ini格式通过yaml转换，字段名称使用yaml tag
 type Formatted[ITEM any] struct {
 	items      *mapfx.StructMap[string, ITEM]
 	data       []byte
@@ -25,7 +32,7 @@ type Formatted[ITEM any] struct {
 	formatType FormatType
 }
 
-// NewFormatFile 创建一个新的自定义结构的yaml/json配置文件
+// NewFormatFile 创建一个新的自定义结构的yaml/json/toml/ini配置文件
 func NewFormatFile[ITEM any](configfile string, ft FormatType) *Formatted[ITEM] {
 	y := &Formatted[ITEM]{filepath: configfile, formatType: ft, items: mapfx.NewStructMap[string, ITEM]()}
 	y.FromFile("")
@@ -71,24 +78,28 @@ func (f *Formatted[ITEM]) Has(key string) bool {
 
 // Print 返回所有配置项
 func (f *Formatted[ITEM]) Print() string {
-	switch f.formatType {
-	case YAML:
-		f.toYAML()
-	case JSON:
-		f.toJSON()
-	}
+	f.marshal(f.formatType)
 	return string(f.data)
 }
 
-// PrintFormat 返回所有配置项
+// PrintFormat 以指定格式返回所有配置项，可用于格式转换
 func (f *Formatted[ITEM]) PrintFormat(fmt FormatType) string {
-	switch fmt {
+	f.marshal(fmt)
+	return string(f.data)
+}
+
+func (f *Formatted[ITEM]) marshal(ft FormatType) error {
+	switch ft {
 	case YAML:
-		f.toYAML()
+		return f.toYAML()
 	case JSON:
-		f.toJSON()
+		return f.toJSON()
+	case TOML:
+		return f.toTOML()
+	case INI:
+		return f.toINI()
 	}
-	return string(f.data)
+	return nil
 }
 
 // FromFile 从文件读取配置
@@ -110,17 +121,18 @@ func (f *Formatted[ITEM]) FromFile(configfile string) error {
 		return f.fromYAML(b)
 	case JSON:
 		return f.fromJSON(b)
+	case TOML:
+		return f.fromTOML(b)
+	case INI:
+		return f.fromINI(b)
 	}
 	return nil
 }
 
 // ToFile 写入文件
 func (f *Formatted[ITEM]) ToFile() error {
-	switch f.formatType {
-	case YAML:
-		f.toYAML()
-	case JSON:
-		f.toJSON()
+	if err := f.marshal(f.formatType); err != nil {
+		return err
 	}
 	return os.WriteFile(f.filepath, f.data, 0644)
 }
@@ -171,3 +183,75 @@ func (f *Formatted[ITEM]) toJSON() error {
 	f.data = b
 	return nil
 }
+
+// fromTOML 从toml文件读取
+func (f *Formatted[ITEM]) fromTOML(b []byte) error {
+	m := make(map[string]any)
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	yb, err := yaml.Marshal(normalizeTOML(m))
+	if err != nil {
+		return err
+	}
+	x := make(map[string]*ITEM)
+	if err := yaml.Unmarshal(yb, &x); err != nil {
+		return err
+	}
+	for k, v := range x {
+		f.items.Store(k, v)
+	}
+	return nil
+}
+
+// fromINI 从ini文件读取
+func (f *Formatted[ITEM]) fromINI(b []byte) error {
+	n, err := iniToNode(b)
+	if err != nil {
+		return err
+	}
+	x := make(map[string]*ITEM)
+	if err := n.Decode(&x); err != nil {
+		return err
+	}
+	for k, v := range x {
+		f.items.Store(k, v)
+	}
+	return nil
+}
+
+// toTOML 写入toml文件
+func (f *Formatted[ITEM]) toTOML() error {
+	yb, err := yaml.Marshal(f.items.Clone())
+	if err != nil {
+		return err
+	}
+	m := make(map[string]any)
+	if err := yaml.Unmarshal(yb, &m); err != nil {
+		return err
+	}
+	b, err := toml.Marshal(dropNil(m))
+	if err != nil {
+		return err
+	}
+	f.data = b
+	return nil
+}
+
+// toINI 写入ini文件
+func (f *Formatted[ITEM]) toINI() error {
+	yb, err := yaml.Marshal(f.items.Clone())
+	if err != nil {
+		return err
+	}
+	n := &yaml.Node{}
+	if err := yaml.Unmarshal(yb, n); err != nil {
+		return err
+	}
+	b, err := nodeToINI(n)
+	if err != nil {
+		return err
+	}
+	f.data = b
+	return nil
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// iniKey ini配置项
+type iniKey struct {
+	key     string
+	value   string
+	comment string
+	quoted  bool
+}
+
+// iniSection ini配置段，name为空表示第一个段之前的配置项
+type iniSection struct {
+	name    string
+	comment string
+	keys    []*iniKey
+}
+
+// iniDoc ini文件内容，保持配置段和配置项的顺序
+type iniDoc struct {
+	sections []*iniSection
+}
+
+func newINIDoc() *iniDoc {
+	return &iniDoc{
+		sections: []*iniSection{{name: ""}},
+	}
+}
+
+// section 获取或添加一个配置段
+func (d *iniDoc) section(name string) *iniSection {
+	for _, s := range d.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	s := &iniSection{name: name}
+	d.sections = append(d.sections, s)
+	return s
+}
+
+// parseINI 解析ini内容
+//
+//	支持`#`和`;`注释，`key=value`或`key: value`，值可以使用单引号或双引号，未使用引号的值支持行尾注释
+func parseINI(b []byte) (*iniDoc, error) {
+	d := newINIDoc()
+	sec := d.sections[0]
+	tip := make([]string, 0)
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	for i, line := range strings.Split(string(b), "\n") {
+		s := strings.TrimSpace(line)
+		switch {
+		case s == "":
+			continue
+		case s[0] == '#' || s[0] == ';':
+			if xt := strings.TrimSpace(s[1:]); xt != "" {
+				tip = append(tip, xt)
+			}
+			continue
+		case s[0] == '[':
+			if !strings.HasSuffix(s, "]") {
+				return nil, fmt.Errorf("ini: line %d: bad section %q", i+1, s)
+			}
+			sec = d.section(strings.TrimSpace(s[1 : len(s)-1]))
+			sec.comment = strings.Join(tip, "\n")
+			tip = []string{}
+			continue
+		}
+		idx := strings.IndexAny(s, "=:")
+		if idx <= 0 {
+			return nil, fmt.Errorf("ini: line %d: bad key value %q", i+1, s)
+		}
+		k := &iniKey{
+			key:     strings.TrimSpace(s[:idx]),
+			comment: strings.Join(tip, "\n"),
+		}
+		k.value, k.quoted = unquoteINI(strings.TrimSpace(s[idx+1:]))
+		sec.keys = append(sec.keys, k)
+		tip = []string{}
+	}
+	return d, nil
+}
+
+// unquoteINI 去掉值的引号和行尾注释
+func unquoteINI(s string) (string, bool) {
+	if len(s) >= 2 {
+		switch s[0] {
+		case '"':
+			if q, err := strconv.QuotedPrefix(s); err == nil {
+				if x, err := strconv.Unquote(q); err == nil {
+					return x, true
+				}
+			}
+		case '\'':
+			if idx := strings.IndexByte(s[1:], '\''); idx >= 0 {
+				return s[1 : idx+1], true
+			}
+		}
+	}
+	for i := 1; i < len(s); i++ {
+		if (s[i] == '#' || s[i] == ';') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i]), false
+		}
+	}
+	return s, false
+}
+
+// quoteINI 值包含首尾空格，注释符号或以引号开头时，使用双引号
+func quoteINI(s string, force bool) string {
+	if force || s != strings.TrimSpace(s) ||
+		strings.Contains(s, " #") || strings.Contains(s, " ;") ||
+		strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") ||
+		strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Bytes 格式化为ini内容
+func (d *iniDoc) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	writeComment := func(c string) {
+		if c == "" {
+			return
+		}
+		for _, v := range strings.Split(c, "\n") {
+			if strings.HasPrefix(v, "#") || strings.HasPrefix(v, ";") {
+				buf.WriteString(v + "\n")
+			} else {
+				buf.WriteString("# " + v + "\n")
+			}
+		}
+	}
+	for _, sec := range d.sections {
+		if sec.name == "" && len(sec.keys) == 0 {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if sec.name != "" {
+			writeComment(sec.comment)
+			buf.WriteString("[" + sec.name + "]\n")
+		}
+		for _, k := range sec.keys {
+			writeComment(k.comment)
+			buf.WriteString(k.key + "=" + quoteINI(k.value, k.quoted) + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// splitKey 将配置项名称拆分为段名称和配置项名称，使用最后一个`.`分割
+func splitKey(key string) (string, string) {
+	if idx := strings.LastIndexByte(key, '.'); idx > 0 && idx < len(key)-1 {
+		return key[:idx], key[idx+1:]
+	}
+	return "", key
+}
+
+// fromINI 解析ini内容，配置段中的配置项名称为 段名称.配置项名称
+func fromINI(b []byte) (map[string]*Item, error) {
+	d, err := parseINI(b)
+	if err != nil {
+		return nil, err
+	}
+	x := make(map[string]*Item)
+	for _, sec := range d.sections {
+		for _, k := range sec.keys {
+			key := k.key
+			if sec.name != "" {
+				key = sec.name + "." + k.key
+			}
+			x[key] = &Item{Key: key, Value: iniToValue(k), Comment: k.comment}
+		}
+	}
+	return x, nil
+}
+
+// toINI 格式化为ini内容，名称中包含`.`的配置项，最后一个`.`之前的部分作为段名称，列表和map使用yaml flow格式
+func toINI(items map[string]*Item) []byte {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	d := newINIDoc()
+	for _, k := range keys {
+		sn, kn := splitKey(k)
+		sec := d.section(sn)
+		value, quoted := iniValue(items[k].Value)
+		sec.keys = append(sec.keys, &iniKey{
+			key:     kn,
+			value:   value,
+			quoted:  quoted,
+			comment: items[k].Comment,
+		})
+	}
+	return d.Bytes()
+}
+
+// iniValue 格式化ini的值，列表和map使用yaml flow格式，以`[`或`{`开头的字符串需要使用引号
+func iniValue(v *Value) (string, bool) {
+	switch v.t {
+	case tlist, tmap:
+		n := &yaml.Node{}
+		if err := n.Encode(v); err == nil {
+			setFlow(n)
+			if b, err := yaml.Marshal(n); err == nil {
+				return strings.TrimSpace(string(b)), false
+			}
+		}
+	case tstr:
+		return v.nstr, strings.HasPrefix(v.nstr, "[") || strings.HasPrefix(v.nstr, "{")
+	}
+	return v.String(), false
+}
+
+// iniToValue 未使用引号，以`[`或`{`开头的值按yaml flow格式解析为列表或map
+func iniToValue(k *iniKey) *Value {
+	if !k.quoted && (strings.HasPrefix(k.value, "[") || strings.HasPrefix(k.value, "{")) {
+		v := &Value{}
+		if err := yaml.Unmarshal([]byte(k.value), v); err == nil && (v.t == tlist || v.t == tmap) {
+			return v
+		}
+	}
+	return NewValue(k.value)
+}
+
+// iniToNode 将ini内容转换为yaml节点，配置段转换为嵌套的map，用于解析自定义结构
+//
+//	未使用引号的值按yaml规则推断类型，以`[`或`{`开头的值按yaml flow格式解析
+func iniToNode(b []byte) (*yaml.Node, error) {
+	d, err := parseINI(b)
+	if err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	child := func(m *yaml.Node, key string) *yaml.Node {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if m.Content[i].Value == key && m.Content[i+1].Kind == yaml.MappingNode {
+				return m.Content[i+1]
+			}
+		}
+		c := &yaml.Node{Kind: yaml.MappingNode}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, c)
+		return c
+	}
+	for _, sec := range d.sections {
+		m := root
+		if sec.name != "" {
+			for _, s := range strings.Split(sec.name, ".") {
+				m = child(m, strings.TrimSpace(s))
+			}
+		}
+		for _, k := range sec.keys {
+			v := &yaml.Node{Kind: yaml.ScalarNode, Value: k.value}
+			switch {
+			case k.quoted:
+				v.Style = yaml.DoubleQuotedStyle
+			case strings.HasPrefix(k.value, "[") || strings.HasPrefix(k.value, "{"):
+				x := &yaml.Node{}
+				if err := yaml.Unmarshal([]byte(k.value), x); err == nil && len(x.Content) > 0 {
+					v = x.Content[0]
+				}
+			}
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k.key}, v)
+		}
+	}
+	return root, nil
+}
+
+// nodeToINI 将yaml节点转换为ini内容，嵌套的map转换为配置段，数组使用yaml flow格式
+func nodeToINI(n *yaml.Node) ([]byte, error) {
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return []byte{}, nil
+		}
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("ini: need a map, got %v", n.Tag)
+	}
+	d := newINIDoc()
+	var walk func(name string, m *yaml.Node) error
+	walk = func(name string, m *yaml.Node) error {
+		sec := d.section(name)
+		subs := make([][2]*yaml.Node, 0)
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			k, v := m.Content[i], m.Content[i+1]
+			switch v.Kind {
+			case yaml.MappingNode:
+				subs = append(subs, [2]*yaml.Node{k, v})
+			case yaml.ScalarNode:
+				ik := &iniKey{key: k.Value, value: v.Value}
+				switch {
+				case v.ShortTag() == "!!null":
+					ik.value = ""
+				case v.ShortTag() == "!!str":
+					// 字符串需要保持原类型
+					plain := &yaml.Node{Kind: yaml.ScalarNode, Value: v.Value}
+					ik.quoted = plain.ShortTag() != "!!str" || strings.HasPrefix(v.Value, "[") || strings.HasPrefix(v.Value, "{")
+				}
+				sec.keys = append(sec.keys, ik)
+			default:
+				setFlow(v)
+				b, err := yaml.Marshal(v)
+				if err != nil {
+					return err
+				}
+				sec.keys = append(sec.keys, &iniKey{key: k.Value, value: strings.TrimSpace(string(b))})
+			}
+		}
+		for _, s := range subs {
+			sn := s[0].Value
+			if name != "" {
+				sn = name + "." + sn
+			}
+			if err := walk(sn, s[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("", n); err != nil {
+		return nil, err
+	}
+	return d.Bytes(), nil
+}
+
+func setFlow(n *yaml.Node) {
+	n.Style = yaml.FlowStyle
+	for _, c := range n.Content {
+		setFlow(c)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// Layer 配置值的来源，优先级由低到高
+type Layer byte
+
+const (
+	// LayerNone 配置不存在
+	LayerNone Layer = iota
+	// LayerDefault 来自GetDefault设置的默认值
+	LayerDefault
+	// LayerFile 来自配置文件或PutItem
+	LayerFile
+	// LayerEnv 来自环境变量
+	LayerEnv
+	// LayerFlag 来自命令行参数
+	LayerFlag
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerDefault:
+		return "default"
+	case LayerFile:
+		return "file"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	default:
+		return "none"
+	}
+}
+
+var envReplacer = strings.NewReplacer(".", "_", "-", "_", " ", "_")
+
+// EnvName 默认的配置项名称到环境变量名称的转换
+//
+//	转为大写，`.`，`-`，空格替换为`_`，并添加前缀，如：prefix=APP，key=db.host，返回 APP_DB_HOST
+func EnvName(prefix, key string) string {
+	key = strings.ToUpper(envReplacer.Replace(key))
+	if prefix == "" {
+		return key
+	}
+	return strings.ToUpper(prefix) + "_" + key
+}
+
+// SetEnv 启用环境变量覆盖配置，环境变量的优先级高于配置文件
+//
+//	prefix: 环境变量前缀，如：APP，则配置项 db_host 对应环境变量 APP_DB_HOST
+//	mapper: 自定义配置项名称到环境变量名称的转换，为nil时使用EnvName
+func (f *File) SetEnv(prefix string, mapper func(key string) string) {
+	if mapper == nil {
+		mapper = func(key string) string {
+			return EnvName(prefix, key)
+		}
+	}
+	f.envMapper = mapper
+}
+
+// SetFlags 启用命令行参数覆盖配置，命令行参数的优先级最高，只有在命令行中明确设置的参数有效
+//
+//	fs: 参数集合，为nil时使用flag.CommandLine
+//	mapper: 自定义配置项名称到参数名称的转换，为nil时参数名称和配置项名称相同
+func (f *File) SetFlags(fs *flag.FlagSet, mapper func(key string) string) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	if mapper == nil {
+		mapper = func(key string) string {
+			return key
+		}
+	}
+	f.flags = fs
+	f.flagMapper = mapper
+}
+
+// DefineFlags 为所有已有配置项定义字符串类型的命令行参数，需要在fs.Parse()之前调用
+//
+//	参数的默认值和说明分别为配置的值和注释，已经定义的参数会被跳过
+func (f *File) DefineFlags(fs *flag.FlagSet, mapper func(key string) string) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	if mapper == nil {
+		mapper = func(key string) string {
+			return key
+		}
+	}
+	f.items.ForEach(func(key string, value *Item) bool {
+		name := mapper(key)
+		if fs.Lookup(name) == nil {
+			fs.String(name, value.Value.String(), value.Comment)
+		}
+		return true
+	})
+}
+
+// overlay 从命令行参数和环境变量中查找配置值
+func (f *File) overlay(key string) (*Value, Layer) {
+	if f.flags != nil && f.flags.Parsed() {
+		name := f.flagMapper(key)
+		var v *Value
+		f.flags.Visit(func(fl *flag.Flag) {
+			if fl.Name == name {
+				v = NewValue(fl.Value.String())
+			}
+		})
+		if v != nil {
+			return v, LayerFlag
+		}
+	}
+	if f.envMapper != nil {
+		if s, ok := os.LookupEnv(f.envMapper(key)); ok {
+			return NewValue(s), LayerEnv
+		}
+	}
+	return nil, LayerNone
+}
+
+// GetItemLayer 获取一个配置值及其来源
+//
+//	优先级：命令行参数 > 环境变量 > 配置文件 > 默认值，不存在时返回EmptyValue和LayerNone
+//	配置项不存在时，会查找嵌套的值，如：db.master.host 可以读取配置项db中的master.host
+func (f *File) GetItemLayer(key string) (*Value, Layer) {
+	if v, l := f.overlay(key); v != nil {
+		return v, l
+	}
+	if v, ok := f.items.Load(key); ok {
+		return v.Value, v.fileLayer()
+	}
+	for i := strings.LastIndexByte(key, '.'); i > 0; i = strings.LastIndexByte(key[:i], '.') {
+		if v, ok := f.items.Load(key[:i]); ok {
+			if x, ok := v.Value.Get(key[i+1:]); ok {
+				return x, v.fileLayer()
+			}
+		}
+	}
+	return EmptyValue, LayerNone
+}
+
+func (i *Item) fileLayer() Layer {
+	if i.layer == LayerNone {
+		return LayerFile
+	}
+	return i.layer
+}
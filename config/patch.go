@@ -0,0 +1,803 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/xyzj/gopsu/json"
+	"gopkg.in/yaml.v3"
+)
+
+// patchFile 在原文件内容上更新配置项，保持原有的顺序，注释和空行
+//
+//	值没有变化的配置项保持原样，删除的配置项连同其上方紧邻的注释一起删除，新增的配置项追加到对应的段或文件末尾
+//	更新后的内容会重新解析校验，无法安全更新时返回错误
+func patchFile(raw []byte, ft FormatType, items map[string]*Item) ([]byte, error) {
+	old, err := parseAs(raw, ft)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	switch ft {
+	case JSON:
+		out, err = patchJSON(raw, old, items)
+	case YAML:
+		out, err = patchYAML(raw, old, items)
+	default:
+		out, err = patchLines(raw, ft, old, items)
+	}
+	if err != nil {
+		return nil, err
+	}
+	x, err := parseAs(out, ft)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != len(items) {
+		return nil, fmt.Errorf("patch: want %d items, got %d", len(items), len(x))
+	}
+	for k, v := range items {
+		if nv, ok := x[k]; !ok || !equalValue(nv.Value, v.Value) {
+			return nil, fmt.Errorf("patch: item %s mismatch", k)
+		}
+	}
+	return out, nil
+}
+
+// sameValue 判断原文件中的值是否没有变化
+func sameValue(old map[string]*Item, key string, item *Item) bool {
+	ov, ok := old[key]
+	return ok && equalValue(ov.Value, item.Value)
+}
+
+// sameComment 判断原文件中的注释是否没有变化
+func sameComment(old map[string]*Item, key string, item *Item) bool {
+	ov, ok := old[key]
+	return ok && ov.Comment == item.Comment
+}
+
+func sortedKeys(items map[string]*Item) []string {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lineDoc 按行编辑的文件内容
+type lineDoc struct {
+	lines   []string
+	remove  map[int]bool
+	replace map[int]string
+	// 在指定行之后插入，-1表示文件开头
+	insert   map[int][]string
+	crlf     bool
+	trailing bool
+}
+
+func newLineDoc(raw []byte) *lineDoc {
+	s := string(raw)
+	d := &lineDoc{
+		remove:  make(map[int]bool),
+		replace: make(map[int]string),
+		insert:  make(map[int][]string),
+		crlf:    strings.Contains(s, "\r\n"),
+	}
+	if d.crlf {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	if strings.HasSuffix(s, "\n") {
+		d.trailing = true
+		s = s[:len(s)-1]
+	}
+	d.lines = strings.Split(s, "\n")
+	return d
+}
+
+// commentAbove 返回指定行上方紧邻的注释的第一行
+func (d *lineDoc) commentAbove(line int, marks string) int {
+	first := line
+	for i := line - 1; i >= 0; i-- {
+		s := strings.TrimSpace(d.lines[i])
+		if s == "" || !strings.ContainsRune(marks, rune(s[0])) {
+			break
+		}
+		first = i
+	}
+	return first
+}
+
+// drop 删除行，包含首尾
+func (d *lineDoc) drop(first, last int) {
+	for i := first; i <= last; i++ {
+		d.remove[i] = true
+	}
+}
+
+// set 将指定的行替换为一行新内容
+func (d *lineDoc) set(first, last int, s string) {
+	d.replace[first] = s
+	for i := first + 1; i <= last; i++ {
+		d.remove[i] = true
+	}
+}
+
+func (d *lineDoc) add(after int, ss ...string) {
+	d.insert[after] = append(d.insert[after], ss...)
+}
+
+func (d *lineDoc) Bytes() []byte {
+	out := make([]string, 0, len(d.lines))
+	out = append(out, d.insert[-1]...)
+	for i, s := range d.lines {
+		if !d.remove[i] {
+			if x, ok := d.replace[i]; ok {
+				s = x
+			}
+			out = append(out, s)
+		}
+		out = append(out, d.insert[i]...)
+	}
+	nl := "\n"
+	if d.crlf {
+		nl = "\r\n"
+	}
+	s := strings.Join(out, nl)
+	if d.trailing {
+		s += nl
+	}
+	return []byte(s)
+}
+
+// lineKey 文本格式中的一个配置项
+type lineKey struct {
+	key    string
+	prefix string // 值之前的内容
+	suffix string // 值之后的内容，如行尾注释
+	first  int    // 紧邻的注释的第一行
+	start  int    // 配置项所在行
+	end    int    // 值的最后一行
+	quoted bool
+	opaque bool // toml的inline table和array of tables，只能整体保留
+}
+
+// lineSection ini的配置段或toml的表
+type lineSection struct {
+	name   string
+	header int // 段名称所在行，-1表示第一个段之前的内容
+	end    int // 段的最后一个配置项的最后一行，没有配置项时为header
+	opaque bool
+}
+
+func commentLines(c string, mark string) []string {
+	if c == "" {
+		return nil
+	}
+	ss := strings.Split(c, "\n")
+	for i, v := range ss {
+		if !strings.HasPrefix(v, mark) {
+			ss[i] = mark + " " + v
+		}
+	}
+	return ss
+}
+
+// patchLines 按行更新key=value，ini和toml格式
+func patchLines(raw []byte, ft FormatType, old, items map[string]*Item) ([]byte, error) {
+	d := newLineDoc(raw)
+	var keys []*lineKey
+	var secs []*lineSection
+	var err error
+	marks := "#"
+	switch ft {
+	case INI:
+		marks = "#;"
+		keys, secs = scanINI(d)
+	case TOML:
+		keys, secs, err = scanTOML(d)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		keys = scanKeyValue(d)
+		secs = []*lineSection{{header: -1, end: -1}}
+	}
+	render := func(k *lineKey, v *Value) string {
+		switch ft {
+		case INI:
+			value, quoted := iniValue(v)
+			if v.t != tlist && v.t != tmap {
+				quoted = quoted || k.quoted
+			}
+			return quoteINI(value, quoted)
+		case TOML:
+			return tomlValue(v)
+		}
+		return v.String()
+	}
+	found := make(map[string]bool)
+	for _, k := range keys {
+		if k.opaque {
+			// 整体保留，其中的配置项不能有变化
+			for key := range old {
+				if key != k.key && !strings.HasPrefix(key, k.key+".") {
+					continue
+				}
+				if it, ok := items[key]; !ok || !sameValue(old, key, it) {
+					return nil, fmt.Errorf("patch: can not update %s", key)
+				}
+				found[key] = true
+			}
+			continue
+		}
+		if found[k.key] {
+			return nil, fmt.Errorf("patch: duplicate key %s", k.key)
+		}
+		found[k.key] = true
+		it, ok := items[k.key]
+		if !ok {
+			k.first = d.commentAbove(k.start, marks)
+			d.drop(k.first, k.end)
+			continue
+		}
+		if !sameComment(old, k.key, it) {
+			if first := d.commentAbove(k.start, marks); first < k.start {
+				d.drop(first, k.start-1)
+			}
+			d.add(k.start-1, commentLines(it.Comment, "#")...)
+		}
+		if sameValue(old, k.key, it) {
+			continue
+		}
+		d.set(k.start, k.end, k.prefix+render(k, it.Value)+k.suffix)
+	}
+	// 新增的配置项
+	secIdx := make(map[string]*lineSection)
+	for _, s := range secs {
+		if s.opaque {
+			secIdx[s.name] = nil
+			continue
+		}
+		secIdx[s.name] = s
+	}
+	added := make(map[string][]string)
+	addOrder := make([]string, 0)
+	for _, key := range sortedKeys(items) {
+		if found[key] {
+			continue
+		}
+		it := items[key]
+		if ft == KeyValue {
+			d.add(len(d.lines)-1, strings.Split(strings.TrimSuffix(it.String(), "\n"), "\n")...)
+			continue
+		}
+		sn, kn := splitKey(key)
+		if ft == TOML && tomlTableConflict(sn, items) {
+			sn, kn = "", key
+		}
+		if s, ok := secIdx[sn]; ok && s == nil {
+			return nil, fmt.Errorf("patch: can not add %s", key)
+		}
+		ss := commentLines(it.Comment, "#")
+		if ft == INI {
+			ss = append(ss, kn+"="+quoteINI(iniValue(it.Value)))
+		} else {
+			ss = append(ss, tomlKey(kn)+" = "+tomlValue(it.Value))
+		}
+		if _, ok := added[sn]; !ok {
+			addOrder = append(addOrder, sn)
+		}
+		added[sn] = append(added[sn], ss...)
+	}
+	for _, sn := range addOrder {
+		ss := added[sn]
+		s, ok := secIdx[sn]
+		switch {
+		case ok && (s.header >= 0 || s.end >= 0):
+			d.add(s.end, ss...)
+		case sn == "":
+			// 第一个段之前
+			at := len(d.lines) - 1
+			for _, x := range secs {
+				if x.header >= 0 {
+					at = d.commentAbove(x.header, marks) - 1
+					ss = append(ss, "")
+					break
+				}
+			}
+			d.add(at, ss...)
+		default:
+			name := sn
+			if ft == TOML {
+				name = tomlTable(sn)
+			}
+			d.add(len(d.lines)-1, append([]string{"", "[" + name + "]"}, ss...)...)
+		}
+	}
+	return d.Bytes(), nil
+}
+
+// scanKeyValue 查找key=value格式的配置项，规则和fromKeyValue相同
+func scanKeyValue(d *lineDoc) []*lineKey {
+	keys := make([]*lineKey, 0)
+	for i, line := range d.lines {
+		s := strings.TrimSpace(line)
+		if strings.HasPrefix(s, "#") {
+			continue
+		}
+		it := strings.Split(s, "=")
+		if len(it) != 2 {
+			continue
+		}
+		keys = append(keys, &lineKey{
+			key:    it[0],
+			prefix: line[:strings.IndexByte(line, '=')+1],
+			start:  i,
+			end:    i,
+		})
+	}
+	return keys
+}
+
+// scanINI 查找ini格式的配置段和配置项，规则和parseINI相同
+func scanINI(d *lineDoc) ([]*lineKey, []*lineSection) {
+	keys := make([]*lineKey, 0)
+	sec := &lineSection{header: -1, end: -1}
+	secs := []*lineSection{sec}
+	for i, line := range d.lines {
+		s := strings.TrimSpace(line)
+		switch {
+		case s == "", s[0] == '#', s[0] == ';':
+			continue
+		case s[0] == '[':
+			name := strings.TrimSpace(strings.TrimSuffix(s[1:], "]"))
+			sec = nil
+			for _, x := range secs {
+				if x.name == name {
+					sec = x
+				}
+			}
+			if sec == nil {
+				sec = &lineSection{name: name, header: i, end: i}
+				secs = append(secs, sec)
+			}
+			continue
+		}
+		idx := strings.IndexAny(line, "=:")
+		if idx <= 0 {
+			continue
+		}
+		j := idx + 1
+		for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+			j++
+		}
+		k := &lineKey{
+			key:    strings.TrimSpace(line[:idx]),
+			prefix: line[:j],
+			start:  i,
+			end:    i,
+		}
+		if sec.name != "" {
+			k.key = sec.name + "." + k.key
+		}
+		rest := line[j:]
+		n := len(strings.TrimRight(rest, " \t"))
+		switch {
+		case strings.HasPrefix(rest, "\""):
+			if q, err := strconv.QuotedPrefix(rest); err == nil {
+				n = len(q)
+				k.quoted = true
+			}
+		case strings.HasPrefix(rest, "'"):
+			if x := strings.IndexByte(rest[1:], '\''); x >= 0 {
+				n = x + 2
+				k.quoted = true
+			}
+		default:
+			for x := 1; x < len(rest); x++ {
+				if (rest[x] == '#' || rest[x] == ';') && (rest[x-1] == ' ' || rest[x-1] == '\t') {
+					n = len(strings.TrimRight(rest[:x], " \t"))
+					break
+				}
+			}
+		}
+		k.suffix = rest[n:]
+		keys = append(keys, k)
+		sec.end = i
+	}
+	return keys, secs
+}
+
+// scanTOML 查找toml格式的表和配置项
+func scanTOML(d *lineDoc) ([]*lineKey, []*lineSection, error) {
+	keys := make([]*lineKey, 0)
+	sec := &lineSection{header: -1, end: -1}
+	secs := []*lineSection{sec}
+	for i := 0; i < len(d.lines); i++ {
+		line := d.lines[i]
+		s := strings.TrimSpace(line)
+		switch {
+		case s == "", s[0] == '#':
+			continue
+		case s[0] == '[':
+			opaque := strings.HasPrefix(s, "[[")
+			s = strings.TrimLeft(s, "[")
+			if x := strings.IndexByte(s, ']'); x >= 0 {
+				s = s[:x]
+			}
+			sec = &lineSection{name: strings.Join(tomlKeyPath(s), "."), header: i, end: i, opaque: opaque}
+			secs = append(secs, sec)
+			if opaque {
+				keys = append(keys, &lineKey{key: sec.name, opaque: true})
+			}
+			continue
+		}
+		idx := tomlKeyEnd(line)
+		if idx <= 0 {
+			return nil, nil, fmt.Errorf("patch: bad toml line %d", i+1)
+		}
+		j := idx + 1
+		for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+			j++
+		}
+		el, ec := tomlValueEnd(d.lines, i, j)
+		sec.end = el
+		if sec.opaque {
+			i = el
+			continue
+		}
+		k := &lineKey{
+			key:    strings.Join(tomlKeyPath(line[:idx]), "."),
+			prefix: line[:j],
+			suffix: d.lines[el][ec:],
+			start:  i,
+			end:    el,
+			opaque: strings.HasPrefix(line[j:], "{"),
+		}
+		if sec.name != "" {
+			k.key = sec.name + "." + k.key
+		}
+		keys = append(keys, k)
+		i = el
+	}
+	return keys, secs, nil
+}
+
+// tomlValueEnd 返回toml值的结束位置，值可以跨行
+func tomlValueEnd(lines []string, li, col int) (int, int) {
+	depth := 0
+	for ; li < len(lines); li, col = li+1, 0 {
+		line := lines[li]
+		for col < len(line) {
+			c := line[col]
+			switch {
+			case strings.HasPrefix(line[col:], `"""`) || strings.HasPrefix(line[col:], `'''`):
+				q := line[col : col+3]
+				li, col = tomlStringEnd(lines, li, col+3, q)
+				line = lines[li]
+			case c == '"' || c == '\'':
+				li, col = tomlStringEnd(lines, li, col+1, string(c))
+			case c == '[' || c == '{':
+				depth++
+				col++
+				continue
+			case c == ']' || c == '}':
+				depth--
+				col++
+			case c == '#':
+				col = len(line)
+				continue
+			case depth > 0:
+				col++
+				continue
+			case c == ' ' || c == '\t':
+				return li, col
+			default:
+				col++
+				continue
+			}
+			if depth == 0 {
+				return li, col
+			}
+		}
+		if depth == 0 {
+			return li, len(strings.TrimRight(line, " \t"))
+		}
+	}
+	li = len(lines) - 1
+	return li, len(lines[li])
+}
+
+// tomlStringEnd 返回字符串结束引号之后的位置
+func tomlStringEnd(lines []string, li, col int, q string) (int, int) {
+	for ; li < len(lines); li, col = li+1, 0 {
+		line := lines[li]
+		for col < len(line) {
+			if q[0] == '"' && line[col] == '\\' {
+				col += 2
+				continue
+			}
+			if strings.HasPrefix(line[col:], q) {
+				col += len(q)
+				// 多行字符串结束时可以有额外的引号
+				for len(q) == 3 && col < len(line) && line[col] == q[0] {
+					col++
+				}
+				return li, col
+			}
+			col++
+		}
+		if len(q) == 1 {
+			return li, len(line)
+		}
+	}
+	li = len(lines) - 1
+	return li, len(lines[li])
+}
+
+// patchYAML 使用行列信息更新yaml格式的配置项
+//
+//	单行的值和注释直接替换，无法直接替换时重新生成整个配置项，新增的配置项使用原文件的缩进
+func patchYAML(raw []byte, old, items map[string]*Item) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(raw, doc); err != nil {
+		return nil, err
+	}
+	d := newLineDoc(raw)
+	found := make(map[string]bool)
+	indent := 2
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+			return nil, fmt.Errorf("patch: yaml root must be a block map")
+		}
+		indent = yamlIndent(root)
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			k, v := root.Content[i], root.Content[i+1]
+			found[k.Value] = true
+			it, ok := items[k.Value]
+			if !ok {
+				d.drop(d.commentAbove(k.Line-1, "#"), lastLine(v)-1)
+				continue
+			}
+			sv, sc := sameValue(old, k.Value, it), sameComment(old, k.Value, it)
+			if sv && sc {
+				continue
+			}
+			if v.Style&yaml.FlowStyle == 0 && (sv || yamlSetScalar(d, mapValue(v, "value"), it.Value)) &&
+				(sc || yamlSetScalar(d, mapValue(v, "comment"), it.Comment)) {
+				continue
+			}
+			// 重新生成整个配置项
+			end := len(d.lines) - 1
+			if i+2 < len(root.Content) {
+				end = d.commentAbove(root.Content[i+2].Line-1, "#") - 1
+			}
+			for end > k.Line-1 && yamlTrailing(d.lines[end], k.Column) {
+				end--
+			}
+			ss, err := yamlItem(k.Value, it, indent)
+			if err != nil {
+				return nil, err
+			}
+			for j := k.Line - 1; j <= end; j++ {
+				delete(d.replace, j)
+			}
+			d.drop(k.Line-1, end)
+			d.add(k.Line-2, ss...)
+		}
+	}
+	for _, key := range sortedKeys(items) {
+		if found[key] {
+			continue
+		}
+		ss, err := yamlItem(key, items[key], indent)
+		if err != nil {
+			return nil, err
+		}
+		d.add(len(d.lines)-1, ss...)
+	}
+	return d.Bytes(), nil
+}
+
+// yamlIndent 原文件配置项内容的缩进，默认为2
+func yamlIndent(root *yaml.Node) int {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 && len(v.Content) > 0 && v.Content[0].Column > k.Column {
+			return v.Content[0].Column - k.Column
+		}
+	}
+	return 2
+}
+
+// yamlItem 使用指定缩进格式化一个配置项
+func yamlItem(key string, it *Item, indent int) ([]string, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(map[string]*Item{key: it}); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// yamlSetScalar 替换单行的标量，无法替换时返回false
+func yamlSetScalar(d *lineDoc, n *yaml.Node, v any) bool {
+	if n == nil || n.Kind != yaml.ScalarNode || n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return false
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return false
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	if strings.Contains(s, "\n") {
+		return false
+	}
+	li := n.Line - 1
+	line := d.lines[li]
+	if x, ok := d.replace[li]; ok {
+		line = x
+	}
+	col := runeOffset(line, n.Column-1)
+	size := yamlScalarLen(line[col:], n.Style)
+	d.set(li, li, line[:col]+s+line[col+size:])
+	return true
+}
+
+// yamlTrailing 配置项之后的空行，或缩进不大于配置项的注释
+func yamlTrailing(line string, column int) bool {
+	s := strings.TrimSpace(line)
+	if s == "" {
+		return true
+	}
+	return s[0] == '#' && len(line)-len(strings.TrimLeft(line, " \t")) < column
+}
+
+func mapValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// lastLine 节点的最后一行
+func lastLine(n *yaml.Node) int {
+	l := n.Line
+	for _, c := range n.Content {
+		if x := lastLine(c); x > l {
+			l = x
+		}
+	}
+	return l
+}
+
+// runeOffset 将字符位置转换为字节位置
+func runeOffset(s string, n int) int {
+	off := 0
+	for i := 0; i < n && off < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[off:])
+		off += size
+	}
+	return off
+}
+
+// yamlScalarLen 返回单行yaml标量的字节长度
+func yamlScalarLen(s string, style yaml.Style) int {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	default:
+		for i := 1; i < len(s); i++ {
+			if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+				return len(strings.TrimRight(s[:i], " \t"))
+			}
+		}
+	}
+	return len(strings.TrimRight(s, " \t"))
+}
+
+var jsonPathReplacer = strings.NewReplacer(`\`, `\\`, ".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`, ":", `\:`)
+
+// patchJSON 使用sjson更新json格式的配置项，保持原有的顺序和缩进
+func patchJSON(raw []byte, old, items map[string]*Item) ([]byte, error) {
+	if !gjson.ValidBytes(raw) {
+		return nil, fmt.Errorf("patch: invalid json")
+	}
+	out := raw
+	var err error
+	indent := "  "
+	gjson.ParseBytes(raw).ForEach(func(k, _ gjson.Result) bool {
+		if k.Index > 0 {
+			if nl := bytes.LastIndexByte(raw[:k.Index], '\n'); nl >= 0 {
+				indent = string(raw[nl+1 : k.Index])
+			}
+		}
+		if _, ok := items[k.String()]; !ok {
+			out, err = sjson.DeleteBytes(out, jsonPathReplacer.Replace(k.String()))
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	added := make([]string, 0)
+	for _, key := range sortedKeys(items) {
+		it := items[key]
+		if _, ok := old[key]; !ok {
+			b, err := json.Marshal(it)
+			if err != nil {
+				return nil, err
+			}
+			k, _ := json.Marshal(key)
+			added = append(added, indent+string(k)+": "+string(b))
+			continue
+		}
+		if !sameComment(old, key, it) {
+			if out, err = sjson.SetBytes(out, jsonPathReplacer.Replace(key)+".comment", it.Comment); err != nil {
+				return nil, err
+			}
+		}
+		if sameValue(old, key, it) {
+			continue
+		}
+		b, err := it.Value.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		if out, err = sjson.SetRawBytes(out, jsonPathReplacer.Replace(key)+".value", b); err != nil {
+			return nil, err
+		}
+	}
+	if len(added) == 0 {
+		return out, nil
+	}
+	// 新增的配置项追加到最后，使用原有的缩进
+	end := bytes.LastIndexByte(out, '}')
+	if end < 0 {
+		return nil, fmt.Errorf("patch: invalid json")
+	}
+	body := bytes.TrimRight(out[:end], " \t\r\n")
+	sep := ",\n"
+	if bytes.HasSuffix(body, []byte("{")) {
+		sep = "\n"
+	}
+	buf := &bytes.Buffer{}
+	buf.Write(body)
+	buf.WriteString(sep + strings.Join(added, ",\n") + "\n")
+	buf.Write(out[end:])
+	return buf.Bytes(), nil
+}
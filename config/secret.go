@@ -0,0 +1,234 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/xyzj/gopsu"
+	"github.com/xyzj/gopsu/crypto"
+)
+
+// secretPrefix 密文前缀，完整格式为 enc:v1:算法:密钥id:base64url密文
+const secretPrefix = "enc:v1:"
+
+var defaultBox atomic.Pointer[SecretBox]
+
+// SecretBox 使用主密钥加密解密配置中的敏感内容
+//
+//	密文格式：enc:v1:aes256gcm:1a2b3c4d:xxxx，密钥id为主密钥sha256的前4字节，用于识别加密时使用的密钥
+type SecretBox struct {
+	gcm  *crypto.GCM
+	algo crypto.GCMType
+	kid  string
+}
+
+// NewSecretBox 使用主密钥创建加密解密器
+//
+//	algo: crypto.AES128GCM，crypto.AES256GCM，crypto.SM4GCM
+//	key: 主密钥，长度必须等于算法的密钥长度
+func NewSecretBox(algo crypto.GCMType, key []byte) (*SecretBox, error) {
+	g := crypto.NewGCM(algo)
+	if err := g.SetKey(key); err != nil {
+		return nil, err
+	}
+	return &SecretBox{
+		gcm:  g,
+		algo: algo,
+		kid:  crypto.GetSHA256(string(key))[:8],
+	}, nil
+}
+
+// LoadSecretBox 从环境变量或密钥文件读取主密钥，创建加密解密器
+//
+//	envname: 环境变量名称，不为空且环境变量存在时优先使用
+//	keyfile: 密钥文件
+//	密钥内容为base64或hex编码的主密钥
+func LoadSecretBox(algo crypto.GCMType, keyfile, envname string) (*SecretBox, error) {
+	var s string
+	if v, ok := os.LookupEnv(envname); envname != "" && ok {
+		s = v
+	} else {
+		if keyfile == "" {
+			return nil, fmt.Errorf("no key file or env was specified")
+		}
+		b, err := os.ReadFile(keyfile)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	}
+	key, err := parseSecretKey(strings.TrimSpace(s), algo.KeySize())
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretBox(algo, key)
+}
+
+func parseSecretKey(s string, l int) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(crypto.FillBase64(s)); err == nil && len(b) == l {
+		return b, nil
+	}
+	if b, err := hex.DecodeString(s); err == nil && len(b) == l {
+		return b, nil
+	}
+	return nil, fmt.Errorf("key must be a base64 or hex encoded %d bytes key", l)
+}
+
+// GenerateSecretKey 生成随机的主密钥，返回base64编码的密钥
+//
+//	keyfile: 不为空时将密钥写入该文件，文件权限为0600
+func GenerateSecretKey(algo crypto.GCMType, keyfile string) (string, error) {
+	s := base64.StdEncoding.EncodeToString(crypto.GetRandom(algo.KeySize()))
+	if keyfile != "" {
+		if err := os.WriteFile(keyfile, []byte(s+"\n"), 0o600); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+// KeyID 返回密钥id
+func (b *SecretBox) KeyID() string {
+	return b.kid
+}
+
+// header 密文头，同时作为认证的附加数据，防止算法和密钥id被篡改
+func (b *SecretBox) header() string {
+	return secretPrefix + b.algo.String() + ":" + b.kid + ":"
+}
+
+// Encrypt 加密字符串，返回带版本前缀的密文
+func (b *SecretBox) Encrypt(s string) (string, error) {
+	h := b.header()
+	v, err := b.gcm.Encode([]byte(s), []byte(h))
+	if err != nil {
+		return "", err
+	}
+	return h + base64.RawURLEncoding.EncodeToString(v), nil
+}
+
+// Decrypt 解密字符串
+//
+//	带版本前缀的密文使用主密钥解密，密钥不匹配或内容被篡改时返回错误
+//	其他内容按旧的gopsu.CodeString格式解码
+func (b *SecretBox) Decrypt(s string) (string, error) {
+	if !IsSecret(s) {
+		return gopsu.DecodeString(s), nil
+	}
+	ss := strings.SplitN(s[len(secretPrefix):], ":", 3)
+	if len(ss) != 3 {
+		return "", fmt.Errorf("bad secret format")
+	}
+	if ss[0] != b.algo.String() || ss[1] != b.kid {
+		return "", fmt.Errorf("secret was encrypted by %s key %s, not %s key %s", ss[0], ss[1], b.algo.String(), b.kid)
+	}
+	v, err := base64.RawURLEncoding.DecodeString(ss[2])
+	if err != nil {
+		return "", err
+	}
+	return b.gcm.Decode(v, []byte(b.header()))
+}
+
+// owns 判断密文是否由该加密器加密
+func (b *SecretBox) owns(s string) bool {
+	return strings.HasPrefix(s, b.header())
+}
+
+// IsSecret 判断字符串是否是带版本前缀的密文
+func IsSecret(s string) bool {
+	return strings.HasPrefix(s, secretPrefix)
+}
+
+// SetSecretBox 设置默认的加密解密器，PwdString，NewCodeValue，Value.TryDecode和结构体绑定时使用
+//
+//	为nil时加密使用旧的gopsu.CodeString，带版本前缀的密文无法解密
+func SetSecretBox(b *SecretBox) {
+	defaultBox.Store(b)
+}
+
+// encodeSecret 使用默认加密器加密，未设置时使用gopsu.CodeString
+func encodeSecret(s string) string {
+	if b := defaultBox.Load(); b != nil {
+		if x, err := b.Encrypt(s); err == nil {
+			return x
+		}
+	}
+	return gopsu.CodeString(s)
+}
+
+// decodeSecret 解密，带版本前缀的密文使用默认加密器，其他内容使用gopsu.DecodeString，失败时返回空字符串
+func decodeSecret(s string) string {
+	if !IsSecret(s) {
+		return gopsu.DecodeString(s)
+	}
+	if b := defaultBox.Load(); b != nil {
+		if x, err := b.Decrypt(s); err == nil {
+			return x
+		}
+	}
+	return ""
+}
+
+// RotateSecrets 使用新的加密器重新加密配置中的密文，返回重新加密的配置项数量，需要调用Save保存
+//
+//	old: 原加密器，为nil时只处理legacy
+//	legacy: 使用旧的gopsu.CodeString编码的配置项名称，会被转换为新格式
+//	已经由新加密器加密的配置项会被跳过，任一配置项解密失败时不做任何修改
+func (f *File) RotateSecrets(old, new *SecretBox, legacy ...string) (int, error) {
+	if new == nil {
+		return 0, fmt.Errorf("new secret box is nil")
+	}
+	plain := make(map[string]string)
+	errs := make([]string, 0)
+	f.items.ForEach(func(key string, value *Item) bool {
+		s := value.Value.String()
+		if !IsSecret(s) || new.owns(s) {
+			return true
+		}
+		if old == nil {
+			errs = append(errs, key+": no old secret box")
+			return true
+		}
+		x, err := old.Decrypt(s)
+		if err != nil {
+			errs = append(errs, key+": "+err.Error())
+			return true
+		}
+		plain[key] = x
+		return true
+	})
+	for _, key := range legacy {
+		v, ok := f.items.Load(key)
+		if !ok || IsSecret(v.Value.String()) {
+			continue
+		}
+		plain[key] = gopsu.DecodeString(v.Value.String())
+	}
+	if len(errs) > 0 {
+		return 0, fmt.Errorf("rotate secrets failed:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	enc := make(map[string]string, len(plain))
+	for k, s := range plain {
+		x, err := new.Encrypt(s)
+		if err != nil {
+			return 0, err
+		}
+		enc[k] = x
+	}
+	// 使用新的配置项整体替换，不修改map内的值
+	for k, s := range enc {
+		if v, ok := f.items.Load(k); ok {
+			f.items.Store(k, &Item{
+				Key:     v.Key,
+				Value:   NewValue(s),
+				Comment: v.Comment,
+				layer:   v.layer,
+			})
+		}
+	}
+	return len(enc), nil
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// fromTOML 解析toml内容，表中的配置项名称为 表名称.配置项名称，数组和inline table保持嵌套结构
+func fromTOML(b []byte) (map[string]*Item, error) {
+	m := make(map[string]any)
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	x := make(map[string]*Item)
+	flattenTOML("", m, x)
+	for k, c := range tomlComments(b) {
+		if it, ok := x[k]; ok {
+			it.Comment = c
+		}
+	}
+	return x, nil
+}
+
+func flattenTOML(prefix string, m map[string]any, x map[string]*Item) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			flattenTOML(k, sub, x)
+			continue
+		}
+		x[k] = &Item{Key: k, Value: tomlToValue(v)}
+	}
+}
+
+func tomlToValue(v any) *Value {
+	switch x := v.(type) {
+	case int64:
+		return NewInt64Value(x)
+	case float64:
+		return NewFloat64Value(x)
+	case bool:
+		return NewBoolValue(x)
+	case string:
+		return NewValue(x)
+	case time.Time:
+		return NewValue(x.Format(time.RFC3339Nano))
+	case []any:
+		l := make([]*Value, 0, len(x))
+		for _, a := range x {
+			l = append(l, tomlToValue(a))
+		}
+		return NewListValue(l...)
+	case map[string]any:
+		m := make(map[string]*Value, len(x))
+		for k, a := range x {
+			m[k] = tomlToValue(a)
+		}
+		return NewMapValue(m)
+	default:
+		return NewValue(fmt.Sprintf("%v", x))
+	}
+}
+
+// normalizeTOML 将toml的本地日期时间类型转换为字符串
+func normalizeTOML(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, a := range x {
+			x[k] = normalizeTOML(a)
+		}
+	case []any:
+		for i, a := range x {
+			x[i] = normalizeTOML(a)
+		}
+	case toml.LocalDate, toml.LocalTime, toml.LocalDateTime:
+		return fmt.Sprintf("%v", x)
+	}
+	return v
+}
+
+// dropNil 删除nil值，toml不支持空值
+func dropNil(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, a := range x {
+			if a == nil {
+				delete(x, k)
+				continue
+			}
+			x[k] = dropNil(a)
+		}
+	case []any:
+		y := x[:0]
+		for _, a := range x {
+			if a != nil {
+				y = append(y, dropNil(a))
+			}
+		}
+		return y
+	}
+	return v
+}
+
+// tomlComments 读取toml内容中配置项上方的注释
+func tomlComments(b []byte) map[string]string {
+	x := make(map[string]string)
+	table := ""
+	tip := make([]string, 0)
+	multi := ""
+	for _, line := range strings.Split(string(b), "\n") {
+		s := strings.TrimSpace(line)
+		if multi != "" {
+			if strings.Count(s, multi)%2 == 1 {
+				multi = ""
+			}
+			continue
+		}
+		switch {
+		case s == "":
+			continue
+		case s[0] == '#':
+			if xt := strings.TrimSpace(s[1:]); xt != "" {
+				tip = append(tip, xt)
+			}
+			continue
+		case s[0] == '[':
+			s = strings.Trim(s, "[]")
+			if idx := strings.IndexByte(s, ']'); idx >= 0 {
+				s = s[:idx]
+			}
+			table = strings.Join(tomlKeyPath(s), ".")
+			tip = []string{}
+			continue
+		}
+		for _, q := range []string{`"""`, `'''`} {
+			if strings.Count(s, q)%2 == 1 {
+				multi = q
+			}
+		}
+		idx := tomlKeyEnd(s)
+		if idx <= 0 {
+			continue
+		}
+		key := strings.Join(tomlKeyPath(s[:idx]), ".")
+		if table != "" {
+			key = table + "." + key
+		}
+		if len(tip) > 0 {
+			x[key] = strings.Join(tip, "\n")
+		}
+		tip = []string{}
+	}
+	return x
+}
+
+// tomlKeyEnd 返回引号外第一个`=`的位置
+func tomlKeyEnd(s string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlKeyPath 拆分toml的点分名称，如：a."b.c" 返回 a，b.c
+func tomlKeyPath(s string) []string {
+	ss := make([]string, 0)
+	var quote byte
+	cur := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			} else {
+				cur.WriteByte(s[i])
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '.':
+			ss = append(ss, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(ss, strings.TrimSpace(cur.String()))
+}
+
+// tomlKey 非bare key使用引号
+func tomlKey(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return tomlString(s)
+		}
+	}
+	return s
+}
+
+func tomlTable(s string) string {
+	ss := strings.Split(s, ".")
+	for i, v := range ss {
+		ss[i] = tomlKey(v)
+	}
+	return strings.Join(ss, ".")
+}
+
+// tomlString 格式化为toml基本字符串
+func tomlString(s string) string {
+	buf := &strings.Builder{}
+	buf.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(buf, `\u%04X`, c)
+			} else {
+				buf.WriteRune(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// tomlValue 按值的类型格式化
+func tomlValue(v *Value) string {
+	switch v.t {
+	case tint64:
+		return strconv.FormatInt(v.nint64, 10)
+	case tuint64:
+		if v.nuint64 <= math.MaxInt64 {
+			return strconv.FormatUint(v.nuint64, 10)
+		}
+	case tfloat64:
+		switch {
+		case math.IsNaN(v.nfloat64):
+			return "nan"
+		case math.IsInf(v.nfloat64, 1):
+			return "inf"
+		case math.IsInf(v.nfloat64, -1):
+			return "-inf"
+		}
+		s := strconv.FormatFloat(v.nfloat64, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	case tbool:
+		return strconv.FormatBool(v.nbool)
+	case tlist:
+		ss := make([]string, 0, len(v.nlist))
+		for _, x := range v.nlist {
+			ss = append(ss, tomlValue(x))
+		}
+		return "[" + strings.Join(ss, ", ") + "]"
+	case tmap:
+		keys := make([]string, 0, len(v.nmap))
+		for k := range v.nmap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ss := make([]string, 0, len(keys))
+		for _, k := range keys {
+			ss = append(ss, tomlKey(k)+" = "+tomlValue(v.nmap[k]))
+		}
+		return "{" + strings.Join(ss, ", ") + "}"
+	}
+	return tomlString(v.String())
+}
+
+// toTOML 格式化为toml内容，名称中包含`.`的配置项，最后一个`.`之前的部分作为表名称
+//
+//	表名称或其上级与其他配置项同名时（如同时有x和x.y），该表的配置项使用带引号的完整名称写在最前，如："x.y" = 1
+func toTOML(items map[string]*Item) []byte {
+	tables := make(map[string][]*Item)
+	quoted := make(map[string]bool)
+	for k, v := range items {
+		tn, _ := splitKey(k)
+		if tomlTableConflict(tn, items) {
+			tn = ""
+			quoted[k] = true
+		}
+		tables[tn] = append(tables[tn], v)
+	}
+	names := make([]string, 0, len(tables))
+	for k := range tables {
+		names = append(names, k)
+	}
+	// 无表名称的配置项必须在最前
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	for _, tn := range names {
+		its := tables[tn]
+		sort.Slice(its, func(i, j int) bool {
+			return its[i].Key < its[j].Key
+		})
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if tn != "" {
+			buf.WriteString("[" + tomlTable(tn) + "]\n")
+		}
+		for _, it := range its {
+			if it.Comment != "" {
+				for _, c := range strings.Split(it.Comment, "\n") {
+					if strings.HasPrefix(c, "#") {
+						buf.WriteString(c + "\n")
+					} else {
+						buf.WriteString("# " + c + "\n")
+					}
+				}
+			}
+			_, kn := splitKey(it.Key)
+			if quoted[it.Key] {
+				kn = it.Key
+			}
+			buf.WriteString(tomlKey(kn) + " = " + tomlValue(it.Value) + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// tomlTableConflict 表名称或其上级是否与配置项同名
+func tomlTableConflict(tn string, items map[string]*Item) bool {
+	for tn != "" {
+		if _, ok := items[tn]; ok {
+			return true
+		}
+		tn, _ = splitKey(tn)
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xyzj/gopsu"
 	"github.com/xyzj/gopsu/json"
@@ -17,6 +18,8 @@ const (
 	tuint64
 	tfloat64
 	tbool
+	tlist
+	tmap
 )
 
 // EmptyValue an empty value
@@ -61,10 +64,28 @@ func NewBoolValue(n bool) *Value {
 	}
 }
 
+// NewListValue return a list value
+func NewListValue(vs ...*Value) *Value {
+	return &Value{
+		t:     tlist,
+		nlist: vs,
+	}
+}
+
+// NewMapValue return a map value
+func NewMapValue(m map[string]*Value) *Value {
+	return &Value{
+		t:    tmap,
+		nmap: m,
+	}
+}
+
 // NewCodeValue return a value after code the data
+//
+//	设置了SetSecretBox时使用主密钥加密，否则使用gopsu.CodeString
 func NewCodeValue(s string) *Value {
 	return &Value{
-		nstr: gopsu.CodeString(s),
+		nstr: encodeSecret(s),
 	}
 }
 
@@ -75,6 +96,8 @@ type Value struct {
 	nuint64  uint64
 	nfloat64 float64
 	nbool    bool
+	nlist    []*Value
+	nmap     map[string]*Value
 	t        dataType
 }
 
@@ -104,16 +127,42 @@ func (v *Value) unmarshal() error {
 }
 
 func (v *Value) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	if err := unmarshal(&v.nstr); err != nil {
+	if err := unmarshal(&v.nstr); err == nil {
+		return v.unmarshal()
+	}
+	var l []*Value
+	if err := unmarshal(&l); err == nil {
+		v.setList(l)
+		return nil
+	}
+	var m map[string]*Value
+	if err := unmarshal(&m); err != nil {
 		return err
 	}
-	return v.unmarshal()
+	v.setMap(m)
+	return nil
 }
 
 func (v *Value) UnmarshalJSON(data []byte) error {
 	if len(data) == 0 {
 		return nil
 	}
+	switch data[0] {
+	case '[':
+		var l []*Value
+		if err := json.Unmarshal(data, &l); err != nil {
+			return err
+		}
+		v.setList(l)
+		return nil
+	case '{':
+		var m map[string]*Value
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		v.setMap(m)
+		return nil
+	}
 	if data[0] == 34 {
 		data = data[1 : len(data)-1]
 	}
@@ -121,8 +170,34 @@ func (v *Value) UnmarshalJSON(data []byte) error {
 	return v.unmarshal()
 }
 
+// setList 设置列表，空元素替换为空字符串
+func (v *Value) setList(l []*Value) {
+	for i, x := range l {
+		if x == nil {
+			l[i] = NewValue("")
+		}
+	}
+	v.t = tlist
+	v.nlist = l
+}
+
+// setMap 设置map，空元素替换为空字符串
+func (v *Value) setMap(m map[string]*Value) {
+	for k, x := range m {
+		if x == nil {
+			m[k] = NewValue("")
+		}
+	}
+	v.t = tmap
+	v.nmap = m
+}
+
 func (v *Value) MarshalYAML() (any, error) {
 	switch v.t {
+	case tlist:
+		return v.nlist, nil
+	case tmap:
+		return v.nmap, nil
 	case tint64:
 		return v.nint64, nil
 	case tuint64:
@@ -138,6 +213,10 @@ func (v *Value) MarshalYAML() (any, error) {
 
 func (v *Value) MarshalJSON() ([]byte, error) {
 	switch v.t {
+	case tlist:
+		return json.Marshal(v.nlist)
+	case tmap:
+		return json.Marshal(v.nmap)
 	case tint64:
 		return strconv.AppendInt([]byte{}, v.nint64, 10), nil // []byte(fmt.Sprintf("%d", v.nint64)), nil
 	case tuint64:
@@ -152,24 +231,66 @@ func (v *Value) MarshalJSON() ([]byte, error) {
 }
 
 // String reutrn string
+//
+//	列表返回使用`,`连接的元素，map返回json字符串
 func (v *Value) String() string {
 	switch v.t {
 	case tstr:
 		return v.nstr
+	case tlist:
+		ss := make([]string, 0, len(v.nlist))
+		for _, x := range v.nlist {
+			ss = append(ss, x.String())
+		}
+		return strings.Join(ss, ",")
+	case tmap:
+		b, _ := json.Marshal(v.nmap)
+		return json.String(b)
 	case tint64:
-		v.nstr = strconv.FormatInt(v.nint64, 10)
+		return strconv.FormatInt(v.nint64, 10)
 	case tuint64:
-		v.nstr = strconv.FormatUint(v.nuint64, 10)
+		return strconv.FormatUint(v.nuint64, 10)
 	case tfloat64:
-		v.nstr = strconv.FormatFloat(v.nfloat64, 'g', -1, 64)
+		return strconv.FormatFloat(v.nfloat64, 'g', -1, 64)
 	case tbool:
-		v.nstr = strconv.FormatBool(v.nbool)
+		return strconv.FormatBool(v.nbool)
 	}
 	return v.nstr
 }
 
+// equalValue 判断两个值是否相同，map不受元素顺序影响
+func equalValue(a, b *Value) bool {
+	switch {
+	case a.t == tmap && b.t == tmap:
+		if len(a.nmap) != len(b.nmap) {
+			return false
+		}
+		for k, x := range a.nmap {
+			if y, ok := b.nmap[k]; !ok || !equalValue(x, y) {
+				return false
+			}
+		}
+		return true
+	case a.t == tlist && b.t == tlist:
+		if len(a.nlist) != len(b.nlist) {
+			return false
+		}
+		for i := range a.nlist {
+			if !equalValue(a.nlist[i], b.nlist[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.String() == b.String()
+}
+
 // Bytes reutrn []byte
 func (v *Value) Bytes() []byte {
+	switch v.t {
+	case tint64, tuint64, tfloat64, tbool:
+		return json.Bytes(v.String())
+	}
 	return json.Bytes(v.nstr)
 }
 
@@ -295,8 +416,119 @@ func (v *Value) TryFloat64(dec ...int) float64 {
 	return 0
 }
 
+// Get 读取嵌套的值，path使用`.`分割，列表使用数字下标，如：master.hosts.0
+func (v *Value) Get(path string) (*Value, bool) {
+	x := v
+	for _, k := range strings.Split(path, ".") {
+		switch x.t {
+		case tmap:
+			y, ok := x.nmap[k]
+			if !ok {
+				return EmptyValue, false
+			}
+			x = y
+		case tlist:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(x.nlist) {
+				return EmptyValue, false
+			}
+			x = x.nlist[i]
+		default:
+			return EmptyValue, false
+		}
+	}
+	return x, true
+}
+
+// TryList 返回列表的元素
+//
+//	字符串以`[`开头时按json数组解析，否则使用`,`分割，兼容旧的使用`,`连接的配置
+func (v *Value) TryList() []*Value {
+	switch v.t {
+	case tlist:
+		return v.nlist
+	case tmap:
+		return []*Value{}
+	}
+	s := strings.TrimSpace(v.String())
+	if strings.HasPrefix(s, "[") {
+		var l []*Value
+		if err := json.Unmarshal([]byte(s), &l); err == nil {
+			return l
+		}
+	}
+	l := make([]*Value, 0)
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			l = append(l, NewValue(x))
+		}
+	}
+	return l
+}
+
+// TryStringSlice 返回字符串列表，规则同TryList
+func (v *Value) TryStringSlice() []string {
+	l := v.TryList()
+	ss := make([]string, 0, len(l))
+	for _, x := range l {
+		ss = append(ss, x.String())
+	}
+	return ss
+}
+
+// TryIntSlice 返回整数列表，规则同TryList，无法转换的元素为0
+func (v *Value) TryIntSlice() []int {
+	l := v.TryList()
+	ns := make([]int, 0, len(l))
+	for _, x := range l {
+		ns = append(ns, x.TryInt())
+	}
+	return ns
+}
+
+// TryDuration 返回时间间隔
+//
+//	字符串按time.ParseDuration解析，如：1m30s，数字作为秒数
+func (v *Value) TryDuration() time.Duration {
+	switch v.t {
+	case tint64, tuint64, tbool:
+		return time.Duration(v.TryInt64()) * time.Second
+	case tfloat64:
+		return time.Duration(v.nfloat64 * float64(time.Second))
+	case tlist, tmap:
+		return 0
+	}
+	d, _ := parseDuration(v.nstr)
+	return d
+}
+
+// parseDuration 解析时长，支持time.ParseDuration的格式，以及单位为秒的数字
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+// TryMap 返回map，字符串以`{`开头时按json对象解析，其他情况返回空map
+func (v *Value) TryMap() map[string]*Value {
+	if v.t == tmap {
+		return v.nmap
+	}
+	m := make(map[string]*Value)
+	if s := strings.TrimSpace(v.nstr); v.t == tstr && strings.HasPrefix(s, "{") {
+		json.Unmarshal([]byte(s), &m)
+	}
+	return m
+}
+
 func (v *Value) TryDecode() string {
-	if s := gopsu.DecodeString(v.nstr); s != "" {
+	if s := decodeSecret(v.nstr); s != "" {
 		return s
 	}
 	return v.nstr
@@ -310,6 +542,8 @@ func (v *Value) TryTimestamp(f string) int64 {
 }
 
 // PwdString 序列化反序列化时可自动加密解密字符串，用于敏感字段
+//
+//	设置了SetSecretBox时使用主密钥加密，解密时兼容gopsu.CodeString的旧格式
 type PwdString string
 
 func (p *PwdString) UnmarshalJSON(data []byte) error {
@@ -317,7 +551,7 @@ func (p *PwdString) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
-	*p = PwdString(gopsu.DecodeString(s))
+	*p = PwdString(decodeSecret(s))
 	return nil
 }
 
@@ -325,7 +559,7 @@ func (p *PwdString) MarshalJSON() ([]byte, error) {
 	if string(*p) == "" {
 		return []byte("\"\""), nil
 	}
-	return []byte("\"" + gopsu.CodeString(string(*p)) + "\""), nil
+	return []byte("\"" + encodeSecret(string(*p)) + "\""), nil
 }
 
 func (p *PwdString) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -333,7 +567,7 @@ func (p *PwdString) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&s); err != nil {
 		return err
 	}
-	*p = PwdString(gopsu.DecodeString(s))
+	*p = PwdString(decodeSecret(s))
 	return nil
 }
 
@@ -341,7 +575,7 @@ func (p PwdString) MarshalYAML() (interface{}, error) {
 	if string(p) == "" {
 		return "", nil
 	}
-	return gopsu.CodeString(string(p)), nil
+	return encodeSecret(string(p)), nil
 }
 
 // VString value string, can parse to bool int64 float64
@@ -395,7 +629,7 @@ func (rs VString) TryFloat64() float64 {
 
 // TryDecode try decode the value, if failed, return the origin
 func (rs VString) TryDecode() string {
-	if s := gopsu.DecodeString(string(rs)); s != "" {
+	if s := decodeSecret(string(rs)); s != "" {
 		return s
 	}
 	return string(rs)
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xyzj/gopsu/logger"
+	"github.com/xyzj/gopsu/loopfunc"
+	"github.com/xyzj/gopsu/mapfx"
+)
+
+// WatchOpt 配置文件监视参数
+type WatchOpt struct {
+	// 载入新配置后的校验方法，返回错误时放弃本次载入，保留原配置
+	Validate func(nf *File) error
+	// 载入或校验失败时的处理方法
+	OnError func(err error)
+	// 检查间隔，默认5秒，不低于1秒
+	Interval time.Duration
+}
+
+// watcher 配置文件监视状态
+type watcher struct {
+	locker   sync.Mutex
+	onChange []func(key string, old, new *Value)
+	opt      *WatchOpt
+	stop     chan struct{}
+	modTime  time.Time
+	size     int64
+	hash     uint64
+}
+
+func (f *File) getWatcher() *watcher {
+	f.watchOnce.Do(func() {
+		f.watch = &watcher{
+			onChange: make([]func(key string, old, new *Value), 0),
+		}
+	})
+	return f.watch
+}
+
+// OnChange 添加配置变化时的回调方法，配置重新载入后，对每个新增，删除或值变化的配置项调用
+//
+//	新增的配置old为nil，删除的配置new为nil
+func (f *File) OnChange(fn func(key string, old, new *Value)) {
+	w := f.getWatcher()
+	w.locker.Lock()
+	w.onChange = append(w.onChange, fn)
+	w.locker.Unlock()
+}
+
+// Watch 定时检查配置文件，文件变化时自动重新载入，重复调用会替换原有的监视参数
+//
+//	通过文件修改时间，大小和内容校验判断文件是否变化
+//	新配置会先完整解析并校验，通过后一次性替换原配置，然后执行OnChange回调
+func (f *File) Watch(opt *WatchOpt) error {
+	if f.filepath == "" {
+		return fmt.Errorf("no file name was specified")
+	}
+	if opt == nil {
+		opt = &WatchOpt{}
+	}
+	if opt.Interval == 0 {
+		opt.Interval = time.Second * 5
+	}
+	if opt.Interval < time.Second {
+		opt.Interval = time.Second
+	}
+	f.StopWatch()
+	w := f.getWatcher()
+	w.locker.Lock()
+	w.opt = opt
+	w.stop = make(chan struct{})
+	stop := w.stop
+	if fi, err := os.Stat(f.filepath); err == nil {
+		w.modTime = fi.ModTime()
+		w.size = fi.Size()
+	}
+	if b, err := os.ReadFile(f.filepath); err == nil {
+		w.hash = hashBytes(b)
+	}
+	w.locker.Unlock()
+	go loopfunc.LoopFunc(func(params ...interface{}) {
+		t := time.NewTicker(opt.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				fi, err := os.Stat(f.filepath)
+				if err != nil {
+					continue
+				}
+				w.locker.Lock()
+				changed := !fi.ModTime().Equal(w.modTime) || fi.Size() != w.size
+				w.locker.Unlock()
+				if !changed {
+					continue
+				}
+				if err := f.Reload(); err != nil && opt.OnError != nil {
+					opt.OnError(err)
+				}
+			}
+		}
+	}, "config watch", logger.NewConsoleWriter())
+	return nil
+}
+
+// StopWatch 停止监视配置文件
+func (f *File) StopWatch() {
+	w := f.getWatcher()
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Reload 重新载入配置文件，内容没有变化时不做处理
+//
+//	设置了Watch时，会使用其校验方法，校验失败时保留原配置并返回错误
+//	回调方法中的panic会被忽略，不影响其他回调
+func (f *File) Reload() error {
+	if f.filepath == "" {
+		return fmt.Errorf("no file name was specified")
+	}
+	fi, err := os.Stat(f.filepath)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(f.filepath)
+	if err != nil {
+		return err
+	}
+	w := f.getWatcher()
+	changes, err := w.reload(f, fi, b)
+	if err != nil {
+		return err
+	}
+	w.locker.Lock()
+	fns := w.onChange
+	w.locker.Unlock()
+	for _, c := range changes {
+		for _, fn := range fns {
+			func() {
+				defer func() { recover() }()
+				fn(c.key, c.old, c.new)
+			}()
+		}
+	}
+	return nil
+}
+
+type change struct {
+	old *Value
+	new *Value
+	key string
+}
+
+// reload 解析校验并替换配置，返回变化的配置项
+//
+//	读取到空文件（如编辑器先清空再写入），解析或校验失败时保留原配置，
+//	且不更新文件状态，监视时会在下次检查时重试
+func (w *watcher) reload(f *File, fi os.FileInfo, b []byte) ([]*change, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	h := hashBytes(b)
+	if h == w.hash {
+		w.modTime = fi.ModTime()
+		w.size = fi.Size()
+		return nil, nil
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("config file %s is empty", f.filepath)
+	}
+	items, ft, err := parseItems(b, formatFromExt(f.filepath))
+	if err != nil {
+		return nil, err
+	}
+	if w.opt != nil && w.opt.Validate != nil {
+		nf := &File{
+			items:      mapfx.NewStructMap[string, Item](),
+			data:       &bytes.Buffer{},
+			envMapper:  f.envMapper,
+			flags:      f.flags,
+			flagMapper: f.flagMapper,
+			filepath:   f.filepath,
+			raw:        b,
+			formatType: ft,
+		}
+		for k, v := range items {
+			x := *v
+			nf.items.Store(k, &x)
+		}
+		if err := w.opt.Validate(nf); err != nil {
+			return nil, fmt.Errorf("config validate failed: %w", err)
+		}
+	}
+	w.hash = h
+	w.modTime = fi.ModTime()
+	w.size = fi.Size()
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	old := f.items.Clone()
+	// 保留文件中没有的默认值
+	for k, v := range old {
+		if _, ok := items[k]; !ok && v.layer == LayerDefault {
+			items[k] = v
+		}
+	}
+	f.items.Replace(items)
+	f.data.Reset()
+	f.data.Write(b)
+	f.raw = b
+	f.formatType = ft
+	changes := make([]*change, 0)
+	for k, ov := range old {
+		nv, ok := items[k]
+		switch {
+		case !ok:
+			changes = append(changes, &change{key: k, old: ov.Value})
+		case !equalValue(nv.Value, ov.Value):
+			changes = append(changes, &change{key: k, old: ov.Value, new: nv.Value})
+		}
+	}
+	for k, nv := range items {
+		if _, ok := old[k]; !ok {
+			changes = append(changes, &change{key: k, new: nv.Value})
+		}
+	}
+	return changes, nil
+}
+
+func hashBytes(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
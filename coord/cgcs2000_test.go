@@ -1,6 +1,9 @@
 package coord
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestCGCS2000(t *testing.T) {
 	p1 := &Point{
@@ -21,3 +24,88 @@ func TestCGCS2000(t *testing.T) {
 		println(yp2.String())
 	})
 }
+
+func TestProjection(t *testing.T) {
+	t.Run("reference", func(t *testing.T) {
+		for _, c := range []struct {
+			p    Point
+			e, n float64
+		}{
+			// UTM 31带赤道上的西边界
+			{Point{Lng: 0, Lat: 0}, 166021.4431, 0},
+			// 中央经线上的北坐标为0.9996倍子午线弧长，WGS84椭球45度子午线弧长4984944.3779米
+			{Point{Lng: 3, Lat: 45}, 500000, 4984944.3779 * 0.9996},
+		} {
+			x, zone, south := ToUTM(&c.p)
+			if zone != 31 || south || math.Abs(x.Lng-c.e) > 1e-3 || math.Abs(x.Lat-c.n) > 1e-3 {
+				t.Fatalf("%v: %d %v %.4f %.4f", c.p, zone, south, x.Lng, x.Lat)
+			}
+		}
+		// 旧的级数公式在中央经线附近与新算法一致
+		x := WGS84ToCGCS2000(&Point{Lng: 117.1, Lat: 31.2}, 39500000, 117)
+		if math.Abs(x.Lng-39509530.4380) > 1e-3 || math.Abs(x.Lat-3453152.8688) > 1e-3 {
+			t.Fatalf("gk %.4f %.4f", x.Lng, x.Lat)
+		}
+	})
+	t.Run("zone", func(t *testing.T) {
+		for _, c := range []struct {
+			lng         float64
+			width, zone int
+		}{
+			{117.1, 3, 39}, {118.6, 3, 40}, {117.1, 6, 20}, {-1, 3, 120}, {1, 3, 120}, {-179, 6, 31}, {179, 6, 30},
+		} {
+			if z := GKZone(c.lng, c.width); z != c.zone {
+				t.Fatalf("%f %d: zone %d, want %d", c.lng, c.width, z, c.zone)
+			}
+		}
+		for _, c := range []struct {
+			p     Point
+			zone  int
+			south bool
+		}{
+			{Point{Lng: 117.1, Lat: 31.2}, 50, false},
+			{Point{Lng: 151.2, Lat: -33.9}, 56, true},
+			{Point{Lng: 5, Lat: 60}, 32, false},
+			{Point{Lng: 10, Lat: 78}, 33, false},
+		} {
+			if z, s := UTMZone(&c.p); z != c.zone || s != c.south {
+				t.Fatalf("%v: %d %v", c.p, z, s)
+			}
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		for _, width := range []int{3, 6} {
+			for lat := -80.0; lat <= 84; lat += 4.1 {
+				for lng := 70.0; lng <= 140; lng += 0.37 {
+					p := &Point{Lng: lng, Lat: lat}
+					x, zone, err := ToGaussKruger(p, width)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if int(x.Lng/1e6) != zone {
+						t.Fatalf("easting %f has no zone prefix %d", x.Lng, zone)
+					}
+					y, err := FromGaussKruger(x, width)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if d := GeodesicDistance(p.Lng, p.Lat, y.Lng, y.Lat); d > 1e-3 {
+						t.Fatalf("gk%d %v: round trip error %f m", width, p, d)
+					}
+					u, zone, south := ToUTM(p)
+					y, _ = FromUTM(u, zone, south)
+					if d := GeodesicDistance(p.Lng, p.Lat, y.Lng, y.Lat); d > 1e-3 {
+						t.Fatalf("utm %v: round trip error %f m", p, d)
+					}
+				}
+			}
+		}
+		if _, err := FromGaussKruger(&Point{Lng: 509530, Lat: 3453152}, 3); err == nil {
+			t.Fatal("easting without zone prefix should fail")
+		}
+		x := CGCS2000ToWGS84(WGS84ToCGCS2000v2(&Point{Lng: 118.4, Lat: 39.9}, 39500000, 117), 39500000, 117)
+		if d := GeodesicDistance(x.Lng, x.Lat, 118.4, 39.9); d > 1e-3 {
+			t.Fatalf("deprecated round trip error %f m", d)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package coord
+
+import (
+	"math"
+)
+
+// Ellipsoid 参考椭球
+type Ellipsoid struct {
+	// A 长半轴，单位米
+	A float64
+	// F 扁率
+	F float64
+}
+
+var (
+	// EllipsoidWGS84 WGS84椭球
+	EllipsoidWGS84 = Ellipsoid{A: 6378137, F: 1 / 298.257223563}
+	// EllipsoidCGCS2000 CGCS2000椭球
+	EllipsoidCGCS2000 = Ellipsoid{A: 6378137, F: 1 / 298.257222101}
+)
+
+// B 短半轴
+func (e Ellipsoid) B() float64 {
+	return e.A * (1 - e.F)
+}
+
+// Inverse 大地主题反算，计算两点间的椭球面距离(米)，起点方位角和终点方位角(度，正北顺时针，0-360)
+//
+//	优先使用Vincenty公式，近似对跖点不收敛时使用Karney的方法求解
+func (e Ellipsoid) Inverse(p1, p2 *Point) (float64, float64, float64) {
+	if s, azi1, azi2, ok := e.vincenty(p1, p2); ok {
+		return s, azi1, azi2
+	}
+	return e.karney(p1, p2)
+}
+
+// vincenty Vincenty反算，不收敛时返回false
+func (e Ellipsoid) vincenty(p1, p2 *Point) (float64, float64, float64, bool) {
+	a, f := e.A, e.F
+	b := e.B()
+	L := degRad(p2.Lng - p1.Lng)
+	tanU1 := (1 - f) * math.Tan(degRad(p1.Lat))
+	tanU2 := (1 - f) * math.Tan(degRad(p2.Lat))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	cosU2 := 1 / math.Sqrt(1+tanU2*tanU2)
+	sinU2 := tanU2 * cosU2
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 1000; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		x := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(cosU2*sinLambda*cosU2*sinLambda + x*x)
+		if sinSigma == 0 {
+			// 重合点
+			return 0, 0, 0, true
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			// 赤道线上cosSqAlpha为0
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda) > math.Pi {
+			break
+		}
+		if math.Abs(lambda-prev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return 0, 0, 0, false
+	}
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	s := b * A * (sigma - deltaSigma)
+	azi1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	azi2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+	return s, normBearing(azi1 / gc), normBearing(azi2 / gc), true
+}
+
+// Direct 大地主题正算，根据起点，方位角(度)和距离(米)计算终点和终点方位角
+func (e Ellipsoid) Direct(p *Point, bearing, dist float64) (*Point, float64) {
+	a, f := e.A, e.F
+	b := e.B()
+	sinAlpha1, cosAlpha1 := math.Sincos(degRad(bearing))
+	tanU1 := (1 - f) * math.Tan(degRad(p.Lat))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := dist / (b * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 100; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		prev := sigma
+		sigma = dist/(b*A) + deltaSigma
+		if math.Abs(sigma-prev) < 1e-12 {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-f)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+	azi2 := math.Atan2(sinAlpha, -x)
+	return &Point{Lng: normLng(p.Lng + L/gc), Lat: lat / gc}, normBearing(azi2 / gc)
+}
+
+// Intermediate 将两点间的测地线n等分，返回包括起点和终点在内的n+1个点
+func (e Ellipsoid) Intermediate(p1, p2 *Point, n int) []*Point {
+	if n < 1 {
+		n = 1
+	}
+	s, azi1, _ := e.Inverse(p1, p2)
+	ps := make([]*Point, 0, n+1)
+	ps = append(ps, &Point{Lng: p1.Lng, Lat: p1.Lat})
+	for i := 1; i < n; i++ {
+		p, _ := e.Direct(p1, azi1, s*float64(i)/float64(n))
+		ps = append(ps, p)
+	}
+	return append(ps, &Point{Lng: p2.Lng, Lat: p2.Lat})
+}
+
+// GeodesicDistance 计算WGS84椭球面上两点的距离，单位米，参数顺序与Distance相同
+func GeodesicDistance(longitude1, latitude1, longitude2, latitude2 float64) float64 {
+	s, _, _ := EllipsoidWGS84.Inverse(&Point{Lng: longitude1, Lat: latitude1}, &Point{Lng: longitude2, Lat: latitude2})
+	return s
+}
+
+// karney 按Karney(2013)的方法反算，适用于包括对跖点在内的所有情况
+//
+//	将两点变换到 φ1<=0，|φ2|<=|φ1|，λ12>=0 的标准位置后，
+//	经度差是起点方位角的单调函数，用二分法求解方位角，经度和距离积分使用数值积分计算
+func (e Ellipsoid) karney(p1, p2 *Point) (float64, float64, float64) {
+	lat1, lat2 := p1.Lat, p2.Lat
+	lam12 := degRad(normLng(p2.Lng - p1.Lng))
+	swap := math.Abs(lat1) < math.Abs(lat2)
+	if swap {
+		lat1, lat2 = lat2, lat1
+		lam12 = -lam12
+	}
+	latsign := lat1 > 0
+	if latsign {
+		lat1, lat2 = -lat1, -lat2
+	}
+	lonsign := lam12 < 0
+	if lonsign {
+		lam12 = -lam12
+	}
+	b1 := math.Atan((1 - e.F) * math.Tan(degRad(lat1)))
+	b2 := math.Atan((1 - e.F) * math.Tan(degRad(lat2)))
+	var alpha1 float64
+	var g geoLine
+	switch {
+	case math.Cos(b1) < 1e-15:
+		// 起点在极点，沿终点所在的经线，方位角为经度差
+		alpha1 = lam12
+		g = e.line(b1, b2, alpha1)
+	case b1 == 0 && b2 == 0 && lam12 <= (1-e.F)*math.Pi:
+		// 沿赤道
+		alpha1 = math.Pi / 2
+		g = geoLine{lam12: lam12, alpha2: alpha1, s: e.A * lam12}
+	default:
+		lo, hi := 0.0, math.Pi
+		for i := 0; i < 100 && hi-lo > 1e-15; i++ {
+			alpha1 = (lo + hi) / 2
+			if g = e.line(b1, b2, alpha1); g.lam12 < lam12 {
+				lo = alpha1
+			} else {
+				hi = alpha1
+			}
+		}
+		alpha1 = (lo + hi) / 2
+		g = e.line(b1, b2, alpha1)
+	}
+	azi1, azi2 := alpha1/gc, g.alpha2/gc
+	if lonsign {
+		azi1, azi2 = -azi1, -azi2
+	}
+	if latsign {
+		azi1, azi2 = 180-azi1, 180-azi2
+	}
+	if swap {
+		azi1, azi2 = azi2+180, azi1+180
+	}
+	return g.s, normBearing(azi1), normBearing(azi2)
+}
+
+// geoLine 从标准位置的起点按方位角到达终点纬度的测地线
+type geoLine struct {
+	lam12  float64
+	alpha2 float64
+	s      float64
+}
+
+// line 计算从约化纬度b1，方位角alpha1出发，向北到达约化纬度b2时的经度差，终点方位角和距离
+func (e Ellipsoid) line(b1, b2, alpha1 float64) geoLine {
+	sb1, cb1 := math.Sincos(b1)
+	sb2, cb2 := math.Sincos(b2)
+	sa1, ca1 := math.Sincos(alpha1)
+	sa0 := sa1 * cb1
+	ca0 := math.Sqrt(1 - sa0*sa0)
+	ca2 := math.Sqrt(math.Max(0, ca1*ca1*cb1*cb1+cb2*cb2-cb1*cb1)) / cb2
+	if sb1 == 0 {
+		// 起点在赤道时视为在南半球，向南出发时sigma1为-π
+		sb1 = math.Copysign(0, -1)
+	}
+	sigma1 := math.Atan2(sb1, ca1*cb1)
+	sigma2 := math.Atan2(sb2, ca2*cb2)
+	y1 := sa0 * math.Sin(sigma1)
+	if y1 == 0 {
+		y1 = math.Copysign(0, -1)
+	}
+	omega12 := math.Atan2(sa0*math.Sin(sigma2), math.Cos(sigma2)) - math.Atan2(y1, math.Cos(sigma1))
+	ep2 := e.F * (2 - e.F) / ((1 - e.F) * (1 - e.F))
+	k2 := ep2 * ca0 * ca0
+	is := gaussLegendre(sigma1, sigma2, func(x float64) float64 {
+		return math.Sqrt(1 + k2*math.Pow(math.Sin(x), 2))
+	})
+	il := gaussLegendre(sigma1, sigma2, func(x float64) float64 {
+		return (2 - e.F) / (1 + (1-e.F)*math.Sqrt(1+k2*math.Pow(math.Sin(x), 2)))
+	})
+	return geoLine{
+		lam12:  omega12 - e.F*sa0*il,
+		alpha2: math.Atan2(sa0/cb2, ca2),
+		s:      e.B() * is,
+	}
+}
+
+// gaussLegendre 分段5点高斯-勒让德积分
+func gaussLegendre(a, b float64, f func(float64) float64) float64 {
+	xs := [5]float64{-0.906179845938664, -0.5384693101056831, 0, 0.5384693101056831, 0.906179845938664}
+	ws := [5]float64{0.2369268850561891, 0.4786286704993665, 0.5688888888888889, 0.4786286704993665, 0.2369268850561891}
+	n := int(math.Abs(b-a)/0.2) + 1
+	h := (b - a) / float64(n)
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		m := a + h*(float64(i)+0.5)
+		for j, x := range xs {
+			sum += ws[j] * f(m+x*h/2)
+		}
+	}
+	return sum * h / 2
+}
+
+// normBearing 方位角规范到[0, 360)
+func normBearing(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+// normLng 经度规范到[-180, 180)
+func normLng(d float64) float64 {
+	d = math.Mod(d+180, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d - 180
+}
@@ -0,0 +1,214 @@
+package coord
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/xyzj/gopsu/json"
+)
+
+// MarshalGeoJSON 格式化为GeoJSON几何对象，如：{"type":"Point","coordinates":[121.5,31.2]}
+//
+//	空点的coordinates为[]
+func MarshalGeoJSON(g Geometry) ([]byte, error) {
+	if err := ValidateGeometry(g); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	writeGeoJSON(buf, g)
+	return buf.Bytes(), nil
+}
+
+func writeGeoJSON(buf *bytes.Buffer, g Geometry) {
+	buf.WriteString(`{"type":"` + g.GeometryType() + `",`)
+	switch x := g.(type) {
+	case *Point:
+		buf.WriteString(`"coordinates":`)
+		if x.IsEmpty() {
+			buf.WriteString("[]")
+		} else {
+			writeJSONPoint(buf, x)
+		}
+	case LineString:
+		buf.WriteString(`"coordinates":`)
+		writeJSONLine(buf, x)
+	case Polygon:
+		buf.WriteString(`"coordinates":`)
+		writeJSONPolygon(buf, x)
+	case MultiPoint:
+		buf.WriteString(`"coordinates":`)
+		writeJSONLine(buf, LineString(x))
+	case MultiLineString:
+		buf.WriteString(`"coordinates":`)
+		writeJSONPolygon(buf, Polygon(x))
+	case MultiPolygon:
+		buf.WriteString(`"coordinates":[`)
+		for i, p := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONPolygon(buf, p)
+		}
+		buf.WriteByte(']')
+	case GeometryCollection:
+		buf.WriteString(`"geometries":[`)
+		for i, c := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeGeoJSON(buf, c)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+}
+
+func writeJSONPoint(buf *bytes.Buffer, p *Point) {
+	buf.WriteString("[" + formatFloat(p.Lng) + "," + formatFloat(p.Lat) + "]")
+}
+
+func writeJSONLine(buf *bytes.Buffer, l LineString) {
+	buf.WriteByte('[')
+	for i, p := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONPoint(buf, p)
+	}
+	buf.WriteByte(']')
+}
+
+func writeJSONPolygon(buf *bytes.Buffer, p Polygon) {
+	buf.WriteByte('[')
+	for i, r := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONLine(buf, r)
+	}
+	buf.WriteByte(']')
+}
+
+// UnmarshalGeoJSON 解析GeoJSON几何对象
+//
+//	type必须是7种几何类型之一，不支持Feature，坐标只能包含经度和纬度，bbox等其他成员会被忽略
+func UnmarshalGeoJSON(b []byte) (Geometry, error) {
+	m := make(map[string]any)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("geojson: %w", err)
+	}
+	g, err := geoJSONGeometry(m)
+	if err != nil {
+		return nil, fmt.Errorf("geojson: %w", err)
+	}
+	if err := ValidateGeometry(g); err != nil {
+		return nil, fmt.Errorf("geojson: %w", err)
+	}
+	return g, nil
+}
+
+func geoJSONGeometry(m map[string]any) (Geometry, error) {
+	t, ok := m["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing type")
+	}
+	if t == TypeGeometryCollection {
+		gs, ok := m["geometries"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("geometries must be an array")
+		}
+		c := make(GeometryCollection, 0, len(gs))
+		for i, v := range gs {
+			sub, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("geometry %d: must be an object", i)
+			}
+			g, err := geoJSONGeometry(sub)
+			if err != nil {
+				return nil, fmt.Errorf("geometry %d: %w", i, err)
+			}
+			c = append(c, g)
+		}
+		return c, nil
+	}
+	coords, ok := m["coordinates"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("coordinates must be an array")
+	}
+	switch t {
+	case TypePoint:
+		if len(coords) == 0 {
+			return EmptyPoint(), nil
+		}
+		return jsonPoint(coords)
+	case TypeLineString:
+		return jsonLine(coords)
+	case TypePolygon:
+		return jsonPolygon(coords)
+	case TypeMultiPoint:
+		l, err := jsonLine(coords)
+		return MultiPoint(l), err
+	case TypeMultiLineString:
+		p, err := jsonPolygon(coords)
+		return MultiLineString(p), err
+	case TypeMultiPolygon:
+		mp := make(MultiPolygon, 0, len(coords))
+		for i, v := range coords {
+			a, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("polygon %d: must be an array", i)
+			}
+			p, err := jsonPolygon(a)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %w", i, err)
+			}
+			mp = append(mp, p)
+		}
+		return mp, nil
+	}
+	return nil, fmt.Errorf("unknown geometry type %q", t)
+}
+
+func jsonPoint(a []any) (*Point, error) {
+	if len(a) != 2 {
+		return nil, fmt.Errorf("position must have 2 numbers, got %d", len(a))
+	}
+	x, ok1 := a[0].(float64)
+	y, ok2 := a[1].(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("position must have 2 numbers")
+	}
+	return &Point{Lng: x, Lat: y}, nil
+}
+
+func jsonLine(a []any) (LineString, error) {
+	l := make(LineString, 0, len(a))
+	for i, v := range a {
+		pa, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("position %d: must be an array", i)
+		}
+		p, err := jsonPoint(pa)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", i, err)
+		}
+		l = append(l, p)
+	}
+	return l, nil
+}
+
+func jsonPolygon(a []any) (Polygon, error) {
+	p := make(Polygon, 0, len(a))
+	for i, v := range a {
+		ra, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("ring %d: must be an array", i)
+		}
+		r, err := jsonLine(ra)
+		if err != nil {
+			return nil, fmt.Errorf("ring %d: %w", i, err)
+		}
+		p = append(p, r)
+	}
+	return p, nil
+}
@@ -0,0 +1,194 @@
+package coord
+
+import (
+	"fmt"
+	"math"
+)
+
+// 几何类型名称，与GeoJSON的type一致，WKT使用大写形式
+const (
+	TypePoint              = "Point"
+	TypeLineString         = "LineString"
+	TypePolygon            = "Polygon"
+	TypeMultiPoint         = "MultiPoint"
+	TypeMultiLineString    = "MultiLineString"
+	TypeMultiPolygon       = "MultiPolygon"
+	TypeGeometryCollection = "GeometryCollection"
+)
+
+// Geometry 几何对象
+//
+//	*Point，LineString，Polygon，MultiPoint，MultiLineString，MultiPolygon，GeometryCollection
+//	只支持二维坐标，Lng对应x，Lat对应y
+type Geometry interface {
+	// GeometryType 几何类型名称
+	GeometryType() string
+	// IsEmpty 是否为空几何对象
+	IsEmpty() bool
+}
+
+// LineString 线，至少包含2个点
+type LineString []*Point
+
+// Polygon 面，第一个环为外环，其余为内环(洞)，每个环首尾相同且至少包含4个点
+type Polygon []LineString
+
+// MultiPoint 多点
+type MultiPoint []*Point
+
+// MultiLineString 多线
+type MultiLineString []LineString
+
+// MultiPolygon 多面
+type MultiPolygon []Polygon
+
+// GeometryCollection 几何对象集合
+type GeometryCollection []Geometry
+
+// EmptyPoint 返回一个空点，坐标为NaN，对应WKT的 POINT EMPTY
+func EmptyPoint() *Point {
+	return &Point{Lng: math.NaN(), Lat: math.NaN()}
+}
+
+// GeometryType 几何类型名称
+func (p *Point) GeometryType() string { return TypePoint }
+
+// IsEmpty 坐标为NaN时表示空点
+func (p *Point) IsEmpty() bool { return math.IsNaN(p.Lng) && math.IsNaN(p.Lat) }
+
+// GeometryType 几何类型名称
+func (l LineString) GeometryType() string { return TypeLineString }
+
+// IsEmpty 是否为空几何对象
+func (l LineString) IsEmpty() bool { return len(l) == 0 }
+
+// GeometryType 几何类型名称
+func (p Polygon) GeometryType() string { return TypePolygon }
+
+// IsEmpty 是否为空几何对象
+func (p Polygon) IsEmpty() bool { return len(p) == 0 }
+
+// GeometryType 几何类型名称
+func (m MultiPoint) GeometryType() string { return TypeMultiPoint }
+
+// IsEmpty 是否为空几何对象
+func (m MultiPoint) IsEmpty() bool { return len(m) == 0 }
+
+// GeometryType 几何类型名称
+func (m MultiLineString) GeometryType() string { return TypeMultiLineString }
+
+// IsEmpty 是否为空几何对象
+func (m MultiLineString) IsEmpty() bool { return len(m) == 0 }
+
+// GeometryType 几何类型名称
+func (m MultiPolygon) GeometryType() string { return TypeMultiPolygon }
+
+// IsEmpty 是否为空几何对象
+func (m MultiPolygon) IsEmpty() bool { return len(m) == 0 }
+
+// GeometryType 几何类型名称
+func (c GeometryCollection) GeometryType() string { return TypeGeometryCollection }
+
+// IsEmpty 是否为空几何对象
+func (c GeometryCollection) IsEmpty() bool { return len(c) == 0 }
+
+// ValidateGeometry 检查几何对象的结构
+//
+//	坐标必须是有限值，线至少2个点，面的环首尾相同且至少4个点，多点和集合中不能包含nil
+func ValidateGeometry(g Geometry) error {
+	switch x := g.(type) {
+	case nil:
+		return fmt.Errorf("geometry is nil")
+	case *Point:
+		if x == nil {
+			return fmt.Errorf("point is nil")
+		}
+		if x.IsEmpty() {
+			return nil
+		}
+		return checkPoint(x)
+	case LineString:
+		return checkLine(x, false)
+	case Polygon:
+		return checkPolygon(x)
+	case MultiPoint:
+		for i, p := range x {
+			if err := checkPoint(p); err != nil {
+				return fmt.Errorf("point %d: %w", i, err)
+			}
+		}
+	case MultiLineString:
+		for i, l := range x {
+			if len(l) == 0 {
+				return fmt.Errorf("linestring %d: is empty", i)
+			}
+			if err := checkLine(l, false); err != nil {
+				return fmt.Errorf("linestring %d: %w", i, err)
+			}
+		}
+	case MultiPolygon:
+		for i, p := range x {
+			if len(p) == 0 {
+				return fmt.Errorf("polygon %d: is empty", i)
+			}
+			if err := checkPolygon(p); err != nil {
+				return fmt.Errorf("polygon %d: %w", i, err)
+			}
+		}
+	case GeometryCollection:
+		for i, c := range x {
+			if err := ValidateGeometry(c); err != nil {
+				return fmt.Errorf("geometry %d: %w", i, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %T", g)
+	}
+	return nil
+}
+
+func checkPoint(p *Point) error {
+	if p == nil {
+		return fmt.Errorf("point is nil")
+	}
+	if math.IsNaN(p.Lng) || math.IsNaN(p.Lat) || math.IsInf(p.Lng, 0) || math.IsInf(p.Lat, 0) {
+		return fmt.Errorf("bad coordinate %v %v", p.Lng, p.Lat)
+	}
+	return nil
+}
+
+func checkLine(l LineString, ring bool) error {
+	if len(l) == 0 {
+		return nil
+	}
+	for i, p := range l {
+		if err := checkPoint(p); err != nil {
+			return fmt.Errorf("point %d: %w", i, err)
+		}
+	}
+	if !ring {
+		if len(l) < 2 {
+			return fmt.Errorf("linestring must have at least 2 points")
+		}
+		return nil
+	}
+	if len(l) < 4 {
+		return fmt.Errorf("ring must have at least 4 points")
+	}
+	if !l[0].Equals(l[len(l)-1]) {
+		return fmt.Errorf("ring is not closed")
+	}
+	return nil
+}
+
+func checkPolygon(p Polygon) error {
+	for i, r := range p {
+		if len(r) == 0 {
+			return fmt.Errorf("ring %d: is empty", i)
+		}
+		if err := checkLine(r, true); err != nil {
+			return fmt.Errorf("ring %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package coord
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var geoCases = []struct {
+	wkt  string
+	json string
+}{
+	{"POINT (121.5 31.25)", `{"type":"Point","coordinates":[121.5,31.25]}`},
+	{"POINT EMPTY", `{"type":"Point","coordinates":[]}`},
+	{"LINESTRING (0 0, 1 1, 2 -0.5)", `{"type":"LineString","coordinates":[[0,0],[1,1],[2,-0.5]]}`},
+	{"LINESTRING EMPTY", `{"type":"LineString","coordinates":[]}`},
+	{"POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 2 2))", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[2,2],[2,4],[4,4],[2,2]]]}`},
+	{"MULTIPOINT ((1 2), (3 4))", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+	{"MULTILINESTRING ((0 0, 1 1), (2 2, 3 3))", `{"type":"MultiLineString","coordinates":[[[0,0],[1,1]],[[2,2],[3,3]]]}`},
+	{"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5), (5.2 5.1, 5.8 5.1, 5.8 5.6, 5.2 5.1)))", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]],[[5.2,5.1],[5.8,5.1],[5.8,5.6],[5.2,5.1]]]]}`},
+	{"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 1 1), GEOMETRYCOLLECTION EMPTY)", `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[0,0],[1,1]]},{"type":"GeometryCollection","geometries":[]}]}`},
+}
+
+func TestGeometry(t *testing.T) {
+	for _, c := range geoCases {
+		t.Run(c.wkt, func(t *testing.T) {
+			g, err := UnmarshalWKT(c.wkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := MarshalWKT(g)
+			if err != nil || s != c.wkt {
+				t.Fatalf("wkt got %q %v", s, err)
+			}
+			js, err := MarshalGeoJSON(g)
+			if err != nil || string(js) != c.json {
+				t.Fatalf("geojson got %s %v", js, err)
+			}
+			gj, err := UnmarshalGeoJSON(js)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s, _ := MarshalWKT(gj); s != c.wkt {
+				t.Fatalf("geojson round trip got %q", s)
+			}
+			for _, order := range []binary.AppendByteOrder{binary.LittleEndian, binary.BigEndian} {
+				b, err := MarshalWKB(g, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gb, err := UnmarshalWKB(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s, _ := MarshalWKT(gb); s != c.wkt {
+					t.Fatalf("wkb round trip got %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestGeometryPostGIS(t *testing.T) {
+	g, err := UnmarshalWKT("srid=4326;multipoint(1 2,3 4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, MultiPoint{{Lng: 1, Lat: 2}, {Lng: 3, Lat: 4}}) {
+		t.Fatalf("got %#v", g)
+	}
+	// ST_AsBinary('POINT(1 2)')
+	b, _ := MarshalWKB(&Point{Lng: 1, Lat: 2}, nil)
+	if s := strings.ToUpper(hex.EncodeToString(b)); s != "0101000000000000000000F03F0000000000000040" {
+		t.Fatalf("wkb got %s", s)
+	}
+	// ST_AsEWKB('SRID=4326;POINT(1 2)')
+	b, _ = hex.DecodeString("0101000020E6100000000000000000F03F0000000000000040")
+	g, err = UnmarshalWKB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, &Point{Lng: 1, Lat: 2}) {
+		t.Fatalf("got %#v", g)
+	}
+}
+
+func TestGeometryStrict(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"POINT",
+		"POINT (1)",
+		"POINT (1 2 3)",
+		"POINT Z (1 2 3)",
+		"POINT (1 2",
+		"POINT (1 2) x",
+		"POINT (a 2)",
+		"POINT (NaN 2)",
+		"LINESTRING (1 2)",
+		"POLYGON ((0 0, 1 0, 1 1, 0 1))",
+		"POLYGON ((0 0, 1 0, 0 0))",
+		"POLYGON (EMPTY)",
+		"MULTILINESTRING ((0 0, 1 1), EMPTY)",
+		"CIRCLE (0 0)",
+	} {
+		if _, err := UnmarshalWKT(s); err == nil {
+			t.Fatalf("wkt %q should fail", s)
+		}
+	}
+	for _, s := range []string{
+		`{}`,
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]}}`,
+		`{"type":"Point","coordinates":[1,2,3]}`,
+		`{"type":"Point","coordinates":["1",2]}`,
+		`{"type":"Point"}`,
+		`{"type":"LineString","coordinates":[[1,2]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`,
+		`{"type":"GeometryCollection","geometries":[{"type":"Point"}]}`,
+	} {
+		if _, err := UnmarshalGeoJSON([]byte(s)); err == nil {
+			t.Fatalf("geojson %s should fail", s)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"02",
+		"0101000000000000000000F03F",
+		"0101000000000000000000F03F000000000000004000",
+		"01E9030000000000000000F03F00000000000000400000000000000840",
+		"0102000000FFFFFFFF",
+		"010400000001000000010200000000000000",
+	} {
+		b, _ := hex.DecodeString(s)
+		if _, err := UnmarshalWKB(b); err == nil {
+			t.Fatalf("wkb %s should fail", s)
+		}
+	}
+	if _, err := MarshalWKT(LineString{{Lng: 1, Lat: 2}, nil}); err == nil {
+		t.Fatal("nil point should fail")
+	}
+}
+
+func TestGeometryOps(t *testing.T) {
+	// 10x10的正方形，中间挖去2x2的洞，洞的方向与外环相同
+	pg := Polygon{
+		{{Lng: 0, Lat: 0}, {Lng: 10, Lat: 0}, {Lng: 10, Lat: 10}, {Lng: 0, Lat: 10}, {Lng: 0, Lat: 0}},
+		{{Lng: 6, Lat: 6}, {Lng: 8, Lat: 6}, {Lng: 8, Lat: 8}, {Lng: 6, Lat: 8}, {Lng: 6, Lat: 6}},
+	}
+	near := func(a, b, e float64) bool { return math.Abs(a-b) <= e }
+	t.Run("point in polygon", func(t *testing.T) {
+		for _, c := range []struct {
+			p  Point
+			in bool
+		}{
+			{Point{Lng: 1, Lat: 1}, true},
+			{Point{Lng: 0, Lat: 5}, true},
+			{Point{Lng: 10, Lat: 10}, true},
+			{Point{Lng: 7, Lat: 7}, false},
+			{Point{Lng: 6, Lat: 7}, true},
+			{Point{Lng: 11, Lat: 5}, false},
+			{Point{Lng: -0.000001, Lat: 5}, false},
+		} {
+			if PointInPolygon(&c.p, pg) != c.in {
+				t.Fatalf("%v should be %v", c.p, c.in)
+			}
+		}
+		if !PointInMultiPolygon(&Point{Lng: 1, Lat: 1}, MultiPolygon{pg[1:], pg[:1]}) {
+			t.Fatal("multipolygon failed")
+		}
+	})
+	t.Run("projected", func(t *testing.T) {
+		if a := Area(pg, Projected); a != 96 {
+			t.Fatalf("area %f", a)
+		}
+		c := Centroid(pg, Projected)
+		// (100*5 - 4*7) / 96
+		if !near(c.Lng, 472.0/96, 1e-12) || !near(c.Lat, 472.0/96, 1e-12) {
+			t.Fatalf("centroid %v", c)
+		}
+		if l := Length(pg[0], Projected); l != 40 {
+			t.Fatalf("length %f", l)
+		}
+		b, ok := Bounds(GeometryCollection{pg, &Point{Lng: -1, Lat: 20}})
+		if !ok || b.Min != (Point{Lng: -1, Lat: 0}) || b.Max != (Point{Lng: 10, Lat: 20}) || !b.Contains(&Point{Lng: 0, Lat: 15}) {
+			t.Fatalf("bounds %v", b)
+		}
+		if _, ok := Bounds(LineString{}); ok {
+			t.Fatal("empty bounds")
+		}
+		p, idx, d := NearestPoint(&Point{Lng: 5, Lat: 3}, pg[0], Projected)
+		if idx != 0 || d != 3 || *p != (Point{Lng: 5, Lat: 0}) {
+			t.Fatalf("nearest %v %d %f", p, idx, d)
+		}
+		l := LineString{{Lng: 0, Lat: 0}, {Lng: 1, Lat: 0.1}, {Lng: 2, Lat: -0.1}, {Lng: 3, Lat: 5}, {Lng: 4, Lat: 6}, {Lng: 5, Lat: 7}}
+		x := Simplify(l, 0.5, Projected)
+		if len(x) != 4 || x[1] != l[2] || x[2] != l[3] {
+			t.Fatalf("simplify %v", x)
+		}
+	})
+	t.Run("geographic", func(t *testing.T) {
+		// 赤道附近0.01度的正方形，约1113.2米
+		sq := Polygon{{{Lng: 0, Lat: 0}, {Lng: 0.01, Lat: 0}, {Lng: 0.01, Lat: 0.01}, {Lng: 0, Lat: 0.01}, {Lng: 0, Lat: 0}}}
+		side := degRad(0.01) * earthRadius
+		if a := Area(sq, Geographic); !near(a, side*side, side*side*1e-4) {
+			t.Fatalf("area %f, want %f", a, side*side)
+		}
+		if l := Length(sq[0], Geographic); !near(l, side*4, 1e-3) {
+			t.Fatalf("length %f", l)
+		}
+		c := Centroid(sq, Geographic)
+		if !near(c.Lng, 0.005, 1e-9) || !near(c.Lat, 0.005, 1e-9) {
+			t.Fatalf("centroid %v", c)
+		}
+		_, idx, d := NearestPoint(&Point{Lng: 0.005, Lat: 0.011}, sq[0], Geographic)
+		if idx != 2 || !near(d, side/10, 1e-3) {
+			t.Fatalf("nearest %d %f", idx, d)
+		}
+		// 偏离直线约11米的点被去掉
+		track := LineString{{Lng: 121, Lat: 31}, {Lng: 121.005, Lat: 31.0001}, {Lng: 121.01, Lat: 31}}
+		if x := Simplify(track, 20, Geographic); len(x) != 2 {
+			t.Fatalf("simplify %v", x)
+		}
+		if x := Simplify(track, 5, Geographic); len(x) != 3 {
+			t.Fatalf("simplify %v", x)
+		}
+	})
+}
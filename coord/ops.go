@@ -0,0 +1,315 @@
+package coord
+
+import (
+	"math"
+)
+
+// Space 坐标类型，决定长度，面积和距离的计算方式
+type Space byte
+
+const (
+	// Geographic 经纬度坐标，使用球面公式，长度和距离单位为米，面积单位为平方米
+	Geographic Space = iota
+	// Projected 平面投影坐标，如墨卡托和CGCS2000高斯投影，单位与坐标一致
+	Projected
+)
+
+// BBox 外接矩形
+type BBox struct {
+	Min Point `json:"min"`
+	Max Point `json:"max"`
+}
+
+// Contains 点是否在矩形内(包括边界)
+func (b *BBox) Contains(p *Point) bool {
+	return p.Lng >= b.Min.Lng && p.Lng <= b.Max.Lng && p.Lat >= b.Min.Lat && p.Lat <= b.Max.Lat
+}
+
+// Bounds 计算几何对象的外接矩形，空几何对象返回false
+func Bounds(g Geometry) (BBox, bool) {
+	b := BBox{
+		Min: Point{Lng: math.Inf(1), Lat: math.Inf(1)},
+		Max: Point{Lng: math.Inf(-1), Lat: math.Inf(-1)},
+	}
+	eachPoint(g, func(p *Point) {
+		b.Min.Lng = math.Min(b.Min.Lng, p.Lng)
+		b.Min.Lat = math.Min(b.Min.Lat, p.Lat)
+		b.Max.Lng = math.Max(b.Max.Lng, p.Lng)
+		b.Max.Lat = math.Max(b.Max.Lat, p.Lat)
+	})
+	return b, !math.IsInf(b.Min.Lng, 1)
+}
+
+func eachPoint(g Geometry, f func(*Point)) {
+	switch x := g.(type) {
+	case *Point:
+		if !x.IsEmpty() {
+			f(x)
+		}
+	case LineString:
+		for _, p := range x {
+			f(p)
+		}
+	case MultiPoint:
+		for _, p := range x {
+			f(p)
+		}
+	case Polygon:
+		for _, r := range x {
+			eachPoint(r, f)
+		}
+	case MultiLineString:
+		for _, l := range x {
+			eachPoint(l, f)
+		}
+	case MultiPolygon:
+		for _, p := range x {
+			eachPoint(p, f)
+		}
+	case GeometryCollection:
+		for _, c := range x {
+			eachPoint(c, f)
+		}
+	}
+}
+
+// PointInPolygon 判断点是否在面内，边界上的点视为在面内，在洞内的点视为在面外
+//
+//	经纬度和平面坐标均按平面计算，适用于不跨越180度经线的面
+func PointInPolygon(p *Point, pg Polygon) bool {
+	if len(pg) == 0 || !inRing(p, pg[0]) {
+		return false
+	}
+	for _, h := range pg[1:] {
+		if inRing(p, h) && !onRing(p, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// PointInMultiPolygon 判断点是否在任意一个面内
+func PointInMultiPolygon(p *Point, mp MultiPolygon) bool {
+	for _, pg := range mp {
+		if PointInPolygon(p, pg) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRing 射线法判断点是否在环内，包括边界
+func inRing(p *Point, r LineString) bool {
+	if onRing(p, r) {
+		return true
+	}
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			in = !in
+		}
+	}
+	return in
+}
+
+func onRing(p *Point, r LineString) bool {
+	for i := 1; i < len(r); i++ {
+		if onSegment(p, r[i-1], r[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func onSegment(p, a, b *Point) bool {
+	cross := (b.Lng-a.Lng)*(p.Lat-a.Lat) - (b.Lat-a.Lat)*(p.Lng-a.Lng)
+	scale := math.Max(math.Abs(b.Lng-a.Lng), math.Abs(b.Lat-a.Lat))
+	if math.Abs(cross) > 1e-12*math.Max(scale, 1) {
+		return false
+	}
+	return p.Lng >= math.Min(a.Lng, b.Lng) && p.Lng <= math.Max(a.Lng, b.Lng) &&
+		p.Lat >= math.Min(a.Lat, b.Lat) && p.Lat <= math.Max(a.Lat, b.Lat)
+}
+
+// Area 计算面积，外环面积减去洞的面积
+func Area(pg Polygon, s Space) float64 {
+	if len(pg) == 0 {
+		return 0
+	}
+	a := ringArea(pg[0], s)
+	for _, h := range pg[1:] {
+		a -= ringArea(h, s)
+	}
+	return a
+}
+
+// ringArea 环的面积，与环的方向无关
+func ringArea(r LineString, s Space) float64 {
+	a := 0.0
+	for i := 1; i < len(r); i++ {
+		p1, p2 := r[i-1], r[i]
+		if s == Geographic {
+			a += degRad(p2.Lng-p1.Lng) * (2 + math.Sin(degRad(p1.Lat)) + math.Sin(degRad(p2.Lat)))
+		} else {
+			a += p1.Lng*p2.Lat - p2.Lng*p1.Lat
+		}
+	}
+	if s == Geographic {
+		return math.Abs(a * earthRadius * earthRadius / 2)
+	}
+	return math.Abs(a / 2)
+}
+
+// Centroid 计算面的质心，面积为0时返回所有顶点的平均值
+//
+//	经纬度坐标使用以第一个点为中心的等距圆柱投影计算，适用于城市范围的面
+func Centroid(pg Polygon, s Space) *Point {
+	if len(pg) == 0 || len(pg[0]) == 0 {
+		return EmptyPoint()
+	}
+	o := pg[0][0]
+	k := 1.0
+	if s == Geographic {
+		k = math.Cos(degRad(o.Lat))
+	}
+	var sa, sx, sy, n, ax, ay float64
+	for i, r := range pg {
+		var a, cx, cy float64
+		for j := 1; j < len(r); j++ {
+			x1, y1 := (r[j-1].Lng-o.Lng)*k, r[j-1].Lat-o.Lat
+			x2, y2 := (r[j].Lng-o.Lng)*k, r[j].Lat-o.Lat
+			c := x1*y2 - x2*y1
+			a += c
+			cx += (x1 + x2) * c
+			cy += (y1 + y2) * c
+			ax += x1
+			ay += y1
+			n++
+		}
+		if a == 0 {
+			continue
+		}
+		// 统一环的方向，外环加，洞减
+		f := math.Copysign(1, a)
+		if i > 0 {
+			f = -f
+		}
+		sa += f * a
+		sx += f * cx / 3
+		sy += f * cy / 3
+	}
+	switch {
+	case n == 0:
+		return &Point{Lng: o.Lng, Lat: o.Lat}
+	case sa == 0:
+		return &Point{Lng: o.Lng + ax/n/k, Lat: o.Lat + ay/n}
+	}
+	return &Point{Lng: o.Lng + sx/sa/k, Lat: o.Lat + sy/sa}
+}
+
+// Length 计算线的长度
+func Length(l LineString, s Space) float64 {
+	d := 0.0
+	for i := 1; i < len(l); i++ {
+		d += distance(l[i-1], l[i], s)
+	}
+	return d
+}
+
+func distance(a, b *Point, s Space) float64 {
+	if s == Geographic {
+		return Distance(a.Lng, a.Lat, b.Lng, b.Lat)
+	}
+	return math.Hypot(b.Lng-a.Lng, b.Lat-a.Lat)
+}
+
+// NearestPoint 计算线上离p最近的点
+//
+//	返回最近点，最近点所在线段的起点序号，以及距离，线为空时序号为-1
+//	经纬度坐标使用以p为中心的等距圆柱投影查找最近点
+func NearestPoint(p *Point, l LineString, s Space) (*Point, int, float64) {
+	if len(l) == 0 {
+		return EmptyPoint(), -1, math.Inf(1)
+	}
+	if len(l) == 1 {
+		return &Point{Lng: l[0].Lng, Lat: l[0].Lat}, 0, distance(p, l[0], s)
+	}
+	k := 1.0
+	if s == Geographic {
+		k = math.Cos(degRad(p.Lat))
+	}
+	best, idx, bd := &Point{}, -1, math.Inf(1)
+	for i := 1; i < len(l); i++ {
+		ax, ay := (l[i-1].Lng-p.Lng)*k, l[i-1].Lat-p.Lat
+		bx, by := (l[i].Lng-p.Lng)*k, l[i].Lat-p.Lat
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if dd := dx*dx + dy*dy; dd > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/dd))
+		}
+		x, y := ax+t*dx, ay+t*dy
+		if d := x*x + y*y; d < bd {
+			bd = d
+			idx = i - 1
+			best = &Point{Lng: p.Lng + x/k, Lat: p.Lat + y}
+		}
+	}
+	return best, idx, distance(p, best, s)
+}
+
+// Simplify 使用Douglas-Peucker算法简化线，返回的切片引用原来的点
+//
+//	tolerance: 允许的最大偏差，经纬度坐标单位为米
+func Simplify(l LineString, tolerance float64, s Space) LineString {
+	if len(l) < 3 {
+		return append(LineString{}, l...)
+	}
+	xs := make([][2]float64, len(l))
+	o := l[0]
+	for i, p := range l {
+		if s == Geographic {
+			xs[i] = [2]float64{
+				degRad(p.Lng-o.Lng) * math.Cos(degRad(o.Lat)) * earthRadius,
+				degRad(p.Lat-o.Lat) * earthRadius,
+			}
+		} else {
+			xs[i] = [2]float64{p.Lng, p.Lat}
+		}
+	}
+	keep := make([]bool, len(l))
+	keep[0], keep[len(l)-1] = true, true
+	stack := [][2]int{{0, len(l) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		idx, md := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segDistance(xs[i], xs[first], xs[last]); d > md {
+				idx, md = i, d
+			}
+		}
+		if idx >= 0 {
+			keep[idx] = true
+			stack = append(stack, [2]int{first, idx}, [2]int{idx, last})
+		}
+	}
+	x := make(LineString, 0, len(l))
+	for i, p := range l {
+		if keep[i] {
+			x = append(x, p)
+		}
+	}
+	return x
+}
+
+// segDistance 点到线段的平面距离
+func segDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if dd := dx*dx + dy*dy; dd > 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/dd))
+	}
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
@@ -0,0 +1,234 @@
+package coord
+
+import (
+	"fmt"
+	"math"
+)
+
+// Projection 横轴墨卡托投影，高斯-克吕格投影和UTM投影均使用该投影
+//
+//	使用Krüger的6阶级数展开(Karney 2011)，投影带内误差小于1毫米
+//	投影坐标的Lng为东坐标，Lat为北坐标，单位米
+type Projection struct {
+	// Ellipsoid 参考椭球
+	Ellipsoid Ellipsoid
+	// Meridian 中央经线，单位度
+	Meridian float64
+	// Scale 中央经线比例因子，高斯-克吕格为1，UTM为0.9996
+	Scale float64
+	// FalseEasting 东偏移，不包括带号前缀
+	FalseEasting float64
+	// FalseNorthing 北偏移
+	FalseNorthing float64
+	// Zone 带号，ZonePrefix为true时，东坐标加上 Zone*1000000
+	Zone       int
+	ZonePrefix bool
+}
+
+// tmSeries Krüger级数系数
+type tmSeries struct {
+	A     float64
+	e     float64
+	alpha [6]float64
+	beta  [6]float64
+}
+
+func (e Ellipsoid) tmSeries() tmSeries {
+	n := e.F / (2 - e.F)
+	n2, n3, n4, n5, n6 := n*n, n*n*n, n*n*n*n, n*n*n*n*n, n*n*n*n*n*n
+	return tmSeries{
+		A: e.A / (1 + n) * (1 + n2/4 + n4/64 + n6/256),
+		e: math.Sqrt(e.F * (2 - e.F)),
+		alpha: [6]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+			13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+			61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+			49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+			34729*n5/80640 - 3418889*n6/1995840,
+			212378941 * n6 / 319334400,
+		},
+		beta: [6]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+			n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+			17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+			4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+			4583*n5/161280 - 108847*n6/3991680,
+			20648693 * n6 / 638668800,
+		},
+	}
+}
+
+// falseEasting 包括带号前缀的东偏移
+func (p *Projection) falseEasting() float64 {
+	if p.ZonePrefix {
+		return p.FalseEasting + float64(p.Zone)*1e6
+	}
+	return p.FalseEasting
+}
+
+// Forward 经纬度->投影坐标
+func (p *Projection) Forward(pt *Point) *Point {
+	s := p.Ellipsoid.tmSeries()
+	lambda := degRad(normLng(pt.Lng - p.Meridian))
+	tau := math.Tan(degRad(pt.Lat))
+	sigma := math.Sinh(s.e * math.Atanh(s.e*tau/math.Sqrt(1+tau*tau)))
+	taup := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+	sinL, cosL := math.Sincos(lambda)
+	xip := math.Atan2(taup, cosL)
+	etap := math.Asinh(sinL / math.Sqrt(taup*taup+cosL*cosL))
+	xi, eta := xip, etap
+	for j, a := range s.alpha {
+		k := 2 * float64(j+1)
+		xi += a * math.Sin(k*xip) * math.Cosh(k*etap)
+		eta += a * math.Cos(k*xip) * math.Sinh(k*etap)
+	}
+	return &Point{
+		Lng: p.Scale*s.A*eta + p.falseEasting(),
+		Lat: p.Scale*s.A*xi + p.FalseNorthing,
+	}
+}
+
+// Inverse 投影坐标->经纬度
+func (p *Projection) Inverse(pt *Point) *Point {
+	s := p.Ellipsoid.tmSeries()
+	eta := (pt.Lng - p.falseEasting()) / (p.Scale * s.A)
+	xi := (pt.Lat - p.FalseNorthing) / (p.Scale * s.A)
+	xip, etap := xi, eta
+	for j, b := range s.beta {
+		k := 2 * float64(j+1)
+		xip -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etap -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	sinhEtap := math.Sinh(etap)
+	sinXip, cosXip := math.Sincos(xip)
+	taup := sinXip / math.Sqrt(sinhEtap*sinhEtap+cosXip*cosXip)
+	// 牛顿迭代求tan(φ)
+	e2 := s.e * s.e
+	tau := taup
+	for i := 0; i < 10; i++ {
+		sigma := math.Sinh(s.e * math.Atanh(s.e*tau/math.Sqrt(1+tau*tau)))
+		taui := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		d := (taup - taui) / math.Sqrt(1+taui*taui) * (1 + (1-e2)*tau*tau) / ((1 - e2) * math.Sqrt(1+tau*tau))
+		tau += d
+		if math.Abs(d) < 1e-12 {
+			break
+		}
+	}
+	return &Point{
+		Lng: normLng(p.Meridian + math.Atan2(sinhEtap, cosXip)/gc),
+		Lat: math.Atan(tau) / gc,
+	}
+}
+
+// GKZone 根据经度计算高斯-克吕格投影带号
+//
+//	width: 3或6，3度带中央经线为 3*zone，6度带为 6*zone-3
+func GKZone(lng float64, width int) int {
+	lng = normLng(lng) + 180
+	if width == 3 {
+		return (int(math.Floor((lng+1.5)/3))+59)%120 + 1
+	}
+	return (int(math.Floor(lng/6))+30)%60 + 1
+}
+
+// GaussKruger 创建CGCS2000椭球的高斯-克吕格投影
+//
+//	width: 3或6
+//	zone: 带号
+//	prefix: 东坐标是否带带号前缀
+func GaussKruger(width, zone int, prefix bool) (*Projection, error) {
+	if width != 3 && width != 6 {
+		return nil, fmt.Errorf("zone width must be 3 or 6")
+	}
+	if err := checkZone(width, zone); err != nil {
+		return nil, err
+	}
+	meridian := float64(width * zone)
+	if width == 6 {
+		meridian -= 3
+	}
+	return &Projection{
+		Ellipsoid:    EllipsoidCGCS2000,
+		Meridian:     normLng(meridian),
+		Scale:        1,
+		FalseEasting: 500000,
+		Zone:         zone,
+		ZonePrefix:   prefix,
+	}, nil
+}
+
+// ToGaussKruger 经纬度->高斯-克吕格坐标，根据经度自动选择投影带，东坐标带带号前缀，返回坐标和带号
+func ToGaussKruger(p *Point, width int) (*Point, int, error) {
+	zone := GKZone(p.Lng, width)
+	pj, err := GaussKruger(width, zone, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pj.Forward(p), zone, nil
+}
+
+// FromGaussKruger 带带号前缀的高斯-克吕格坐标->经纬度，根据东坐标的前缀确定投影带
+func FromGaussKruger(p *Point, width int) (*Point, error) {
+	zone := int(math.Floor(p.Lng / 1e6))
+	pj, err := GaussKruger(width, zone, true)
+	if err != nil {
+		return nil, fmt.Errorf("easting %.3f has no valid zone prefix: %w", p.Lng, err)
+	}
+	return pj.Inverse(p), nil
+}
+
+// UTMZone 根据经纬度计算UTM投影带号，包括挪威和斯瓦尔巴群岛的特殊分带，返回带号和是否为南半球
+func UTMZone(p *Point) (int, bool) {
+	lng := normLng(p.Lng)
+	zone := int(math.Floor((lng+180)/6))%60 + 1
+	switch {
+	case p.Lat >= 56 && p.Lat < 64 && lng >= 3 && lng < 12:
+		zone = 32
+	case p.Lat >= 72 && p.Lat <= 84 && lng >= 0 && lng < 42:
+		switch {
+		case lng < 9:
+			zone = 31
+		case lng < 21:
+			zone = 33
+		case lng < 33:
+			zone = 35
+		default:
+			zone = 37
+		}
+	}
+	return zone, p.Lat < 0
+}
+
+// UTM 创建WGS84椭球的UTM投影
+func UTM(zone int, south bool) (*Projection, error) {
+	if err := checkZone(6, zone); err != nil {
+		return nil, err
+	}
+	pj := &Projection{
+		Ellipsoid:    EllipsoidWGS84,
+		Meridian:     float64(6*zone - 183),
+		Scale:        0.9996,
+		FalseEasting: 500000,
+		Zone:         zone,
+	}
+	if south {
+		pj.FalseNorthing = 10000000
+	}
+	return pj, nil
+}
+
+// ToUTM 经纬度->UTM坐标，根据经纬度自动选择投影带，返回坐标，带号和是否为南半球
+func ToUTM(p *Point) (*Point, int, bool) {
+	zone, south := UTMZone(p)
+	pj, _ := UTM(zone, south)
+	return pj.Forward(p), zone, south
+}
+
+// FromUTM UTM坐标->经纬度
+func FromUTM(p *Point, zone int, south bool) (*Point, error) {
+	pj, err := UTM(zone, south)
+	if err != nil {
+		return nil, err
+	}
+	return pj.Inverse(p), nil
+}
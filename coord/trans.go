@@ -109,6 +109,8 @@ func degRad(ang float64) float64 {
 }
 
 // Distance computes the distance between two given coordinates in meter
+//
+//	球面公式，误差可达0.5%，需要精确距离时使用GeodesicDistance
 func Distance(longitude1, latitude1, longitude2, latitude2 float64) float64 {
 	radLat1 := degRad(latitude1)
 	radLat2 := degRad(latitude2)
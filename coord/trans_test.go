@@ -0,0 +1,121 @@
+package coord
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTransformer(t *testing.T) {
+	wgs := &Point{Lng: 121.4737, Lat: 31.2304}
+	t.Run("gcj02 inverse", func(t *testing.T) {
+		gcj := WGS84toGCJ02(wgs)
+		fast := GCJ02toWGS84(gcj)
+		exact := GCJ02toWGS84Exact(gcj)
+		if d := Distance(wgs.Lng, wgs.Lat, exact.Lng, exact.Lat); d > 1e-6 {
+			t.Fatalf("exact inverse error %f m", d)
+		}
+		t.Logf("fast inverse error %f m", Distance(wgs.Lng, wgs.Lat, fast.Lng, fast.Lat))
+	})
+	t.Run("path", func(t *testing.T) {
+		for _, c := range []struct {
+			src, dst CRS
+			n        int
+		}{
+			{CRSWGS84, CRSWGS84, 1},
+			{CRSWGS84, CRSBD09MC, 4},
+			{"webmercator", "bd09", 4},
+			{"EPSG:4527", CRSGCJ02, 3},
+			{CRSGaussKruger(6, 20), CRSGaussKruger(3, 39), 3},
+			{"EPSG:32650", CRSUTM(51, false), 3},
+		} {
+			tf, err := NewTransformer(c.src, c.dst, PrecisionHigh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tf.Path()) != c.n {
+				t.Fatalf("%s -> %s: %v", c.src, c.dst, tf.Path())
+			}
+		}
+		if _, err := NewTransformer("WGS84", "EPSG:9999", PrecisionHigh); err == nil {
+			t.Fatal("unknown crs should fail")
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		for _, dst := range []CRS{CRSGCJ02, CRSBD09, CRSBD09MC, CRSWebMercator, CRSGaussKruger(3, 40), CRSGaussKruger(6, 21)} {
+			fw, err := NewTransformer(CRSWGS84, dst, PrecisionHigh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bw, _ := NewTransformer(dst, CRSWGS84, PrecisionHigh)
+			ps := bw.TransformSlice(fw.TransformSlice([]*Point{wgs, {Lng: 120.5, Lat: 30.1}}))
+			for i, p := range []*Point{wgs, {Lng: 120.5, Lat: 30.1}} {
+				// 百度墨卡托反算为近似算法
+				if d := Distance(p.Lng, p.Lat, ps[i].Lng, ps[i].Lat); d > 0.1 {
+					t.Fatalf("%s: round trip error %f m", dst, d)
+				}
+			}
+		}
+		tf, _ := NewTransformer(CRSWGS84, CRSGCJ02, PrecisionFast)
+		pg := Polygon{{{Lng: 121, Lat: 31}, {Lng: 122, Lat: 31}, {Lng: 122, Lat: 32}, {Lng: 121, Lat: 31}}}
+		x := tf.TransformGeometry(pg).(Polygon)
+		if math.Abs(x[0][0].Lng-WGS84toGCJ02(pg[0][0]).Lng) > 1e-12 || pg[0][0].Lng != 121 {
+			t.Fatal("transform geometry failed")
+		}
+	})
+}
+
+func TestGeodesic(t *testing.T) {
+	dms := func(d, m, s float64) float64 {
+		return math.Copysign(math.Abs(d)+m/60+s/3600, d)
+	}
+	// Vincenty(1975)，Geoscience Australia 算例：Flinders Peak -> Buninyong，GRS80椭球
+	flinders := &Point{Lng: dms(144, 25, 29.52440), Lat: dms(-37, 57, 3.72030)}
+	buninyong := &Point{Lng: dms(143, 55, 35.38390), Lat: dms(-37, 39, 10.15610)}
+	s, azi1, azi2 := EllipsoidCGCS2000.Inverse(flinders, buninyong)
+	if math.Abs(s-54972.271) > 1e-3 || math.Abs(azi1-dms(306, 52, 5.37)) > 0.01/3600 || math.Abs(azi2-dms(307, 10, 25.07)) > 0.01/3600 {
+		t.Fatalf("inverse %f %f %f", s, azi1, azi2)
+	}
+	p, azi := EllipsoidCGCS2000.Direct(flinders, dms(306, 52, 5.37), 54972.271)
+	if math.Abs(p.Lng-buninyong.Lng) > 1e-4/3600 || math.Abs(p.Lat-buninyong.Lat) > 1e-4/3600 || math.Abs(azi-dms(307, 10, 25.07)) > 0.01/3600 {
+		t.Fatalf("direct %v %f", p, azi)
+	}
+	// Karney(2013) GeographicLib 算例：Wellington -> Salamanca，近似对跖点，Vincenty不收敛
+	wellington, salamanca := &Point{Lng: 174.81, Lat: -41.32}, &Point{Lng: -5.5, Lat: 40.96}
+	s, azi1, azi2 = EllipsoidWGS84.Inverse(wellington, salamanca)
+	if math.Abs(s-19959679.267353) > 1e-3 || math.Abs(azi1-161.06766998615) > 1e-9 || math.Abs(azi2-18.825195123248) > 1e-9 {
+		t.Fatalf("inverse %f %.12f %.12f", s, azi1, azi2)
+	}
+	p, _ = EllipsoidWGS84.Direct(wellington, azi1, s)
+	if d := GeodesicDistance(p.Lng, p.Lat, salamanca.Lng, salamanca.Lat); d > 1e-3 {
+		t.Fatalf("direct error %f m", d)
+	}
+	// 对称和边界情况
+	for _, c := range [][4]float64{
+		{0, 0, 179.9, 0},
+		{0, 0, 90, 0},
+		{0, -90, 50, 10},
+		{121.47, 31.23, 116.4, 39.9},
+		{-73.78, 40.64, -0.45, 51.47},
+	} {
+		p1, p2 := &Point{Lng: c[0], Lat: c[1]}, &Point{Lng: c[2], Lat: c[3]}
+		s, azi1, _ := EllipsoidWGS84.Inverse(p1, p2)
+		s2, _, _ := EllipsoidWGS84.Inverse(p2, p1)
+		p, _ := EllipsoidWGS84.Direct(p1, azi1, s)
+		if math.Abs(s-s2) > 1e-3 || GeodesicDistance(p.Lng, p.Lat, p2.Lng, p2.Lat) > 1e-3 {
+			t.Fatalf("%v: %f %f %v", c, s, s2, p)
+		}
+		// 球面公式误差小于0.5%
+		if h := Distance(c[0], c[1], c[2], c[3]); math.Abs(h-s)/s > 0.005 {
+			t.Fatalf("%v: haversine %f, geodesic %f", c, h, s)
+		}
+	}
+	ps := EllipsoidWGS84.Intermediate(flinders, buninyong, 4)
+	if len(ps) != 5 || *ps[4] != *buninyong {
+		t.Fatalf("intermediate %v", ps)
+	}
+	for i := 1; i < len(ps); i++ {
+		if d := GeodesicDistance(ps[i-1].Lng, ps[i-1].Lat, ps[i].Lng, ps[i].Lat); math.Abs(d-54972.271/4) > 1e-2 {
+			t.Fatalf("intermediate segment %d: %f", i, d)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package coord
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CRS 坐标系名称
+type CRS string
+
+const (
+	// CRSWGS84 WGS84经纬度，CGCS2000经纬度与其差异在厘米级，视为相同
+	CRSWGS84 CRS = "WGS84"
+	// CRSGCJ02 火星坐标系经纬度
+	CRSGCJ02 CRS = "GCJ02"
+	// CRSBD09 百度经纬度
+	CRSBD09 CRS = "BD09"
+	// CRSBD09MC 百度墨卡托，单位米
+	CRSBD09MC CRS = "BD09MC"
+	// CRSWebMercator web墨卡托，单位米
+	CRSWebMercator CRS = "WebMercator"
+)
+
+// Precision 转换精度
+type Precision byte
+
+const (
+	// PrecisionFast 反算使用一步近似，GCJ02->WGS84误差约2米
+	PrecisionFast Precision = iota
+	// PrecisionHigh 反算使用迭代，误差小于1e-9度
+	PrecisionHigh
+)
+
+var (
+	crsAlias = map[string]CRS{
+		"WGS84":       CRSWGS84,
+		"EPSG:4326":   CRSWGS84,
+		"CGCS2000":    CRSWGS84,
+		"EPSG:4490":   CRSWGS84,
+		"GCJ02":       CRSGCJ02,
+		"BD09":        CRSBD09,
+		"BD09LL":      CRSBD09,
+		"BD09MC":      CRSBD09MC,
+		"WEBMERCATOR": CRSWebMercator,
+		"EPSG:3857":   CRSWebMercator,
+	}
+	gkName  = regexp.MustCompile(`(?i)^CGCS2000\s*/\s*(3-degree\s+)?Gauss-Kr(?:u|ü)ger\s+zone\s+(\d+)$`)
+	utmName = regexp.MustCompile(`(?i)^WGS\s*84\s*/\s*UTM\s+zone\s+(\d+)([NS])$`)
+)
+
+// CRSGaussKruger CGCS2000高斯-克吕格投影坐标系名称，东坐标带有带号前缀
+//
+//	width: 3或6，3度带或6度带
+//	zone: 带号，3度带中央经线为 3*zone，6度带为 6*zone-3
+func CRSGaussKruger(width, zone int) CRS {
+	if width == 3 {
+		return CRS(fmt.Sprintf("CGCS2000 / 3-degree Gauss-Kruger zone %d", zone))
+	}
+	return CRS(fmt.Sprintf("CGCS2000 / Gauss-Kruger zone %d", zone))
+}
+
+// CRSUTM WGS84 UTM投影坐标系名称
+func CRSUTM(zone int, south bool) CRS {
+	if south {
+		return CRS(fmt.Sprintf("WGS 84 / UTM zone %dS", zone))
+	}
+	return CRS(fmt.Sprintf("WGS 84 / UTM zone %dN", zone))
+}
+
+// ParseCRS 解析坐标系名称，不区分大小写，忽略`-`，`_`和空格
+//
+//	支持：WGS84，GCJ02，BD09，BD09MC，WebMercator，EPSG:4326，EPSG:4490，EPSG:3857，
+//	CGCS2000 / 3-degree Gauss-Kruger zone N(EPSG:4513-4533)，CGCS2000 / Gauss-Kruger zone N(EPSG:4491-4501)，
+//	WGS 84 / UTM zone NN或NS(EPSG:32601-32660，32701-32760)
+func ParseCRS(s string) (CRS, error) {
+	s = strings.TrimSpace(s)
+	if m := utmName.FindStringSubmatch(s); m != nil {
+		zone, _ := strconv.Atoi(m[1])
+		if err := checkZone(6, zone); err != nil {
+			return "", err
+		}
+		return CRSUTM(zone, strings.EqualFold(m[2], "S")), nil
+	}
+	if m := gkName.FindStringSubmatch(s); m != nil {
+		zone, _ := strconv.Atoi(m[2])
+		width := 6
+		if m[1] != "" {
+			width = 3
+		}
+		if err := checkZone(width, zone); err != nil {
+			return "", err
+		}
+		return CRSGaussKruger(width, zone), nil
+	}
+	key := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToUpper(s))
+	if c, ok := crsAlias[key]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(key, "EPSG:") {
+		code, _ := strconv.Atoi(key[5:])
+		switch {
+		case code >= 4513 && code <= 4533:
+			return CRSGaussKruger(3, code-4513+25), nil
+		case code >= 4491 && code <= 4501:
+			return CRSGaussKruger(6, code-4491+13), nil
+		case code >= 32601 && code <= 32660:
+			return CRSUTM(code-32600, false), nil
+		case code >= 32701 && code <= 32760:
+			return CRSUTM(code-32700, true), nil
+		}
+	}
+	return "", fmt.Errorf("unknown crs %q", s)
+}
+
+func checkZone(width, zone int) error {
+	if zone < 1 || zone > 360/width {
+		return fmt.Errorf("bad %d-degree zone %d", width, zone)
+	}
+	return nil
+}
+
+// crsProjection 返回投影坐标系的投影，其他坐标系返回nil
+func crsProjection(c CRS) *Projection {
+	if m := gkName.FindStringSubmatch(string(c)); m != nil {
+		zone, _ := strconv.Atoi(m[2])
+		width := 6
+		if m[1] != "" {
+			width = 3
+		}
+		pj, _ := GaussKruger(width, zone, true)
+		return pj
+	}
+	if m := utmName.FindStringSubmatch(string(c)); m != nil {
+		zone, _ := strconv.Atoi(m[1])
+		pj, _ := UTM(zone, strings.EqualFold(m[2], "S"))
+		return pj
+	}
+	return nil
+}
+
+// GCJ02toWGS84Exact 火星坐标系->WGS84坐标系，迭代反算
+func GCJ02toWGS84Exact(p *Point) *Point {
+	return iterInverse(p, WGS84toGCJ02, GCJ02toWGS84)
+}
+
+// BD09toGCJ02Exact 百度坐标系->火星坐标系，迭代反算
+func BD09toGCJ02Exact(p *Point) *Point {
+	return iterInverse(p, GCJ02toBD09, BD09toGCJ02)
+}
+
+// iterInverse 以近似反算结果为初值，迭代修正到正算结果与目标一致
+func iterInverse(p *Point, forward, approx func(*Point) *Point) *Point {
+	x := approx(p)
+	x = &Point{Lng: x.Lng, Lat: x.Lat}
+	for i := 0; i < 30; i++ {
+		y := forward(x)
+		dx, dy := p.Lng-y.Lng, p.Lat-y.Lat
+		x.Lng += dx
+		x.Lat += dy
+		if math.Abs(dx) < 1e-12 && math.Abs(dy) < 1e-12 {
+			break
+		}
+	}
+	return x
+}
+
+// crsEdge 两个坐标系之间的直接转换
+type crsEdge struct {
+	to   CRS
+	conv func(*Point) *Point
+}
+
+// Transformer 坐标系转换器，自动查找转换路径，可以并发使用
+type Transformer struct {
+	path  []CRS
+	steps []func(*Point) *Point
+}
+
+// NewTransformer 创建坐标系转换器
+//
+//	src，dst: 坐标系名称，参见ParseCRS
+//	precision: 反算精度
+func NewTransformer(src, dst CRS, precision Precision) (*Transformer, error) {
+	var err error
+	if src, err = ParseCRS(string(src)); err != nil {
+		return nil, err
+	}
+	if dst, err = ParseCRS(string(dst)); err != nil {
+		return nil, err
+	}
+	g := crsGraph(precision, src, dst)
+	// 广度优先查找最短路径
+	prev := map[CRS]crsEdge{src: {}}
+	from := map[CRS]CRS{}
+	queue := []CRS{src}
+	for len(queue) > 0 && dst != src {
+		c := queue[0]
+		queue = queue[1:]
+		for _, e := range g[c] {
+			if _, ok := prev[e.to]; ok {
+				continue
+			}
+			prev[e.to] = e
+			from[e.to] = c
+			queue = append(queue, e.to)
+		}
+		if _, ok := prev[dst]; ok {
+			break
+		}
+	}
+	if _, ok := prev[dst]; !ok {
+		return nil, fmt.Errorf("no conversion from %s to %s", src, dst)
+	}
+	t := &Transformer{path: []CRS{dst}}
+	for c := dst; c != src; c = from[c] {
+		t.steps = append([]func(*Point) *Point{prev[c].conv}, t.steps...)
+		t.path = append([]CRS{from[c]}, t.path...)
+	}
+	return t, nil
+}
+
+// crsGraph 坐标系转换关系，投影坐标系只添加src和dst
+func crsGraph(precision Precision, cs ...CRS) map[CRS][]crsEdge {
+	gcj2wgs, bd2gcj := GCJ02toWGS84, BD09toGCJ02
+	if precision == PrecisionHigh {
+		gcj2wgs, bd2gcj = GCJ02toWGS84Exact, BD09toGCJ02Exact
+	}
+	g := map[CRS][]crsEdge{
+		CRSWGS84: {
+			{CRSGCJ02, WGS84toGCJ02},
+			{CRSWebMercator, ToMercator},
+		},
+		CRSGCJ02: {
+			{CRSWGS84, gcj2wgs},
+			{CRSBD09, GCJ02toBD09},
+		},
+		CRSBD09: {
+			{CRSGCJ02, bd2gcj},
+			{CRSBD09MC, func(p *Point) *Point {
+				x := BD09toBD09MC(&Point{Lng: p.Lng, Lat: p.Lat})
+				return &x
+			}},
+		},
+		CRSBD09MC: {
+			{CRSBD09, func(p *Point) *Point {
+				x := DB09MctoBD09(p)
+				return &x
+			}},
+		},
+		CRSWebMercator: {
+			{CRSWGS84, FromMercator},
+		},
+	}
+	for _, c := range cs {
+		pj := crsProjection(c)
+		if pj == nil {
+			continue
+		}
+		g[CRSWGS84] = append(g[CRSWGS84], crsEdge{c, pj.Forward})
+		g[c] = []crsEdge{{CRSWGS84, pj.Inverse}}
+	}
+	return g
+}
+
+// Path 转换路径，包含源坐标系和目标坐标系
+func (t *Transformer) Path() []CRS {
+	return append([]CRS{}, t.path...)
+}
+
+// Transform 转换一个点，返回新的点
+func (t *Transformer) Transform(p *Point) *Point {
+	x := &Point{Lng: p.Lng, Lat: p.Lat}
+	for _, f := range t.steps {
+		x = f(x)
+	}
+	return x
+}
+
+// TransformSlice 批量转换，返回新的切片
+func (t *Transformer) TransformSlice(ps []*Point) []*Point {
+	x := make([]*Point, len(ps))
+	for i, p := range ps {
+		x[i] = t.Transform(p)
+	}
+	return x
+}
+
+// TransformGeometry 转换几何对象中的所有点，返回新的几何对象，空点保持不变
+func (t *Transformer) TransformGeometry(g Geometry) Geometry {
+	switch x := g.(type) {
+	case *Point:
+		if x.IsEmpty() {
+			return EmptyPoint()
+		}
+		return t.Transform(x)
+	case LineString:
+		return LineString(t.TransformSlice(x))
+	case MultiPoint:
+		return MultiPoint(t.TransformSlice(x))
+	case Polygon:
+		y := make(Polygon, len(x))
+		for i, r := range x {
+			y[i] = t.TransformSlice(r)
+		}
+		return y
+	case MultiLineString:
+		y := make(MultiLineString, len(x))
+		for i, l := range x {
+			y[i] = t.TransformSlice(l)
+		}
+		return y
+	case MultiPolygon:
+		y := make(MultiPolygon, len(x))
+		for i, p := range x {
+			y[i] = t.TransformGeometry(p).(Polygon)
+		}
+		return y
+	case GeometryCollection:
+		y := make(GeometryCollection, len(x))
+		for i, c := range x {
+			y[i] = t.TransformGeometry(c)
+		}
+		return y
+	}
+	return g
+}
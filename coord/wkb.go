@@ -0,0 +1,300 @@
+package coord
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// wkb几何类型代码
+const (
+	wkbPoint uint32 = iota + 1
+	wkbLineString
+	wkbPolygon
+	wkbMultiPoint
+	wkbMultiLineString
+	wkbMultiPolygon
+	wkbGeometryCollection
+)
+
+// PostGIS EWKB 类型标志
+const (
+	ewkbZ    uint32 = 0x80000000
+	ewkbM    uint32 = 0x40000000
+	ewkbSRID uint32 = 0x20000000
+)
+
+var wkbTypes = map[string]uint32{
+	TypePoint:              wkbPoint,
+	TypeLineString:         wkbLineString,
+	TypePolygon:            wkbPolygon,
+	TypeMultiPoint:         wkbMultiPoint,
+	TypeMultiLineString:    wkbMultiLineString,
+	TypeMultiPolygon:       wkbMultiPolygon,
+	TypeGeometryCollection: wkbGeometryCollection,
+}
+
+// MarshalWKB 格式化为WKB
+//
+//	order: binary.LittleEndian(NDR) 或 binary.BigEndian(XDR)，为nil时使用LittleEndian
+//	空点的坐标写为NaN，与PostGIS一致
+func MarshalWKB(g Geometry, order binary.AppendByteOrder) ([]byte, error) {
+	if err := ValidateGeometry(g); err != nil {
+		return nil, err
+	}
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	w := &wkbWriter{order: order}
+	w.geometry(g)
+	return w.buf, nil
+}
+
+type wkbWriter struct {
+	buf   []byte
+	order binary.AppendByteOrder
+}
+
+func (w *wkbWriter) uint32(v uint32) {
+	w.buf = w.order.AppendUint32(w.buf, v)
+}
+
+func (w *wkbWriter) point(p *Point) {
+	w.buf = w.order.AppendUint64(w.buf, math.Float64bits(p.Lng))
+	w.buf = w.order.AppendUint64(w.buf, math.Float64bits(p.Lat))
+}
+
+func (w *wkbWriter) line(l LineString) {
+	w.uint32(uint32(len(l)))
+	for _, p := range l {
+		w.point(p)
+	}
+}
+
+func (w *wkbWriter) polygon(p Polygon) {
+	w.uint32(uint32(len(p)))
+	for _, r := range p {
+		w.line(r)
+	}
+}
+
+func (w *wkbWriter) geometry(g Geometry) {
+	if w.order == binary.BigEndian {
+		w.buf = append(w.buf, 0)
+	} else {
+		w.buf = append(w.buf, 1)
+	}
+	w.uint32(wkbTypes[g.GeometryType()])
+	switch x := g.(type) {
+	case *Point:
+		w.point(x)
+	case LineString:
+		w.line(x)
+	case Polygon:
+		w.polygon(x)
+	case MultiPoint:
+		w.uint32(uint32(len(x)))
+		for _, p := range x {
+			w.geometry(p)
+		}
+	case MultiLineString:
+		w.uint32(uint32(len(x)))
+		for _, l := range x {
+			w.geometry(l)
+		}
+	case MultiPolygon:
+		w.uint32(uint32(len(x)))
+		for _, p := range x {
+			w.geometry(p)
+		}
+	case GeometryCollection:
+		w.uint32(uint32(len(x)))
+		for _, c := range x {
+			w.geometry(c)
+		}
+	}
+}
+
+// UnmarshalWKB 解析WKB
+//
+//	支持NDR和XDR字节序，支持PostGIS EWKB的SRID标志(SRID会被忽略)，不支持Z，M坐标
+func UnmarshalWKB(b []byte) (Geometry, error) {
+	r := &wkbReader{b: b}
+	g, err := r.geometry(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.b) {
+		return nil, r.errorf("%d bytes left after geometry", len(r.b)-r.pos)
+	}
+	if err := ValidateGeometry(g); err != nil {
+		return nil, fmt.Errorf("wkb: %w", err)
+	}
+	return g, nil
+}
+
+type wkbReader struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) errorf(f string, a ...any) error {
+	return fmt.Errorf("wkb: offset %d: %s", r.pos, fmt.Sprintf(f, a...))
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b)-r.pos < 4 {
+		return 0, r.errorf("unexpected end")
+	}
+	v := r.order.Uint32(r.b[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// count 读取数量，并检查剩余长度是否足够，避免错误数据导致分配过大的内存
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(size) > uint64(len(r.b)-r.pos) {
+		return 0, r.errorf("count %d exceeds data length", n)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) point() (*Point, error) {
+	if len(r.b)-r.pos < 16 {
+		return nil, r.errorf("unexpected end")
+	}
+	p := &Point{
+		Lng: math.Float64frombits(r.order.Uint64(r.b[r.pos:])),
+		Lat: math.Float64frombits(r.order.Uint64(r.b[r.pos+8:])),
+	}
+	r.pos += 16
+	return p, nil
+}
+
+func (r *wkbReader) line() (LineString, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	l := make(LineString, n)
+	for i := range l {
+		if l[i], err = r.point(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (r *wkbReader) polygon() (Polygon, error) {
+	n, err := r.count(4)
+	if err != nil {
+		return nil, err
+	}
+	p := make(Polygon, n)
+	for i := range p {
+		if p[i], err = r.line(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// geometry 读取一个几何对象，want不为0时检查类型代码
+func (r *wkbReader) geometry(want uint32) (Geometry, error) {
+	if r.pos >= len(r.b) {
+		return nil, r.errorf("unexpected end")
+	}
+	switch r.b[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, r.errorf("bad byte order %d", r.b[r.pos])
+	}
+	r.pos++
+	t, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if t&(ewkbZ|ewkbM) != 0 || t&^ewkbSRID >= 1000 {
+		return nil, r.errorf("Z/M coordinates are not supported")
+	}
+	if t&ewkbSRID != 0 {
+		if _, err := r.uint32(); err != nil {
+			return nil, err
+		}
+		t &^= ewkbSRID
+	}
+	if want != 0 && t != want {
+		return nil, r.errorf("expected geometry type %d, got %d", want, t)
+	}
+	switch t {
+	case wkbPoint:
+		return r.point()
+	case wkbLineString:
+		return r.line()
+	case wkbPolygon:
+		return r.polygon()
+	case wkbMultiPoint:
+		n, err := r.count(21)
+		if err != nil {
+			return nil, err
+		}
+		m := make(MultiPoint, n)
+		for i := range m {
+			g, err := r.geometry(wkbPoint)
+			if err != nil {
+				return nil, err
+			}
+			m[i] = g.(*Point)
+		}
+		return m, nil
+	case wkbMultiLineString:
+		n, err := r.count(9)
+		if err != nil {
+			return nil, err
+		}
+		m := make(MultiLineString, n)
+		for i := range m {
+			g, err := r.geometry(wkbLineString)
+			if err != nil {
+				return nil, err
+			}
+			m[i] = g.(LineString)
+		}
+		return m, nil
+	case wkbMultiPolygon:
+		n, err := r.count(9)
+		if err != nil {
+			return nil, err
+		}
+		m := make(MultiPolygon, n)
+		for i := range m {
+			g, err := r.geometry(wkbPolygon)
+			if err != nil {
+				return nil, err
+			}
+			m[i] = g.(Polygon)
+		}
+		return m, nil
+	case wkbGeometryCollection:
+		n, err := r.count(5)
+		if err != nil {
+			return nil, err
+		}
+		c := make(GeometryCollection, n)
+		for i := range c {
+			if c[i], err = r.geometry(0); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+	return nil, r.errorf("unknown geometry type %d", t)
+}
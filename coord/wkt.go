@@ -0,0 +1,350 @@
+package coord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalWKT 格式化为WKT，如：POLYGON ((0 0, 1 0, 1 1, 0 0), (...))
+func MarshalWKT(g Geometry) (string, error) {
+	if err := ValidateGeometry(g); err != nil {
+		return "", err
+	}
+	buf := &strings.Builder{}
+	writeWKT(buf, g)
+	return buf.String(), nil
+}
+
+func writeWKT(buf *strings.Builder, g Geometry) {
+	buf.WriteString(strings.ToUpper(g.GeometryType()))
+	if g.IsEmpty() {
+		buf.WriteString(" EMPTY")
+		return
+	}
+	buf.WriteByte(' ')
+	switch x := g.(type) {
+	case *Point:
+		buf.WriteByte('(')
+		writeWKTPoint(buf, x)
+		buf.WriteByte(')')
+	case LineString:
+		writeWKTLine(buf, x)
+	case Polygon:
+		writeWKTPolygon(buf, x)
+	case MultiPoint:
+		buf.WriteByte('(')
+		for i, p := range x {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteByte('(')
+			writeWKTPoint(buf, p)
+			buf.WriteByte(')')
+		}
+		buf.WriteByte(')')
+	case MultiLineString:
+		buf.WriteByte('(')
+		for i, l := range x {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeWKTLine(buf, l)
+		}
+		buf.WriteByte(')')
+	case MultiPolygon:
+		buf.WriteByte('(')
+		for i, p := range x {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeWKTPolygon(buf, p)
+		}
+		buf.WriteByte(')')
+	case GeometryCollection:
+		buf.WriteByte('(')
+		for i, c := range x {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeWKT(buf, c)
+		}
+		buf.WriteByte(')')
+	}
+}
+
+func writeWKTPoint(buf *strings.Builder, p *Point) {
+	buf.WriteString(formatFloat(p.Lng))
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(p.Lat))
+}
+
+func writeWKTLine(buf *strings.Builder, l LineString) {
+	buf.WriteByte('(')
+	for i, p := range l {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeWKTPoint(buf, p)
+	}
+	buf.WriteByte(')')
+}
+
+func writeWKTPolygon(buf *strings.Builder, p Polygon) {
+	buf.WriteByte('(')
+	for i, r := range p {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeWKTLine(buf, r)
+	}
+	buf.WriteByte(')')
+}
+
+// formatFloat 使用最短的可还原格式
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// UnmarshalWKT 解析WKT
+//
+//	类型名称不区分大小写，MULTIPOINT中的点可以带或不带括号，
+//	支持PostGIS EWKT的 SRID=xxxx; 前缀(SRID会被忽略)，不支持Z，M坐标
+func UnmarshalWKT(s string) (Geometry, error) {
+	if len(s) > 5 && strings.EqualFold(s[:5], "SRID=") {
+		idx := strings.IndexByte(s, ';')
+		if idx < 0 {
+			return nil, fmt.Errorf("wkt: bad srid prefix")
+		}
+		if _, err := strconv.Atoi(s[5:idx]); err != nil {
+			return nil, fmt.Errorf("wkt: bad srid prefix")
+		}
+		s = s[idx+1:]
+	}
+	p := &wktParser{s: s}
+	g, err := p.geometry()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t != "" {
+		return nil, p.errorf("unexpected %q after geometry", t)
+	}
+	if err := ValidateGeometry(g); err != nil {
+		return nil, fmt.Errorf("wkt: %w", err)
+	}
+	return g, nil
+}
+
+// wktParser wkt解析器，token为单词，数字或`(`，`)`，`,`
+type wktParser struct {
+	s   string
+	pos int
+	tok string
+}
+
+func (p *wktParser) errorf(f string, a ...any) error {
+	return fmt.Errorf("wkt: offset %d: %s", p.pos, fmt.Sprintf(f, a...))
+}
+
+// next 读取下一个token，结束时返回空字符串
+func (p *wktParser) next() string {
+	if p.tok != "" {
+		t := p.tok
+		p.tok = ""
+		return t
+	}
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos >= len(p.s) {
+		return ""
+	}
+	start := p.pos
+	if strings.IndexByte("(),", p.s[p.pos]) >= 0 {
+		p.pos++
+		return p.s[start:p.pos]
+	}
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n(),", p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *wktParser) peek() string {
+	if p.tok == "" {
+		p.tok = p.next()
+	}
+	return p.tok
+}
+
+func (p *wktParser) expect(t string) error {
+	if x := p.next(); x != t {
+		return p.errorf("expected %q, got %q", t, x)
+	}
+	return nil
+}
+
+// empty 读取 EMPTY 或 `(`，返回是否为空
+func (p *wktParser) empty() (bool, error) {
+	t := p.next()
+	switch {
+	case strings.EqualFold(t, "EMPTY"):
+		return true, nil
+	case t == "(":
+		return false, nil
+	case strings.EqualFold(t, "Z") || strings.EqualFold(t, "M") || strings.EqualFold(t, "ZM"):
+		return false, p.errorf("%s coordinates are not supported", strings.ToUpper(t))
+	}
+	return false, p.errorf("expected \"(\" or EMPTY, got %q", t)
+}
+
+// list 读取以`,`分割，以`)`结束的列表，调用前已读取`(`
+func (p *wktParser) list(f func() error) error {
+	for {
+		if err := f(); err != nil {
+			return err
+		}
+		switch t := p.next(); t {
+		case ",":
+		case ")":
+			return nil
+		default:
+			return p.errorf("expected \",\" or \")\", got %q", t)
+		}
+	}
+}
+
+func (p *wktParser) geometry() (Geometry, error) {
+	t := p.next()
+	if t == "" {
+		return nil, p.errorf("unexpected end")
+	}
+	switch strings.ToUpper(t) {
+	case "POINT":
+		e, err := p.empty()
+		if err != nil || e {
+			return EmptyPoint(), err
+		}
+		pt, err := p.point()
+		if err != nil {
+			return nil, err
+		}
+		return pt, p.expect(")")
+	case "LINESTRING":
+		return p.line()
+	case "POLYGON":
+		return p.polygon()
+	case "MULTIPOINT":
+		m := MultiPoint{}
+		e, err := p.empty()
+		if err != nil || e {
+			return m, err
+		}
+		err = p.list(func() error {
+			// 点可以带括号
+			if p.peek() == "(" {
+				p.next()
+				pt, err := p.point()
+				if err != nil {
+					return err
+				}
+				m = append(m, pt)
+				return p.expect(")")
+			}
+			pt, err := p.point()
+			m = append(m, pt)
+			return err
+		})
+		return m, err
+	case "MULTILINESTRING":
+		m := MultiLineString{}
+		e, err := p.empty()
+		if err != nil || e {
+			return m, err
+		}
+		err = p.list(func() error {
+			l, err := p.line()
+			m = append(m, l)
+			return err
+		})
+		return m, err
+	case "MULTIPOLYGON":
+		m := MultiPolygon{}
+		e, err := p.empty()
+		if err != nil || e {
+			return m, err
+		}
+		err = p.list(func() error {
+			pg, err := p.polygon()
+			m = append(m, pg)
+			return err
+		})
+		return m, err
+	case "GEOMETRYCOLLECTION":
+		c := GeometryCollection{}
+		e, err := p.empty()
+		if err != nil || e {
+			return c, err
+		}
+		err = p.list(func() error {
+			g, err := p.geometry()
+			c = append(c, g)
+			return err
+		})
+		return c, err
+	}
+	return nil, p.errorf("unknown geometry type %q", t)
+}
+
+// point 读取一对坐标
+func (p *wktParser) point() (*Point, error) {
+	var xy [2]float64
+	for i := range xy {
+		t := p.next()
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %q", t)
+		}
+		xy[i] = f
+	}
+	if t := p.peek(); t != "," && t != ")" {
+		return nil, p.errorf("expected 2 coordinates, got extra %q", t)
+	}
+	return &Point{Lng: xy[0], Lat: xy[1]}, nil
+}
+
+func (p *wktParser) line() (LineString, error) {
+	l := LineString{}
+	e, err := p.empty()
+	if err != nil || e {
+		return l, err
+	}
+	err = p.list(func() error {
+		pt, err := p.point()
+		l = append(l, pt)
+		return err
+	})
+	return l, err
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	pg := Polygon{}
+	e, err := p.empty()
+	if err != nil || e {
+		return pg, err
+	}
+	err = p.list(func() error {
+		if err := p.expect("("); err != nil {
+			return err
+		}
+		r := LineString{}
+		err := p.list(func() error {
+			pt, err := p.point()
+			r = append(r, pt)
+			return err
+		})
+		pg = append(pg, r)
+		return err
+	})
+	return pg, err
+}
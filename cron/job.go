@@ -17,20 +17,28 @@
 package cron
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/google/uuid"
 	"github.com/tovenja/cron/v3"
+	"github.com/xyzj/gopsu/logger"
 	"github.com/xyzj/gopsu/mapfx"
 )
 
+// TaskFunc 可以返回错误的任务执行内容，错误会记录在任务状态中
+type TaskFunc func() error
+
 type job struct {
-	job     func()
+	job     TaskFunc
+	cj      gocron.Job
+	stat    *jobStat
 	name    string
 	spec    string
 	limits  uint
@@ -41,6 +49,10 @@ type Crontab struct {
 	cron    gocron.Scheduler
 	jobs    *mapfx.StructMap[string, job]
 	running bool
+	store   JobStore
+	records map[string]*JobRecord
+	locker  sync.Mutex
+	logg    logger.Logger
 }
 
 // Add 添加一个循环任务
@@ -49,6 +61,25 @@ type Crontab struct {
 //	spec： 执行间隔，crontab格式
 //	do: 任务执行内容
 func (c *Crontab) Add(name, spec string, do func()) error {
+	return c.add(name, spec, MisfireSkip, false, noError(do))
+}
+
+// AddWithMisfire 添加一个循环任务，并指定服务停止期间错过的任务的处理方式
+//
+//	name： 任务名称，不可重复
+//	spec： 执行间隔，crontab格式，未指定秒且存储中有同名任务时，沿用存储的秒
+//	misfire: 错过的任务的处理方式，需要设置任务存储，按存储的最后执行时间计算错过的次数
+//	do: 任务执行内容
+func (c *Crontab) AddWithMisfire(name, spec string, misfire MisfirePolicy, do func()) error {
+	return c.add(name, spec, misfire, false, noError(do))
+}
+
+// AddTask 添加一个可以返回错误的循环任务，参数同AddWithMisfire
+func (c *Crontab) AddTask(name, spec string, misfire MisfirePolicy, do TaskFunc) error {
+	return c.add(name, spec, misfire, false, do)
+}
+
+func (c *Crontab) add(name, spec string, misfire MisfirePolicy, paused bool, do TaskFunc) error {
 	if !c.running {
 		return fmt.Errorf("scheduler is not ready")
 	}
@@ -57,28 +88,208 @@ func (c *Crontab) Add(name, spec string, do func()) error {
 		return fmt.Errorf("job " + name + " already exist")
 	}
 
+	rec := c.record(name)
 	if _, err := c.parser.Parse(spec); err != nil {
 		if strings.HasPrefix(err.Error(), "expected exactly 6 fields, found 5") { // 采用随机秒
-			spec = strconv.Itoa(rand.Intn(60)) + " " + spec
+			if rec != nil && strings.HasSuffix(rec.Spec, " "+spec) {
+				spec = rec.Spec
+			} else {
+				spec = strconv.Itoa(rand.Intn(60)) + " " + spec
+			}
+		}
+	}
+	stat := &jobStat{}
+	task := func() error { return c.run(name, stat, do, time.Now()) }
+	var cj gocron.Job
+	if !paused {
+		var err error
+		if cj, err = c.newCronJob(name, spec, task); err != nil {
+			return err
+		}
+	}
+	if c.store != nil {
+		r := &JobRecord{
+			Name:    name,
+			Spec:    spec,
+			Misfire: misfire,
+			Paused:  paused,
+		}
+		if rec != nil {
+			r.LastRun = rec.LastRun
+		}
+		if err := c.saveRecord(r); err != nil {
+			c.cron.RemoveByTags(name)
+			return err
 		}
 	}
-	_, err := c.cron.NewJob(
+	c.jobs.Store(name, &job{
+		spec:    spec,
+		job:     task,
+		cj:      cj,
+		stat:    stat,
+		name:    name,
+		running: !paused,
+	})
+	if rec != nil && !rec.Paused && !paused {
+		c.misfire(name, spec, misfire, rec.LastRun, stat, do)
+	}
+	return nil
+}
+
+func (c *Crontab) newCronJob(name, spec string, task TaskFunc) (gocron.Job, error) {
+	return c.cron.NewJob(
 		gocron.CronJob(spec, true),
-		gocron.NewTask(do),
+		gocron.NewTask(task),
 		gocron.WithTags(name),
 	)
+}
+
+// noError 将没有返回值的任务转换为TaskFunc
+func noError(do func()) TaskFunc {
+	return func() error {
+		do()
+		return nil
+	}
+}
+
+// misfire 按处理方式补执行从last到当前时间之间错过的任务
+func (c *Crontab) misfire(name, spec string, policy MisfirePolicy, last time.Time, stat *jobStat, do TaskFunc) {
+	if policy == MisfireSkip || last.IsZero() {
+		return
+	}
+	sched, err := c.parser.Parse(spec)
 	if err != nil {
+		return
+	}
+	tnow := time.Now()
+	missed := make([]time.Time, 0)
+	for t := sched.Next(last); !t.IsZero() && !t.After(tnow); t = sched.Next(t) {
+		missed = append(missed, t)
+		if len(missed) >= 2*maxMisfire {
+			missed = append(missed[:0], missed[maxMisfire:]...)
+		}
+	}
+	if len(missed) == 0 {
+		return
+	}
+	if policy == MisfireOnce {
+		missed = missed[len(missed)-1:]
+	} else if len(missed) > maxMisfire {
+		missed = missed[len(missed)-maxMisfire:]
+	}
+	go func() {
+		for _, t := range missed {
+			if j, ok := c.jobs.Load(name); !ok || !j.running {
+				return
+			}
+			c.run(name, stat, do, t)
+		}
+	}()
+}
+
+// record 读取任务的存储记录
+func (c *Crontab) record(name string) *JobRecord {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if r, ok := c.records[name]; ok {
+		z := *r
+		return &z
+	}
+	return nil
+}
+
+func (c *Crontab) saveRecord(r *JobRecord) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if err := c.store.Save(r); err != nil {
 		return err
 	}
-	c.jobs.Store(name, &job{
-		spec:    spec,
-		job:     do,
-		name:    name,
-		running: true,
-	})
+	c.records[r.Name] = r
 	return nil
 }
 
+// markRun 更新任务的最后执行时间，早于已记录的时间时忽略，保存失败时记录日志
+func (c *Crontab) markRun(name string, t time.Time) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	r, ok := c.records[name]
+	if !ok || !t.After(r.LastRun) {
+		return
+	}
+	r.LastRun = t
+	if err := c.store.Save(r); err != nil {
+		c.logg.Error("[CRON] save job record " + name + " failed: " + err.Error())
+	}
+}
+
+// updateRecord 修改任务的存储记录
+func (c *Crontab) updateRecord(name string, f func(r *JobRecord)) error {
+	if c.store == nil {
+		return nil
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	r, ok := c.records[name]
+	if !ok {
+		return nil
+	}
+	f(r)
+	return c.store.Save(r)
+}
+
+// deleteRecord 删除任务的存储记录
+func (c *Crontab) deleteRecord(name ...string) error {
+	if c.store == nil {
+		return nil
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	var errs []error
+	for _, n := range name {
+		delete(c.records, n)
+		if err := c.store.Delete(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Restore 按任务存储中的记录恢复循环任务，并按各自的方式补执行错过的任务
+//
+//	tasks: 任务名称和执行内容，存储中没有执行内容的记录和已添加的任务会被忽略
+//	暂停的任务恢复后仍为暂停状态
+func (c *Crontab) Restore(tasks map[string]func()) error {
+	x := make(map[string]TaskFunc, len(tasks))
+	for k, do := range tasks {
+		x[k] = noError(do)
+	}
+	return c.RestoreTasks(x)
+}
+
+// RestoreTasks 按任务存储中的记录恢复可以返回错误的循环任务，参数同Restore
+func (c *Crontab) RestoreTasks(tasks map[string]TaskFunc) error {
+	if c.store == nil {
+		return fmt.Errorf("job store is not set")
+	}
+	c.locker.Lock()
+	recs := make([]JobRecord, 0, len(c.records))
+	for _, r := range c.records {
+		recs = append(recs, *r)
+	}
+	c.locker.Unlock()
+	var errs []error
+	for _, r := range recs {
+		do, ok := tasks[r.Name]
+		if !ok || c.jobs.Has(r.Name) {
+			continue
+		}
+		if err := c.add(r.Name, r.Spec, r.Misfire, r.Paused, do); err != nil {
+			errs = append(errs, fmt.Errorf("restore job %s: %w", r.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // AddWithLimits 添加有限次数的任务，此类任务有时效性，因此无法暂停，只能删除
 //
 //	name： 任务名称，不可重复
@@ -87,6 +298,11 @@ func (c *Crontab) Add(name, spec string, do func()) error {
 //	dur: 任务执行间隔
 //	do: 任务执行内容
 func (c *Crontab) AddWithLimits(name string, limits uint, startAt time.Time, dur time.Duration, do func()) error {
+	return c.AddLimitsTask(name, limits, startAt, dur, noError(do))
+}
+
+// AddLimitsTask 添加有限次数的可以返回错误的任务，参数同AddWithLimits
+func (c *Crontab) AddLimitsTask(name string, limits uint, startAt time.Time, dur time.Duration, do TaskFunc) error {
 	if !c.running {
 		return fmt.Errorf("scheduler is not ready")
 	}
@@ -121,16 +337,20 @@ func (c *Crontab) AddWithLimits(name string, limits uint, startAt time.Time, dur
 	} else {
 		opts = append(opts, gocron.JobOption(gocron.WithStartImmediately()))
 	}
-	_, err := c.cron.NewJob(
+	stat := &jobStat{}
+	task := func() error { return c.run(name, stat, do, time.Time{}) }
+	cj, err := c.cron.NewJob(
 		gocron.DurationJob(dur),
-		gocron.NewTask(do),
+		gocron.NewTask(task),
 		opts...,
 	)
 	if err != nil {
 		return err
 	}
 	c.jobs.Store(name, &job{
-		job:     do,
+		job:     task,
+		cj:      cj,
+		stat:    stat,
 		name:    name,
 		limits:  limits,
 		running: true,
@@ -147,7 +367,7 @@ func (c *Crontab) Remove(name ...string) error {
 	}
 	c.cron.RemoveByTags(name...)
 	c.jobs.DeleteMore(name...)
-	return nil
+	return c.deleteRecord(name...)
 }
 
 // Pause 暂停指定的循环任务，有限执行次数的任务无法暂停，只能删除
@@ -164,8 +384,9 @@ func (c *Crontab) Pause(name string) error {
 		if j.running {
 			c.cron.RemoveByTags(name)
 			j.running = false
+			j.cj = nil
 		}
-		return nil
+		return c.updateRecord(name, func(r *JobRecord) { r.Paused = true })
 	}
 	return fmt.Errorf("job " + name + " does not exist")
 }
@@ -183,25 +404,38 @@ func (c *Crontab) Resume(name string) error {
 			return nil
 		}
 		if j.spec != "" {
-			_, err := c.cron.NewJob(
-				gocron.CronJob(j.spec, true),
-				gocron.NewTask(j.job),
-				gocron.WithTags(name),
-			)
+			cj, err := c.newCronJob(name, j.spec, j.job)
 			if err != nil {
 				return err
 			}
+			j.cj = cj
 			j.running = true
+			return c.updateRecord(name, func(r *JobRecord) {
+				r.Paused = false
+				r.LastRun = time.Now()
+			})
 		}
 		return nil
 	}
 	return fmt.Errorf("job " + name + " does not exist")
 }
 
-// Clean 清除所有任务
+// Clean 清除所有任务，以及这些任务在任务存储中的记录，存储中未恢复的记录会保留
 func (c *Crontab) Clean() {
-	c.cron.RemoveByTags(c.jobs.Keys()...)
+	names := c.jobs.Keys()
+	c.cron.RemoveByTags(names...)
 	c.jobs.Clean()
+	if err := c.deleteRecord(names...); err != nil {
+		c.logg.Error("[CRON] delete job records failed: " + err.Error())
+	}
+}
+
+// SetLogger 设置日志，用于记录任务存储的错误，默认输出到控制台
+func (c *Crontab) SetLogger(l logger.Logger) {
+	if l == nil {
+		l = &logger.NilLogger{}
+	}
+	c.logg = l
 }
 
 // List 列出所有任务名称
@@ -216,6 +450,7 @@ func NewCrontab() *Crontab {
 	if err != nil {
 		return &Crontab{
 			running: false,
+			logg:    logger.NewConsoleLogger(),
 		}
 	}
 	sc.Start()
@@ -224,5 +459,35 @@ func NewCrontab() *Crontab {
 		cron:    sc,
 		jobs:    mapfx.NewStructMap[string, job](),
 		running: true,
+		records: make(map[string]*JobRecord),
+		logg:    logger.NewConsoleLogger(),
+	}
+}
+
+// NewCrontabWithStore 创建一个使用任务存储的计划任务，存储中无法读取的记录会被忽略并记录日志
+//
+//	有名称的循环任务的执行间隔，错过任务的处理方式和最后执行时间会保存在store中，
+//	重启后可使用Restore恢复任务，或使用Add/AddWithMisfire重新添加任务，同名任务会按存储的最后执行时间补执行错过的任务
+//	有限次数的任务不会保存
+func NewCrontabWithStore(store JobStore) (*Crontab, error) {
+	if store == nil {
+		return nil, fmt.Errorf("job store is nil")
+	}
+	recs, err := store.Load()
+	// 返回了部分记录时只是个别记录损坏
+	if err != nil && recs == nil {
+		return nil, err
+	}
+	c := NewCrontab()
+	if !c.running {
+		return nil, fmt.Errorf("scheduler is not ready")
+	}
+	if err != nil {
+		c.logg.Error("[CRON] load job records: " + err.Error())
+	}
+	c.store = store
+	for _, r := range recs {
+		c.records[r.Name] = r
 	}
+	return c, nil
 }
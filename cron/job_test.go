@@ -1,9 +1,19 @@
 package cron
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xyzj/gopsu/db"
+	"github.com/xyzj/gopsu/logger"
 )
 
 func TestJob2(t *testing.T) {
@@ -37,3 +47,260 @@ func TestJob1(t *testing.T) {
 
 	time.Sleep(time.Minute * 5)
 }
+
+func TestJobStore(t *testing.T) {
+	dir := t.TempDir()
+	bolt, err := db.NewBolt(filepath.Join(dir, "cron.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	fs, err := NewFileStore(filepath.Join(dir, "cron.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []JobStore{fs, NewBoltStore(bolt, "")} {
+		// 上次执行在5个多小时前，每小时执行的任务错过5次
+		last := time.Now().Truncate(time.Hour).Add(-5*time.Hour + time.Minute)
+		for _, r := range []*JobRecord{
+			{Name: "skip", Spec: "0 0 * * * *", Misfire: MisfireSkip, LastRun: last},
+			{Name: "once", Spec: "0 0 * * * *", Misfire: MisfireOnce, LastRun: last},
+			{Name: "all", Spec: "0 0 * * * *", Misfire: MisfireAll, LastRun: last},
+			{Name: "paused", Spec: "0 0 * * * *", Misfire: MisfireAll, Paused: true, LastRun: last},
+			{Name: "random", Spec: "17 0 * * * *"},
+		} {
+			if err := store.Save(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c, err := NewCrontabWithStore(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var locker sync.Mutex
+		runs := make(map[string]int)
+		tasks := make(map[string]func())
+		for _, name := range []string{"skip", "once", "all", "paused"} {
+			name := name
+			tasks[name] = func() {
+				locker.Lock()
+				runs[name]++
+				locker.Unlock()
+			}
+		}
+		if err := c.Restore(tasks); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add("random", "0 * * * *", func() {}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 200)
+		locker.Lock()
+		if runs["skip"] != 0 || runs["once"] != 1 || runs["all"] != 5 || runs["paused"] != 0 {
+			t.Fatalf("misfire runs %v", runs)
+		}
+		locker.Unlock()
+		if st, _ := c.Status("all"); st.Runs != 5 || st.Result != ResultSuccess {
+			t.Fatalf("misfire status %+v", st)
+		}
+
+		recs, _ := store.Load()
+		got := make(map[string]*JobRecord)
+		for _, r := range recs {
+			got[r.Name] = r
+		}
+		if !got["all"].LastRun.Equal(time.Now().Truncate(time.Hour)) || !got["once"].LastRun.Equal(got["all"].LastRun) {
+			t.Fatalf("last run %v %v", got["all"].LastRun, got["once"].LastRun)
+		}
+		if got["random"].Spec != "17 0 * * * *" {
+			t.Fatalf("random second not kept: %s", got["random"].Spec)
+		}
+		if !got["paused"].Paused || c.Resume("paused") != nil {
+			t.Fatal("paused job not restored")
+		}
+		c.Remove("skip")
+		c.Pause("once")
+		recs, _ = store.Load()
+		got = make(map[string]*JobRecord)
+		for _, r := range recs {
+			got[r.Name] = r
+		}
+		if _, ok := got["skip"]; ok || !got["once"].Paused || got["paused"].Paused || len(got) != 4 {
+			t.Fatalf("records after remove %+v", got)
+		}
+		c.Clean()
+		if recs, _ = store.Load(); len(recs) != 0 {
+			t.Fatalf("records after clean %d", len(recs))
+		}
+	}
+	// 重新打开文件
+	fs.Save(&JobRecord{Name: "a", Spec: "* * * * * *"})
+	fs2, err := NewFileStore(filepath.Join(dir, "cron.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs, _ := fs2.Load(); len(recs) != 1 || recs[0].Name != "a" {
+		t.Fatalf("reload %+v", recs)
+	}
+}
+
+// failStore 设置fail后保存失败的任务存储
+type failStore struct {
+	fail atomic.Bool
+}
+
+func (*failStore) Load() ([]*JobRecord, error) {
+	return []*JobRecord{{Name: "a", Spec: "* * * * * *", Misfire: MisfireAll, LastRun: time.Now().Add(-3 * time.Second)}}, nil
+}
+func (s *failStore) Save(r *JobRecord) error {
+	if s.fail.Load() {
+		return errors.New("disk full")
+	}
+	return nil
+}
+func (*failStore) Delete(name string) error { return nil }
+
+type errLogger struct {
+	logger.NilLogger
+	locker sync.Mutex
+	msgs   []string
+}
+
+func (l *errLogger) Error(msg string) {
+	l.locker.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.locker.Unlock()
+}
+
+func TestJobStoreErrors(t *testing.T) {
+	bolt, err := db.NewBolt(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	store := NewBoltStore(bolt, "")
+	store.Save(&JobRecord{Name: "good", Spec: "* * * * * *"})
+	store.Save(&JobRecord{Name: "later", Spec: "* * * * * *"})
+	bolt.Write("bad", "{not json", "cron")
+	recs, err := store.Load()
+	if err == nil || !strings.Contains(err.Error(), "bad") || len(recs) != 2 {
+		t.Fatalf("load corrupt store %v %+v", err, recs)
+	}
+	// 损坏的记录被忽略，其他记录正常恢复，Clean不删除未恢复的记录
+	c, err := NewCrontabWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.RestoreTasks(map[string]TaskFunc{"good": func() error { return nil }}); err != nil || !c.jobs.Has("good") {
+		t.Fatalf("restore %v", err)
+	}
+	c.Add("other", "0 0 * * *", func() {})
+	c.Clean()
+	if v := bolt.Read("bad", "cron"); v != "{not json" {
+		t.Fatalf("corrupt record removed: %q", v)
+	}
+	if recs, _ = store.Load(); len(recs) != 1 || recs[0].Name != "later" {
+		t.Fatalf("records after clean %+v", recs)
+	}
+
+	// 补执行和计划执行不会同时进行，保存失败时记录日志
+	fs := &failStore{}
+	c, err = NewCrontabWithStore(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &errLogger{}
+	c.SetLogger(l)
+	var active, most atomic.Int32
+	err = c.RestoreTasks(map[string]TaskFunc{"a": func() error {
+		n := active.Add(1)
+		if n > most.Load() {
+			most.Store(n)
+		}
+		time.Sleep(time.Millisecond * 300)
+		active.Add(-1)
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.fail.Store(true)
+	time.Sleep(time.Millisecond * 2500)
+	c.Clean()
+	if most.Load() != 1 {
+		t.Fatalf("concurrent runs %d", most.Load())
+	}
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if len(l.msgs) == 0 || !strings.Contains(l.msgs[0], "disk full") {
+		t.Fatalf("save error not logged %v", l.msgs)
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	c := NewCrontab()
+	var n atomic.Int32
+	err := c.AddTask("err", "* * * * * *", MisfireSkip, func() error {
+		if n.Add(1)%2 == 0 {
+			return errors.New("even run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AddLimitsTask("limits", 3, time.Now().Add(time.Hour), time.Minute, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := c.Status("err"); st.Result != ResultNone || st.NextRun.IsZero() {
+		t.Fatalf("status before run %+v", st)
+	}
+	time.Sleep(time.Millisecond * 2500)
+	c.Pause("err")
+	st, ok := c.Status("err")
+	if !ok || st.Runs < 2 || st.Failures != st.Runs/2 || st.LastStart.IsZero() || !st.Paused || !st.NextRun.IsZero() {
+		t.Fatalf("status %+v", st)
+	}
+	if (st.Runs%2 == 0) != (st.Result == ResultFailed && st.Error == "even run") {
+		t.Fatalf("result %+v", st)
+	}
+	if st, _ = c.Status("limits"); st.Limits != 3 || st.Spec != "" || st.Runs != 0 {
+		t.Fatalf("limits status %+v", st)
+	}
+	if _, ok = c.Status("none"); ok {
+		t.Fatal("status of missing job")
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/cron", c.GinHandler)
+	for _, q := range []string{"", "?name=err", "?name=none"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/cron"+q, nil))
+		var res struct {
+			Status int             `json:"status"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		switch q {
+		case "":
+			var all []*JobStatus
+			json.Unmarshal(res.Data, &all)
+			if len(all) != 2 || all[0].Name != "err" || all[1].Name != "limits" {
+				t.Fatalf("all statuses %s", w.Body.String())
+			}
+		case "?name=err":
+			var one JobStatus
+			json.Unmarshal(res.Data, &one)
+			if res.Status != 1 || one.Name != "err" || one.Runs == 0 {
+				t.Fatalf("job status %s", w.Body.String())
+			}
+		default:
+			if res.Status != 0 {
+				t.Fatalf("missing job %s", w.Body.String())
+			}
+		}
+	}
+}
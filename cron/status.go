@@ -0,0 +1,176 @@
+package cron
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobResult 任务最后一次执行的结果
+type JobResult string
+
+const (
+	// ResultNone 还没有执行过
+	ResultNone JobResult = "none"
+	// ResultRunning 正在执行
+	ResultRunning JobResult = "running"
+	// ResultSuccess 执行成功
+	ResultSuccess JobResult = "success"
+	// ResultFailed 执行返回错误
+	ResultFailed JobResult = "failed"
+)
+
+// JobStatus 任务的执行状态
+type JobStatus struct {
+	Name string `json:"name"`
+	// Spec 执行间隔，有限次数的任务为空
+	Spec string `json:"spec,omitempty"`
+	// Limits 有限次数的任务剩余的执行次数
+	Limits uint `json:"limits,omitempty"`
+	Paused bool `json:"paused"`
+	// Result 最后一次执行的结果，任务在执行时为ResultRunning
+	Result JobResult `json:"result"`
+	// Error 最后一次执行返回的错误
+	Error string `json:"error,omitempty"`
+	// LastStart 最后一次开始执行的时间
+	LastStart time.Time `json:"last_start"`
+	// Duration 最后一次执行完成的耗时，json中单位为纳秒
+	Duration time.Duration `json:"duration"`
+	// NextRun 下次计划执行时间，暂停的任务为零值
+	NextRun time.Time `json:"next_run"`
+	// Runs 执行完成的次数，包括补执行的次数
+	Runs uint64 `json:"runs"`
+	// Failures 返回错误的次数
+	Failures uint64 `json:"failures"`
+}
+
+// jobStat 任务的执行记录
+type jobStat struct {
+	// exec 执行锁，同一任务的补执行和计划执行不会同时进行
+	exec      sync.Mutex
+	locker    sync.Mutex
+	executing int
+	lastStart time.Time
+	duration  time.Duration
+	result    JobResult
+	err       string
+	runs      uint64
+	failures  uint64
+}
+
+func (s *jobStat) begin(t time.Time) {
+	s.locker.Lock()
+	s.executing++
+	s.lastStart = t
+	s.locker.Unlock()
+}
+
+func (s *jobStat) end(dur time.Duration, err error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.executing--
+	s.duration = dur
+	s.runs++
+	if err != nil {
+		s.result = ResultFailed
+		s.err = err.Error()
+		s.failures++
+		return
+	}
+	s.result = ResultSuccess
+	s.err = ""
+}
+
+func (s *jobStat) fill(st *JobStatus) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	st.Result = s.result
+	switch {
+	case s.executing > 0:
+		st.Result = ResultRunning
+	case s.result == "":
+		st.Result = ResultNone
+	}
+	st.Error = s.err
+	st.LastStart = s.lastStart
+	st.Duration = s.duration
+	st.Runs = s.runs
+	st.Failures = s.failures
+}
+
+// run 执行任务并记录状态，设置了任务存储时将计划执行时间planned记录为最后执行时间
+//
+//	同一任务的多次执行依次进行
+func (c *Crontab) run(name string, stat *jobStat, do TaskFunc, planned time.Time) error {
+	stat.exec.Lock()
+	defer stat.exec.Unlock()
+	start := time.Now()
+	stat.begin(start)
+	err := do()
+	stat.end(time.Since(start), err)
+	if c.store != nil && !planned.IsZero() {
+		c.markRun(name, planned)
+	}
+	return err
+}
+
+// Status 获取指定任务的执行状态
+//
+//	name： 任务名称
+func (c *Crontab) Status(name string) (*JobStatus, bool) {
+	j, ok := c.jobs.Load(name)
+	if !ok {
+		return nil, false
+	}
+	st := &JobStatus{
+		Name:   name,
+		Spec:   j.spec,
+		Limits: j.limits,
+		Paused: !j.running,
+	}
+	if j.stat != nil {
+		j.stat.fill(st)
+	}
+	if j.cj != nil {
+		if t, err := j.cj.NextRun(); err == nil {
+			st.NextRun = t
+		}
+	}
+	return st, true
+}
+
+// Statuses 获取所有任务的执行状态，按名称排序
+func (c *Crontab) Statuses() []*JobStatus {
+	names := c.jobs.Keys()
+	sort.Strings(names)
+	x := make([]*JobStatus, 0, len(names))
+	for _, name := range names {
+		if st, ok := c.Status(name); ok {
+			x = append(x, st)
+		}
+	}
+	return x
+}
+
+// GinHandler 以json格式返回任务的执行状态，可以使用参数name指定任务名称，不指定时返回所有任务
+func (c *Crontab) GinHandler(ctx *gin.Context) {
+	name := ctx.Query("name")
+	if name == "" {
+		ctx.Set("status", 1)
+		ctx.Set("data", c.Statuses())
+		ctx.JSON(200, ctx.Keys)
+		return
+	}
+	st, ok := c.Status(name)
+	if !ok {
+		ctx.Set("status", 0)
+		ctx.Set("detail", "job "+name+" does not exist")
+		ctx.JSON(200, ctx.Keys)
+		return
+	}
+	ctx.Set("status", 1)
+	ctx.Set("data", st)
+	ctx.JSON(200, ctx.Keys)
+}
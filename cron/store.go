@@ -0,0 +1,177 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xyzj/gopsu/db"
+	"github.com/xyzj/gopsu/json"
+)
+
+// MisfirePolicy 服务停止期间错过的任务的处理方式
+type MisfirePolicy byte
+
+const (
+	// MisfireSkip 跳过错过的执行
+	MisfireSkip MisfirePolicy = iota
+	// MisfireOnce 启动后补执行一次
+	MisfireOnce
+	// MisfireAll 启动后依次补执行所有错过的次数，最多补执行maxMisfire次
+	MisfireAll
+)
+
+// maxMisfire MisfireAll最多补执行的次数，超出时只补执行最近的部分
+const maxMisfire = 1000
+
+// JobRecord 任务的持久化记录
+type JobRecord struct {
+	Name    string        `json:"name"`
+	Spec    string        `json:"spec"`
+	Misfire MisfirePolicy `json:"misfire"`
+	Paused  bool          `json:"paused,omitempty"`
+	// LastRun 最后一次执行的计划时间，恢复暂停的任务时更新为恢复时间，暂停期间的执行不会补执行
+	LastRun time.Time `json:"last_run"`
+}
+
+// JobStore 任务存储，保存有名称的循环任务的执行间隔和最后执行时间，需要线程安全
+type JobStore interface {
+	// Load 读取所有任务记录
+	Load() ([]*JobRecord, error)
+	// Save 保存任务记录，已存在时覆盖
+	Save(r *JobRecord) error
+	// Delete 删除任务记录
+	Delete(name string) error
+}
+
+type boltStore struct {
+	db     *db.BoltDB
+	bucket string
+}
+
+// NewBoltStore 使用bolt数据文件保存任务记录
+//
+//	bucket: 保存记录的bucket，为空时使用"cron"
+func NewBoltStore(b *db.BoltDB, bucket string) JobStore {
+	if bucket == "" {
+		bucket = "cron"
+	}
+	return &boltStore{
+		db:     b,
+		bucket: bucket,
+	}
+}
+
+// Load 无法解析的记录会被跳过，并在返回的错误中列出
+func (s *boltStore) Load() ([]*JobRecord, error) {
+	x := make([]*JobRecord, 0)
+	var errs []error
+	s.db.ForEach(func(k, v string) error {
+		r := &JobRecord{}
+		if err := json.UnmarshalFromString(v, r); err != nil {
+			errs = append(errs, fmt.Errorf("decode job record %s: %w", k, err))
+			return nil
+		}
+		r.Name = k
+		x = append(x, r)
+		return nil
+	}, s.bucket)
+	return x, errors.Join(errs...)
+}
+
+func (s *boltStore) Save(r *JobRecord) error {
+	v, err := json.MarshalToString(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Write(r.Name, v, s.bucket)
+}
+
+func (s *boltStore) Delete(name string) error {
+	return s.db.Delete(name, s.bucket)
+}
+
+type fileStore struct {
+	locker   sync.Mutex
+	filename string
+	data     map[string]*JobRecord
+}
+
+// NewFileStore 使用json文件保存任务记录，文件不存在时会在保存记录时创建
+func NewFileStore(filename string) (JobStore, error) {
+	s := &fileStore{
+		filename: filename,
+		data:     make(map[string]*JobRecord),
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &s.data); err != nil {
+			return nil, err
+		}
+	}
+	for k, r := range s.data {
+		r.Name = k
+	}
+	return s, nil
+}
+
+func (s *fileStore) Load() ([]*JobRecord, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	x := make([]*JobRecord, 0, len(s.data))
+	for _, r := range s.data {
+		z := *r
+		x = append(x, &z)
+	}
+	return x, nil
+}
+
+func (s *fileStore) Save(r *JobRecord) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	z := *r
+	s.data[r.Name] = &z
+	return s.write()
+}
+
+func (s *fileStore) Delete(name string) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if _, ok := s.data[name]; !ok {
+		return nil
+	}
+	delete(s.data, name)
+	return s.write()
+}
+
+// write 先写入临时文件再改名，避免写入中断时损坏原文件
+func (s *fileStore) write() error {
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
@@ -0,0 +1,112 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/tjfoc/gmsm/sm4"
+)
+
+// GCMType 认证加密算法
+type GCMType byte
+
+const (
+	// AES128GCM aes128gcm算法
+	AES128GCM GCMType = iota
+	// AES256GCM aes256gcm算法
+	AES256GCM
+	// SM4GCM 国密sm4gcm算法
+	SM4GCM
+)
+
+// KeySize 密钥长度
+func (t GCMType) KeySize() int {
+	switch t {
+	case AES256GCM:
+		return 32
+	default:
+		return 16
+	}
+}
+
+func (t GCMType) String() string {
+	switch t {
+	case AES128GCM:
+		return "aes128gcm"
+	case AES256GCM:
+		return "aes256gcm"
+	case SM4GCM:
+		return "sm4gcm"
+	default:
+		return "unknown"
+	}
+}
+
+// GCM aes/sm4 gcm认证加密，每次加密生成随机nonce并追加在加密结果的头部
+type GCM struct {
+	aead     cipher.AEAD
+	workType GCMType
+}
+
+// SetKey 设置key，长度必须等于算法的密钥长度
+func (w *GCM) SetKey(key []byte) error {
+	if len(key) != w.workType.KeySize() {
+		return fmt.Errorf("key length must be %d", w.workType.KeySize())
+	}
+	var block cipher.Block
+	var err error
+	switch w.workType {
+	case SM4GCM:
+		block, err = sm4.NewCipher(key)
+	default:
+		block, err = aes.NewCipher(key)
+	}
+	if err != nil {
+		return err
+	}
+	w.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// Encode 加密，附加数据ad可以为nil，解密时必须相同
+func (w *GCM) Encode(b, ad []byte) (CValue, error) {
+	if w.aead == nil {
+		return EmptyValue, fmt.Errorf("key is not set")
+	}
+	nonce := GetRandom(w.aead.NonceSize())
+	return CValue(w.aead.Seal(nonce, nonce, b, ad)), nil
+}
+
+// Decode 解密并校验，数据被篡改或key错误时返回错误
+func (w *GCM) Decode(b, ad []byte) (string, error) {
+	if w.aead == nil {
+		return "", fmt.Errorf("key is not set")
+	}
+	ns := w.aead.NonceSize()
+	if len(b) < ns+w.aead.Overhead() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	x, err := w.aead.Open(nil, b[:ns], b[ns:], ad)
+	if err != nil {
+		return "", err
+	}
+	return String(x), nil
+}
+
+// DecodeBase64 解密base64编码的字符串
+func (w *GCM) DecodeBase64(s string, ad []byte) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(FillBase64(s))
+	if err != nil {
+		return "", err
+	}
+	return w.Decode(b, ad)
+}
+
+// NewGCM 创建一个新的gcm加密解密器
+func NewGCM(t GCMType) *GCM {
+	return &GCM{
+		workType: t,
+	}
+}
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestGCM(t *testing.T) {
+	for _, x := range []GCMType{AES128GCM, AES256GCM, SM4GCM} {
+		t.Run(x.String(), func(t *testing.T) {
+			c := NewGCM(x)
+			if _, err := c.Encode([]byte("abc"), nil); err == nil {
+				t.Fatal("encode without key should fail")
+			}
+			if err := c.SetKey(GetRandom(x.KeySize() + 1)); err == nil {
+				t.Fatal("wrong key size should fail")
+			}
+			key := GetRandom(x.KeySize())
+			if err := c.SetKey(key); err != nil {
+				t.Fatal(err)
+			}
+			ad := []byte("config")
+			v, err := c.Encode([]byte(s), ad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ss, err := c.Decode(v.Bytes(), ad)
+			if err != nil || ss != s {
+				t.Fatalf("decode failed %v", err)
+			}
+			if ss, err = c.DecodeBase64(v.Base64String(), ad); err != nil || ss != s {
+				t.Fatalf("decode base64 failed %v", err)
+			}
+			// 每次加密使用不同的nonce
+			if v2, _ := c.Encode([]byte(s), ad); v2.Base64String() == v.Base64String() {
+				t.Fatal("nonce should be random")
+			}
+			if _, err = c.Decode(v.Bytes(), []byte("other")); err == nil {
+				t.Fatal("wrong additional data should fail")
+			}
+			if _, err = c.Decode(v.Bytes()[:8], ad); err == nil {
+				t.Fatal("short ciphertext should fail")
+			}
+			// 篡改密文
+			for _, i := range []int{0, len(v) / 2, len(v) - 1} {
+				b := append([]byte{}, v.Bytes()...)
+				b[i] ^= 1
+				if _, err = c.Decode(b, ad); err == nil {
+					t.Fatalf("tampered byte %d should fail", i)
+				}
+			}
+			// 错误的key
+			wrong := NewGCM(x)
+			wrong.SetKey(GetRandom(x.KeySize()))
+			if _, err = wrong.Decode(v.Bytes(), ad); err == nil {
+				t.Fatal("wrong key should fail")
+			}
+		})
+	}
+}
@@ -1,25 +1,28 @@
 /*
-Package geohash ： go版的redis-geo模块，貌似有内存泄露问题，待查找和修正，目前不建议使用
+Package geohash ： go版的redis-geo模块，支持半径，矩形和多边形范围查询，使用快照和追加日志持久化
 */
 package geohash
 
 import (
 	"encoding/binary"
 	"errors"
-	"os"
 	"sync"
 
 	"github.com/xyzj/gopsu"
 	"github.com/xyzj/gopsu/geo/sortedset"
-	json "github.com/xyzj/gopsu/json"
-	"github.com/xyzj/gopsu/pathtool"
 )
 
 // GeoCache geo数据缓存集
 type GeoCache struct {
 	cachename string
-	sortedset *sortedset.SortedSet
+	sortedset *sortedset.SortedSet[uint64]
 	locker    *sync.RWMutex
+	// 持久化
+	dir        string
+	aof        *appendLog
+	snapLocker sync.Mutex
+	closeChan  chan struct{}
+	loopDone   chan struct{} // 持久化线程退出后关闭
 }
 
 // GeoPoint geo点
@@ -66,7 +69,11 @@ func (g *GeoCache) GeoAdd(points ...*GeoPoint) int {
 		if gopsu.TrimString(point.Name) == "" {
 			continue
 		}
-		g.sortedset.Add(point.Name, Encode(point.Lng, point.Lat))
+		score := Encode(point.Lng, point.Lat)
+		g.sortedset.Add(point.Name, score)
+		if g.aof != nil {
+			g.aof.write(opAdd, score, point.Name)
+		}
 		idx++
 	}
 	return idx
@@ -84,7 +91,9 @@ func (g *GeoCache) GeoRem(names ...string) int {
 		if gopsu.TrimString(name) == "" {
 			continue
 		}
-		g.sortedset.Remove(name)
+		if g.sortedset.Remove(name) && g.aof != nil {
+			g.aof.write(opRem, 0, name)
+		}
 		idx++
 	}
 	return idx
@@ -115,8 +124,6 @@ func (g *GeoCache) GeoPos(names ...string) []*GeoPoint {
 
 // GeoDist 计算距离，单位米
 func (g *GeoCache) GeoDist(name1, name2 string) (float64, error) {
-	g.locker.RLock()
-	defer g.locker.RUnlock()
 	gp := g.GeoPos(name1, name2)
 	if len(gp) != 2 {
 		return 0, errors.New("point not found")
@@ -135,90 +142,49 @@ func (g *GeoCache) GeoDistPoint(lng1, lat1, lng2, lat2 float64) (float64, error)
 	return Distance(lng1, lat1, lng2, lat2), nil
 }
 
-// GeoRadius 获取半径内的点
+// GeoRadius 获取半径内的点，半径单位米
 func (g *GeoCache) GeoRadius(longitude, latitude, radius float64) []*GeoPoint {
-	g.locker.RLock()
-	defer g.locker.RUnlock()
-	areas := GetNeighbours(longitude, latitude, radius)
-	gp := make([]*GeoPoint, 0)
-	for _, area := range areas {
-		lower := &sortedset.ScoreBorder{Value: area[0]}
-		upper := &sortedset.ScoreBorder{Value: area[1]}
-		elements := g.sortedset.RangeByScore(lower, upper, 0, -1, true)
-		for _, elem := range elements {
-			gp = append(gp, getPoint(elem.Member, elem.Score))
-		}
-	}
-	return gp
+	return g.searchPoints(&SearchOpt{
+		FromLng:  longitude,
+		FromLat:  latitude,
+		ByRadius: radius,
+	})
 }
 
-// GeoRadiusByMember 获取指定成员半径内的点
+// GeoRadiusByMember 获取指定成员半径内的点，半径单位米
 func (g *GeoCache) GeoRadiusByMember(name string, radius float64) []*GeoPoint {
-	gps := g.GeoPos(name)
-	if gps == nil {
-		return nil
-	}
-	g.locker.RLock()
-	defer g.locker.RUnlock()
-	areas := GetNeighbours(gps[0].Lng, gps[0].Lat, radius)
-	gp := make([]*GeoPoint, 0)
-	for _, area := range areas {
-		lower := &sortedset.ScoreBorder{Value: area[0]}
-		upper := &sortedset.ScoreBorder{Value: area[1]}
-		elements := g.sortedset.RangeByScore(lower, upper, 0, -1, true)
-		for _, elem := range elements {
-			gp = append(gp, getPoint(elem.Member, elem.Score))
-		}
-	}
-	return gp
+	return g.searchPoints(&SearchOpt{
+		FromMember: name,
+		ByRadius:   radius,
+	})
 }
 
-// SaveToFile 保存到文件
+// SaveToFile 保存到文件，已调用Open时等同于Snapshot
+//
+//	先写入临时文件再重命名，文件带有crc32校验
 func (g *GeoCache) SaveToFile() error {
 	if g.cachename == "" {
 		return errors.New("no file name was specified")
 	}
-	if g.sortedset.Len() == 0 {
-		os.WriteFile(pathtool.JoinPathFromHere("_geo_"+g.cachename), []byte{}, 0664)
-		return nil
-	}
-	g.locker.RLock()
-	defer g.locker.RUnlock()
-	points := g.sortedset.Range(0, g.sortedset.Len(), false)
-	gp := make([]*GeoPoint, len(points))
-	for k, point := range points {
-		gp[k] = getPoint(point.Member, point.Score)
-	}
-	geojson := &geoJSON{
-		Points: gp,
-	}
-	b, err := json.Marshal(geojson)
-	if err != nil {
+	if err := g.Snapshot(); err != ErrNotOpened {
 		return err
 	}
-	return os.WriteFile(pathtool.JoinPathFromHere("_geo_"+g.cachename), gopsu.CompressData(b, gopsu.ArchiveZlib), 0664)
+	g.locker.RLock()
+	points := g.points()
+	g.locker.RUnlock()
+	return writeSnapshot(g.filePath(), points)
 }
 
-// LoadFromFile 从文件读取缓存
+// LoadFromFile 从文件读取缓存，校验失败时返回ErrChecksum
 func (g *GeoCache) LoadFromFile() error {
 	if g.cachename == "" {
 		return errors.New("no file name was specified")
 	}
-	b, err := os.ReadFile(pathtool.JoinPathFromHere("_geo_" + g.cachename))
+	points, err := readSnapshot(g.filePath())
 	if err != nil {
 		return err
 	}
-	if len(b) == 0 {
-		return nil
-	}
-	var geojson = &geoJSON{
-		Points: make([]*GeoPoint, 0),
-	}
-	err = json.Unmarshal(gopsu.UncompressData(b, gopsu.ArchiveZlib), geojson)
-	if err != nil {
-		return err
-	}
-	g.GeoAdd(geojson.Points...)
+	g.GeoAdd(points...)
 	return nil
 }
 
@@ -227,6 +193,9 @@ func (g *GeoCache) Reset() {
 	g.locker.Lock()
 	defer g.locker.Unlock()
 	g.sortedset = sortedset.Make()
+	if g.aof != nil {
+		g.aof.write(opClear, 0, "")
+	}
 }
 
 // NewGeoCache 初始化一个新的geocache
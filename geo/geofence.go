@@ -0,0 +1,470 @@
+package geohash
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xyzj/gopsu/coord"
+	"github.com/xyzj/gopsu/logger"
+	"github.com/xyzj/gopsu/loopfunc"
+)
+
+// FenceEventType 围栏事件类型
+type FenceEventType byte
+
+const (
+	// FenceEnter 进入围栏
+	FenceEnter FenceEventType = iota + 1
+	// FenceExit 离开围栏
+	FenceExit
+	// FenceDwell 进入围栏后停留超过Fence.Dwell
+	FenceDwell
+)
+
+func (t FenceEventType) String() string {
+	switch t {
+	case FenceEnter:
+		return "enter"
+	case FenceExit:
+		return "exit"
+	case FenceDwell:
+		return "dwell"
+	}
+	return "unknown"
+}
+
+// Fence 电子围栏，圆形和多边形二选一
+type Fence struct {
+	// Name 围栏名称，重复添加同名围栏会替换
+	Name string
+	// Center，Radius 圆形围栏的中心和半径，单位米
+	Center *coord.Point
+	Radius float64
+	// Polygon 多边形围栏，不能跨越180度经线
+	Polygon coord.Polygon
+	// Dwell 进入后停留超过该时间触发一次FenceDwell事件，0表示不触发
+	Dwell time.Duration
+
+	bbox coord.BBox
+}
+
+// FenceEvent 围栏事件
+type FenceEvent struct {
+	Type   FenceEventType `json:"type"`
+	Device string         `json:"device"`
+	Fence  string         `json:"fence"`
+	// Point 触发事件的位置，FenceDwell为最后一次上报的位置
+	Point *coord.Point `json:"point"`
+	Time  time.Time    `json:"time"`
+}
+
+// FenceOpt 围栏引擎参数
+type FenceOpt struct {
+	// OnEvent 事件回调，在Update或停留检查的线程中执行，同一设备的事件按产生顺序依次回调，
+	// 回调中不能更新同一设备的位置
+	OnEvent func(*FenceEvent)
+	// ChanSize 大于0时创建容量为ChanSize的事件通道，通过Events()读取，通道满时丢弃事件
+	ChanSize int
+	// CheckInterval 停留检查间隔，默认1秒，小于0时只在Update时检查
+	CheckInterval time.Duration
+}
+
+// fenceState 设备在围栏内的状态
+type fenceState struct {
+	enter   time.Time
+	dwelled bool
+}
+
+type deviceState struct {
+	// locker 设备的事件从产生到分发完成期间持有，保证同一设备的事件按顺序分发
+	locker sync.Mutex
+	point  *coord.Point
+	inside map[string]*fenceState
+}
+
+// FenceEngine 电子围栏引擎，可以并发使用
+//
+//	围栏按外接矩形的大小放入不同精度的geohash格子，每次更新只检查位置所在格子中的围栏
+type FenceEngine struct {
+	locker  sync.Mutex
+	fences  map[string]*Fence
+	index   map[uint]map[uint64][]*Fence // geohash step -> 格子 -> 围栏
+	devices map[string]*deviceState
+	geo     *GeoCache
+
+	onEvent   func(*FenceEvent)
+	events    chan *FenceEvent
+	dropped   atomic.Uint64
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFenceEngine 创建电子围栏引擎
+//
+//	CheckInterval不小于0时会创建一个线程检查停留事件，不再使用时应调用Close
+func NewFenceEngine(opt *FenceOpt) *FenceEngine {
+	if opt == nil {
+		opt = &FenceOpt{}
+	}
+	e := &FenceEngine{
+		fences:    make(map[string]*Fence),
+		index:     make(map[uint]map[uint64][]*Fence),
+		devices:   make(map[string]*deviceState),
+		geo:       NewGeoCache(""),
+		onEvent:   opt.OnEvent,
+		closeChan: make(chan struct{}),
+	}
+	if opt.ChanSize > 0 {
+		e.events = make(chan *FenceEvent, opt.ChanSize)
+	}
+	if opt.CheckInterval == 0 {
+		opt.CheckInterval = time.Second
+	}
+	if opt.CheckInterval > 0 {
+		go loopfunc.LoopFunc(func(params ...interface{}) {
+			t := time.NewTicker(opt.CheckInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-e.closeChan:
+					return
+				case now := <-t.C:
+					e.CheckDwell(now)
+				}
+			}
+		}, "geofence", logger.NewConsoleWriter())
+	}
+	return e
+}
+
+// Close 停止停留检查线程
+func (e *FenceEngine) Close() {
+	e.closeOnce.Do(func() {
+		close(e.closeChan)
+	})
+}
+
+// Events 事件通道，FenceOpt.ChanSize为0时返回nil
+func (e *FenceEngine) Events() <-chan *FenceEvent {
+	return e.events
+}
+
+// Dropped 因通道已满丢弃的事件数量
+func (e *FenceEngine) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Devices 设备最后位置，可用于GeoSearch等查询，不要直接修改
+func (e *FenceEngine) Devices() *GeoCache {
+	return e.geo
+}
+
+// AddFence 添加围栏的副本，同名围栏会被替换，设备在旧围栏中的状态会被清除
+func (e *FenceEngine) AddFence(fence *Fence) error {
+	f := fence.clone()
+	if f.Name == "" {
+		return errors.New("fence name is required")
+	}
+	circle := f.Center != nil || f.Radius != 0
+	switch {
+	case circle && len(f.Polygon) > 0:
+		return errors.New("fence can not be both circle and polygon")
+	case circle:
+		if f.Center == nil || f.Radius <= 0 || math.IsNaN(f.Center.Lng) || math.IsNaN(f.Center.Lat) {
+			return errors.New("circle fence needs center and positive radius")
+		}
+		dLat := radDeg(f.Radius / earthRadius)
+		dLng := lngSpan(f.Center.Lat, dLat, f.Radius)
+		f.bbox = coord.BBox{
+			Min: coord.Point{Lng: f.Center.Lng - dLng, Lat: math.Max(-90, f.Center.Lat-dLat)},
+			Max: coord.Point{Lng: f.Center.Lng + dLng, Lat: math.Min(90, f.Center.Lat+dLat)},
+		}
+	case len(f.Polygon) > 0:
+		if err := coord.ValidateGeometry(f.Polygon); err != nil {
+			return err
+		}
+		f.bbox, _ = coord.Bounds(f.Polygon)
+	default:
+		return errors.New("fence needs center and radius or polygon")
+	}
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	e.removeFence(f.Name)
+	e.fences[f.Name] = f
+	step, cells := fenceCells(f.bbox)
+	if e.index[step] == nil {
+		e.index[step] = make(map[uint64][]*Fence)
+	}
+	for _, c := range cells {
+		e.index[step][c] = append(e.index[step][c], f)
+	}
+	return nil
+}
+
+// clone 复制围栏，避免调用方修改已添加的围栏
+func (f *Fence) clone() *Fence {
+	x := *f
+	if f.Center != nil {
+		x.Center = &coord.Point{Lng: f.Center.Lng, Lat: f.Center.Lat}
+	}
+	if f.Polygon != nil {
+		x.Polygon = make(coord.Polygon, 0, len(f.Polygon))
+		for _, ring := range f.Polygon {
+			r := make(coord.LineString, 0, len(ring))
+			for _, p := range ring {
+				if p != nil {
+					p = &coord.Point{Lng: p.Lng, Lat: p.Lat}
+				}
+				r = append(r, p)
+			}
+			x.Polygon = append(x.Polygon, r)
+		}
+	}
+	return &x
+}
+
+// RemoveFence 删除围栏，不会触发离开事件
+func (e *FenceEngine) RemoveFence(name string) {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	e.removeFence(name)
+}
+
+func (e *FenceEngine) removeFence(name string) {
+	f, ok := e.fences[name]
+	if !ok {
+		return
+	}
+	delete(e.fences, name)
+	step, cells := fenceCells(f.bbox)
+	for _, c := range cells {
+		fs := e.index[step][c]
+		for i, x := range fs {
+			if x == f {
+				fs = append(fs[:i], fs[i+1:]...)
+				break
+			}
+		}
+		if len(fs) == 0 {
+			delete(e.index[step], c)
+		} else {
+			e.index[step][c] = fs
+		}
+	}
+	if len(e.index[step]) == 0 {
+		delete(e.index, step)
+	}
+	for _, d := range e.devices {
+		delete(d.inside, name)
+	}
+}
+
+// Fences 所有围栏名称
+func (e *FenceEngine) Fences() []string {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	x := make([]string, 0, len(e.fences))
+	for k := range e.fences {
+		x = append(x, k)
+	}
+	return x
+}
+
+// Inside 设备当前所在的围栏
+func (e *FenceEngine) Inside(device string) []string {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	d, ok := e.devices[device]
+	if !ok {
+		return nil
+	}
+	x := make([]string, 0, len(d.inside))
+	for k := range d.inside {
+		x = append(x, k)
+	}
+	return x
+}
+
+// Contains 查找包含该点的所有围栏
+func (e *FenceEngine) Contains(p *coord.Point) []string {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	fs := e.lookup(p)
+	x := make([]string, 0, len(fs))
+	for _, f := range fs {
+		x = append(x, f.Name)
+	}
+	return x
+}
+
+// RemoveDevice 删除设备的位置和状态，不会触发离开事件
+func (e *FenceEngine) RemoveDevice(device string) {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	delete(e.devices, device)
+	e.geo.GeoRem(device)
+}
+
+// Update 更新设备位置，时间为当前时间，返回并分发产生的事件
+func (e *FenceEngine) Update(device string, p *coord.Point) []*FenceEvent {
+	return e.UpdateAt(device, p, time.Now())
+}
+
+// UpdateAt 更新设备在指定时间的位置，用于回放历史轨迹，此时应将CheckInterval设置为小于0
+//
+//	事件顺序为离开，进入，停留
+func (e *FenceEngine) UpdateAt(device string, p *coord.Point, t time.Time) []*FenceEvent {
+	d := e.lockDevice(device)
+	defer d.locker.Unlock()
+	d.point = &coord.Point{Lng: p.Lng, Lat: p.Lat}
+	e.geo.GeoAdd(&GeoPoint{Name: device, Lng: p.Lng, Lat: p.Lat})
+	cur := make(map[string]bool)
+	for _, f := range e.lookup(p) {
+		cur[f.Name] = true
+	}
+	evs := make([]*FenceEvent, 0)
+	for name := range d.inside {
+		if !cur[name] {
+			delete(d.inside, name)
+			evs = append(evs, &FenceEvent{Type: FenceExit, Device: device, Fence: name, Point: d.point, Time: t})
+		}
+	}
+	for name := range cur {
+		if _, ok := d.inside[name]; !ok {
+			d.inside[name] = &fenceState{enter: t}
+			evs = append(evs, &FenceEvent{Type: FenceEnter, Device: device, Fence: name, Point: d.point, Time: t})
+		}
+	}
+	evs = e.checkDwell(device, d, t, evs)
+	e.locker.Unlock()
+	e.dispatch(evs)
+	return evs
+}
+
+// lockDevice 获取设备状态，不存在时创建，返回时持有设备锁和引擎锁
+//
+//	加锁顺序总是先设备锁后引擎锁
+func (e *FenceEngine) lockDevice(device string) *deviceState {
+	for {
+		e.locker.Lock()
+		d, ok := e.devices[device]
+		if !ok {
+			d = &deviceState{inside: make(map[string]*fenceState)}
+			e.devices[device] = d
+		}
+		e.locker.Unlock()
+		d.locker.Lock()
+		e.locker.Lock()
+		// 等待设备锁期间设备可能已被删除
+		if e.devices[device] == d {
+			return d
+		}
+		e.locker.Unlock()
+		d.locker.Unlock()
+	}
+}
+
+// CheckDwell 检查所有设备的停留时间，返回并分发停留事件，通常由引擎的线程定时调用
+func (e *FenceEngine) CheckDwell(now time.Time) []*FenceEvent {
+	e.locker.Lock()
+	ds := make(map[string]*deviceState, len(e.devices))
+	for device, d := range e.devices {
+		ds[device] = d
+	}
+	e.locker.Unlock()
+	evs := make([]*FenceEvent, 0)
+	for device, d := range ds {
+		d.locker.Lock()
+		e.locker.Lock()
+		var x []*FenceEvent
+		if e.devices[device] == d {
+			x = e.checkDwell(device, d, now, nil)
+		}
+		e.locker.Unlock()
+		e.dispatch(x)
+		d.locker.Unlock()
+		evs = append(evs, x...)
+	}
+	return evs
+}
+
+func (e *FenceEngine) checkDwell(device string, d *deviceState, now time.Time, evs []*FenceEvent) []*FenceEvent {
+	for name, s := range d.inside {
+		f := e.fences[name]
+		if s.dwelled || f.Dwell <= 0 || now.Sub(s.enter) < f.Dwell {
+			continue
+		}
+		s.dwelled = true
+		evs = append(evs, &FenceEvent{Type: FenceDwell, Device: device, Fence: name, Point: d.point, Time: now})
+	}
+	return evs
+}
+
+func (e *FenceEngine) dispatch(evs []*FenceEvent) {
+	for _, ev := range evs {
+		if e.onEvent != nil {
+			e.onEvent(ev)
+		}
+		if e.events != nil {
+			select {
+			case e.events <- ev:
+			default:
+				e.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// lookup 查找包含该点的围栏，需要持有锁
+func (e *FenceEngine) lookup(p *coord.Point) []*Fence {
+	x := make([]*Fence, 0)
+	for step, cells := range e.index {
+		for _, f := range cells[cellOf(p.Lng, p.Lat, step)] {
+			if f.contains(p) {
+				x = append(x, f)
+			}
+		}
+	}
+	return x
+}
+
+func (f *Fence) contains(p *coord.Point) bool {
+	if f.Center != nil {
+		return Distance(f.Center.Lng, f.Center.Lat, p.Lng, p.Lat) <= f.Radius
+	}
+	return f.bbox.Contains(p) && coord.PointInPolygon(p, f.Polygon)
+}
+
+// cellOf 点在指定精度下所在的geohash格子
+func cellOf(lng, lat float64, step uint) uint64 {
+	hash, _ := encode0(ensureValidLng(lng), lat, step*2)
+	return ToUInt64(hash)
+}
+
+// fenceCells 选择格子不小于外接矩形1/3的最大精度，返回精度和覆盖外接矩形的格子，最多16个
+func fenceCells(b coord.BBox) (uint, []uint64) {
+	w, h := b.Max.Lng-b.Min.Lng, b.Max.Lat-b.Min.Lat
+	var step uint
+	for step < 26 && 360/math.Ldexp(1, int(step+1)) >= w/3 && 180/math.Ldexp(1, int(step+1)) >= h/3 {
+		step++
+	}
+	if w >= 360 {
+		step = 0
+	}
+	cw, ch := 360/math.Ldexp(1, int(step)), 180/math.Ldexp(1, int(step))
+	cells := make([]uint64, 0, 16)
+	seen := make(map[uint64]bool)
+	for y := math.Floor((b.Min.Lat + 90) / ch); y*ch-90 <= b.Max.Lat && y*ch < 180; y++ {
+		for x := math.Floor((b.Min.Lng + 180) / cw); x*cw-180 <= b.Max.Lng; x++ {
+			c := cellOf(x*cw-180+cw/2, y*ch-90+ch/2, step)
+			if !seen[c] {
+				seen[c] = true
+				cells = append(cells, c)
+			}
+		}
+	}
+	return step, cells
+}
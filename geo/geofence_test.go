@@ -0,0 +1,232 @@
+package geohash
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xyzj/gopsu/coord"
+)
+
+func TestFenceEvents(t *testing.T) {
+	got := make([]string, 0)
+	e := NewFenceEngine(&FenceOpt{
+		CheckInterval: -1,
+		ChanSize:      1,
+		OnEvent: func(ev *FenceEvent) {
+			got = append(got, ev.Type.String()+" "+ev.Fence)
+		},
+	})
+	defer e.Close()
+	if err := e.AddFence(&Fence{Name: "circle", Center: &coord.Point{Lng: 116.4, Lat: 39.9}, Radius: 1000, Dwell: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddFence(&Fence{Name: "square", Polygon: coord.Polygon{{
+		{Lng: 116.405, Lat: 39.895}, {Lng: 116.42, Lat: 39.895}, {Lng: 116.42, Lat: 39.91}, {Lng: 116.405, Lat: 39.91}, {Lng: 116.405, Lat: 39.895},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		p    *coord.Point
+		dt   time.Duration
+		want []string
+	}{
+		{&coord.Point{Lng: 116.3, Lat: 39.9}, 0, []string{}},
+		{&coord.Point{Lng: 116.401, Lat: 39.9}, time.Second, []string{"enter circle"}},
+		{&coord.Point{Lng: 116.407, Lat: 39.9}, 2 * time.Second, []string{"enter square"}},
+		{&coord.Point{Lng: 116.408, Lat: 39.9}, 61 * time.Second, []string{"dwell circle"}},
+		{&coord.Point{Lng: 116.408, Lat: 39.9}, 200 * time.Second, []string{}},
+		{&coord.Point{Lng: 116.415, Lat: 39.9}, 201 * time.Second, []string{"exit circle"}},
+		{&coord.Point{Lng: 116.5, Lat: 39.9}, 202 * time.Second, []string{"exit square"}},
+	}
+	for i, s := range steps {
+		got = got[:0]
+		e.UpdateAt("dev1", s.p, t0.Add(s.dt))
+		if fmt.Sprint(got) != fmt.Sprint(s.want) {
+			t.Fatalf("step %d: got %v, want %v", i, got, s.want)
+		}
+	}
+	if ev := <-e.Events(); ev.Type != FenceEnter || ev.Fence != "circle" || ev.Device != "dev1" {
+		t.Fatalf("first channel event %+v", ev)
+	}
+	if e.Dropped() != 4 {
+		t.Fatalf("dropped %d", e.Dropped())
+	}
+	if gp := e.Devices().GeoPos("dev1"); len(gp) != 1 || Distance(gp[0].Lng, gp[0].Lat, 116.5, 39.9) > 0.01 {
+		t.Fatalf("device position %+v", gp)
+	}
+
+	e.UpdateAt("dev2", &coord.Point{Lng: 116.41, Lat: 39.9}, t0)
+	if x := e.Inside("dev2"); len(x) != 2 {
+		t.Fatalf("inside %v", x)
+	}
+	e.RemoveFence("square")
+	if x := e.Inside("dev2"); len(x) != 1 || x[0] != "circle" {
+		t.Fatalf("inside after remove %v", x)
+	}
+	for _, f := range []*Fence{
+		{Center: &coord.Point{}, Radius: 1},
+		{Name: "a"},
+		{Name: "a", Radius: 1},
+		{Name: "a", Center: &coord.Point{}, Radius: 1, Polygon: coord.Polygon{{{}, {}, {}, {}}}},
+		{Name: "a", Polygon: coord.Polygon{{{Lng: 1, Lat: 1}, {Lng: 2, Lat: 2}}}},
+	} {
+		if err := e.AddFence(f); err == nil {
+			t.Fatalf("%+v should fail", f)
+		}
+	}
+}
+
+func TestFenceDwellTicker(t *testing.T) {
+	e := NewFenceEngine(&FenceOpt{ChanSize: 10, CheckInterval: 5 * time.Millisecond})
+	defer e.Close()
+	e.AddFence(&Fence{Name: "c", Center: &coord.Point{Lng: 10, Lat: 10}, Radius: 100, Dwell: 20 * time.Millisecond})
+	e.Update("d", &coord.Point{Lng: 10, Lat: 10})
+	<-e.Events()
+	select {
+	case ev := <-e.Events():
+		if ev.Type != FenceDwell {
+			t.Fatalf("got %s", ev.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no dwell event")
+	}
+}
+
+func TestFenceCopy(t *testing.T) {
+	e := NewFenceEngine(&FenceOpt{CheckInterval: -1})
+	defer e.Close()
+	c := &Fence{Name: "c", Center: &coord.Point{Lng: 10, Lat: 10}, Radius: 100}
+	sq := &Fence{Name: "sq", Polygon: coord.Polygon{{
+		{Lng: 20, Lat: 20}, {Lng: 21, Lat: 20}, {Lng: 21, Lat: 21}, {Lng: 20, Lat: 21}, {Lng: 20, Lat: 20},
+	}}}
+	for _, f := range []*Fence{c, sq} {
+		if err := e.AddFence(f); err != nil {
+			t.Fatal(err)
+		}
+		if f.bbox != (coord.BBox{}) {
+			t.Fatalf("fence %s modified", f.Name)
+		}
+	}
+	// 修改调用方的围栏不影响已添加的围栏
+	c.Center.Lng = 50
+	c.Radius = 1
+	sq.Polygon[0][1].Lng = 20.1
+	sq.Polygon[0][2].Lng = 20.1
+	if got := e.Contains(&coord.Point{Lng: 10, Lat: 10.0005}); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("circle got %v", got)
+	}
+	if got := e.Contains(&coord.Point{Lng: 20.5, Lat: 20.5}); len(got) != 1 || got[0] != "sq" {
+		t.Fatalf("polygon got %v", got)
+	}
+}
+
+func TestFenceEventOrder(t *testing.T) {
+	var locker sync.Mutex
+	last := make(map[string]FenceEventType)
+	bad := 0
+	e := NewFenceEngine(&FenceOpt{
+		CheckInterval: -1,
+		OnEvent: func(ev *FenceEvent) {
+			locker.Lock()
+			if last[ev.Device] == ev.Type {
+				bad++
+			}
+			last[ev.Device] = ev.Type
+			locker.Unlock()
+		},
+	})
+	defer e.Close()
+	e.AddFence(&Fence{Name: "c", Center: &coord.Point{Lng: 10, Lat: 10}, Radius: 100})
+	in, out := &coord.Point{Lng: 10, Lat: 10}, &coord.Point{Lng: 11, Lat: 11}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				p := in
+				if (i+j)%2 == 0 {
+					p = out
+				}
+				e.Update(fmt.Sprintf("d%d", j%2), p)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// 同一设备的进入和离开事件交替出现
+	if bad > 0 {
+		t.Fatalf("%d events out of order", bad)
+	}
+}
+
+func TestFenceIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	e := NewFenceEngine(&FenceOpt{CheckInterval: -1})
+	defer e.Close()
+	fences := make([]*Fence, 0)
+	for _, center := range [][2]float64{{116.4, 39.9}, {179.9, 0}, {0, 89.4}} {
+		for i := 0; i < 1000; i++ {
+			lng := ensureValidLng(center[0] + r.Float64()*2 - 1)
+			lat := center[1] + r.Float64() - 0.5
+			size := []float64{0.001, 0.01, 0.3}[i%3]
+			f := &Fence{Name: fmt.Sprintf("f%d-%d", len(fences), i)}
+			if i%2 == 0 {
+				f.Center = &coord.Point{Lng: lng, Lat: lat}
+				f.Radius = size * 111000
+			} else if lng+size <= 180 && lat+size < 90 {
+				f.Polygon = coord.Polygon{{
+					{Lng: lng, Lat: lat}, {Lng: lng + size, Lat: lat}, {Lng: lng + size/2, Lat: lat + size}, {Lng: lng, Lat: lat},
+				}}
+			} else {
+				continue
+			}
+			if err := e.AddFence(f); err != nil {
+				t.Fatal(err)
+			}
+			fences = append(fences, f)
+		}
+		for i := 0; i < 500; i++ {
+			p := &coord.Point{Lng: ensureValidLng(center[0] + r.Float64()*2 - 1), Lat: center[1] + r.Float64() - 0.5}
+			got := e.Contains(p)
+			want := make([]string, 0)
+			for _, f := range fences {
+				if e.fences[f.Name].contains(p) {
+					want = append(want, f.Name)
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("point %v: got %v, want %v", p, got, want)
+			}
+		}
+	}
+	for _, f := range fences {
+		e.RemoveFence(f.Name)
+	}
+	if len(e.index) != 0 {
+		t.Fatal("index should be empty")
+	}
+}
+
+func BenchmarkFenceUpdate(b *testing.B) {
+	r := rand.New(rand.NewSource(3))
+	e := NewFenceEngine(&FenceOpt{CheckInterval: -1})
+	defer e.Close()
+	for i := 0; i < 5000; i++ {
+		e.AddFence(&Fence{
+			Name:   fmt.Sprintf("f%d", i),
+			Center: &coord.Point{Lng: 116 + r.Float64(), Lat: 39.5 + r.Float64()},
+			Radius: 200 + r.Float64()*2000,
+		})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Update(fmt.Sprintf("d%d", i%100), &coord.Point{Lng: 116 + r.Float64(), Lat: 39.5 + r.Float64()})
+	}
+}
@@ -0,0 +1,332 @@
+package geohash
+
+import (
+	"errors"
+	"math"
+
+	"github.com/xyzj/gopsu/coord"
+)
+
+const (
+	base32Chars = "0123456789bcdefghjkmnpqrstuvwxyz"
+	// MaxPrecision geohash字符串最大长度，60位，经纬度各30位
+	MaxPrecision = 12
+)
+
+var base32Index = func() [256]int8 {
+	var x [256]int8
+	for i := range x {
+		x[i] = -1
+	}
+	for i := 0; i < len(base32Chars); i++ {
+		x[base32Chars[i]] = int8(i)
+	}
+	return x
+}()
+
+// Direction 相邻格子的方向
+type Direction byte
+
+const (
+	North Direction = iota
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+var directionOffset = [8][2]float64{
+	{0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1},
+}
+
+func checkPrecision(precision int) error {
+	if precision < 1 || precision > MaxPrecision {
+		return errors.New("geohash precision must be 1 to 12")
+	}
+	return nil
+}
+
+// EncodeString 经纬度->指定长度的base32 geohash字符串，与geohash.org一致
+//
+//	precision: 1-12，超出范围时取最接近的值
+func EncodeString(lng, lat float64, precision int) string {
+	precision = max(1, min(MaxPrecision, precision))
+	minLng, maxLng, minLat, maxLat := -180.0, 180.0, -90.0, 90.0
+	even := true
+	buf := make([]byte, precision)
+	for i := range buf {
+		ch := 0
+		for b := 4; b >= 0; b-- {
+			if even {
+				mid := (minLng + maxLng) / 2
+				if lng < mid {
+					maxLng = mid
+				} else {
+					minLng = mid
+					ch |= 1 << b
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if lat < mid {
+					maxLat = mid
+				} else {
+					minLat = mid
+					ch |= 1 << b
+				}
+			}
+			even = !even
+		}
+		buf[i] = base32Chars[ch]
+	}
+	return string(buf)
+}
+
+// DecodeBBox geohash字符串->格子的外接矩形
+func DecodeBBox(hash string) (coord.BBox, error) {
+	b := coord.BBox{
+		Min: coord.Point{Lng: -180, Lat: -90},
+		Max: coord.Point{Lng: 180, Lat: 90},
+	}
+	if err := checkPrecision(len(hash)); err != nil {
+		return b, err
+	}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := base32Index[hash[i]]
+		if ch < 0 {
+			return b, errors.New("invalid geohash character " + string(hash[i]))
+		}
+		for bit := 4; bit >= 0; bit-- {
+			on := ch&(1<<bit) != 0
+			if even {
+				mid := (b.Min.Lng + b.Max.Lng) / 2
+				if on {
+					b.Min.Lng = mid
+				} else {
+					b.Max.Lng = mid
+				}
+			} else {
+				mid := (b.Min.Lat + b.Max.Lat) / 2
+				if on {
+					b.Min.Lat = mid
+				} else {
+					b.Max.Lat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return b, nil
+}
+
+// DecodeString geohash字符串->格子的中心经纬度
+func DecodeString(hash string) (float64, float64, error) {
+	b, err := DecodeBBox(hash)
+	if err != nil {
+		return 0, 0, err
+	}
+	return (b.Min.Lng + b.Max.Lng) / 2, (b.Min.Lat + b.Max.Lat) / 2, nil
+}
+
+// Neighbour 指定方向上相同精度的相邻格子，跨越180度经线时回绕，超出南北极时返回空字符串
+func Neighbour(hash string, dir Direction) (string, error) {
+	b, err := DecodeBBox(hash)
+	if err != nil {
+		return "", err
+	}
+	if dir > NorthWest {
+		return "", errors.New("invalid direction")
+	}
+	off := directionOffset[dir]
+	lng := (b.Min.Lng+b.Max.Lng)/2 + off[0]*(b.Max.Lng-b.Min.Lng)
+	lat := (b.Min.Lat+b.Max.Lat)/2 + off[1]*(b.Max.Lat-b.Min.Lat)
+	if lat > 90 || lat < -90 {
+		return "", nil
+	}
+	return EncodeString(ensureValidLng(lng), lat, len(hash)), nil
+}
+
+// Neighbours 周围8个格子，顺序为North，NorthEast，East，SouthEast，South，SouthWest，West，NorthWest
+func Neighbours(hash string) ([]string, error) {
+	x := make([]string, 8)
+	for d := North; d <= NorthWest; d++ {
+		n, err := Neighbour(hash, d)
+		if err != nil {
+			return nil, err
+		}
+		x[d] = n
+	}
+	return x, nil
+}
+
+// cellRelation 格子和范围的关系
+type cellRelation byte
+
+const (
+	cellOutside cellRelation = iota
+	cellPartial
+	cellInside
+)
+
+// CoverPolygon 用互不重叠的geohash格子覆盖多边形，32个子格子都被选中时合并为较短的geohash
+//
+//	precision: 边界格子的精度，1-12，结果包括与多边形边界相交的格子，因此略大于多边形
+//	按平面计算，多边形不能跨越180度经线
+func CoverPolygon(pg coord.Polygon, precision int) ([]string, error) {
+	if err := checkPrecision(precision); err != nil {
+		return nil, err
+	}
+	if err := coord.ValidateGeometry(pg); err != nil {
+		return nil, err
+	}
+	pb, _ := coord.Bounds(pg)
+	return cover(precision, func(b *coord.BBox) cellRelation {
+		if b.Max.Lng < pb.Min.Lng || b.Min.Lng > pb.Max.Lng || b.Max.Lat < pb.Min.Lat || b.Min.Lat > pb.Max.Lat {
+			return cellOutside
+		}
+		for _, r := range pg {
+			for i := 1; i < len(r); i++ {
+				if segmentInRect(r[i-1], r[i], b) {
+					return cellPartial
+				}
+			}
+		}
+		// 边界与格子不相交，格子完全在多边形内或外，或多边形完全在格子内
+		if coord.PointInPolygon(&coord.Point{Lng: (b.Min.Lng + b.Max.Lng) / 2, Lat: (b.Min.Lat + b.Max.Lat) / 2}, pg) {
+			return cellInside
+		}
+		if b.Contains(pg[0][0]) {
+			return cellPartial
+		}
+		return cellOutside
+	}), nil
+}
+
+// CoverCircle 用互不重叠的geohash格子覆盖圆，32个子格子都被选中时合并为较短的geohash
+//
+//	radius: 半径，单位米
+//	precision: 边界格子的精度，1-12，结果包括与圆周相交的格子，因此略大于圆
+func CoverCircle(center *coord.Point, radius float64, precision int) ([]string, error) {
+	if err := checkPrecision(precision); err != nil {
+		return nil, err
+	}
+	if radius <= 0 || math.IsNaN(center.Lng) || math.IsNaN(center.Lat) {
+		return nil, errors.New("circle needs center and positive radius")
+	}
+	return cover(precision, func(b *coord.BBox) cellRelation {
+		minLng, maxLng := b.Min.Lng, b.Max.Lng
+		if maxLng < center.Lng-180 {
+			minLng, maxLng = minLng+360, maxLng+360
+		} else if minLng > center.Lng+180 {
+			minLng, maxLng = minLng-360, maxLng-360
+		}
+		// 格子上离圆心最近的点，在经线边上时不一定与圆心同纬度
+		nLng, nLat := center.Lng, math.Max(b.Min.Lat, math.Min(b.Max.Lat, center.Lat))
+		if center.Lng < minLng || center.Lng > maxLng {
+			nLng = minLng
+			if center.Lng > maxLng {
+				nLng = maxLng
+			}
+			if dl := degRad(nLng - center.Lng); math.Abs(dl) < math.Pi/2 {
+				nLat = radDeg(math.Atan(math.Tan(degRad(center.Lat)) / math.Cos(dl)))
+			} else {
+				nLat = math.Copysign(90, center.Lat)
+			}
+			nLat = math.Max(b.Min.Lat, math.Min(b.Max.Lat, nLat))
+		}
+		if Distance(center.Lng, center.Lat, nLng, nLat) > radius {
+			return cellOutside
+		}
+		// 球面上矩形离圆心最远的点是角点
+		for _, lng := range []float64{minLng, maxLng} {
+			for _, lat := range []float64{b.Min.Lat, b.Max.Lat} {
+				if Distance(center.Lng, center.Lat, lng, lat) > radius {
+					return cellPartial
+				}
+			}
+		}
+		return cellInside
+	}), nil
+}
+
+// cover 从1位geohash开始逐级细分，32个子格子都被选中时合并为上级格子
+func cover(precision int, relation func(b *coord.BBox) cellRelation) []string {
+	var walk func(prefix string) []string
+	walk = func(prefix string) []string {
+		x := make([]string, 0)
+		for i := 0; i < len(base32Chars); i++ {
+			hash := prefix + base32Chars[i:i+1]
+			b, _ := DecodeBBox(hash)
+			switch relation(&b) {
+			case cellInside:
+				x = append(x, hash)
+			case cellPartial:
+				if len(hash) >= precision {
+					x = append(x, hash)
+				} else {
+					x = append(x, walk(hash)...)
+				}
+			}
+		}
+		if prefix != "" && len(x) == len(base32Chars) {
+			merged := true
+			for _, h := range x {
+				if len(h) != len(prefix)+1 {
+					merged = false
+					break
+				}
+			}
+			if merged {
+				return []string{prefix}
+			}
+		}
+		return x
+	}
+	return walk("")
+}
+
+// segmentInRect 线段是否与矩形相交，包括边界
+func segmentInRect(p, q *coord.Point, b *coord.BBox) bool {
+	if b.Contains(p) || b.Contains(q) {
+		return true
+	}
+	if math.Max(p.Lng, q.Lng) < b.Min.Lng || math.Min(p.Lng, q.Lng) > b.Max.Lng ||
+		math.Max(p.Lat, q.Lat) < b.Min.Lat || math.Min(p.Lat, q.Lat) > b.Max.Lat {
+		return false
+	}
+	corners := [5]coord.Point{
+		{Lng: b.Min.Lng, Lat: b.Min.Lat}, {Lng: b.Max.Lng, Lat: b.Min.Lat},
+		{Lng: b.Max.Lng, Lat: b.Max.Lat}, {Lng: b.Min.Lng, Lat: b.Max.Lat},
+		{Lng: b.Min.Lng, Lat: b.Min.Lat},
+	}
+	for i := 1; i < 5; i++ {
+		if segmentsIntersect(p, q, &corners[i-1], &corners[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentsIntersect(p1, p2, q1, q2 *coord.Point) bool {
+	d1 := orient(q1, q2, p1)
+	d2 := orient(q1, q2, p2)
+	d3 := orient(p1, p2, q1)
+	d4 := orient(p1, p2, q2)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onBox(q1, q2, p1)) || (d2 == 0 && onBox(q1, q2, p2)) ||
+		(d3 == 0 && onBox(p1, p2, q1)) || (d4 == 0 && onBox(p1, p2, q2))
+}
+
+func orient(a, b, c *coord.Point) float64 {
+	return (b.Lng-a.Lng)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lng-a.Lng)
+}
+
+func onBox(a, b, p *coord.Point) bool {
+	return p.Lng >= math.Min(a.Lng, b.Lng) && p.Lng <= math.Max(a.Lng, b.Lng) &&
+		p.Lat >= math.Min(a.Lat, b.Lat) && p.Lat <= math.Max(a.Lat, b.Lat)
+}
@@ -0,0 +1,138 @@
+package geohash
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/xyzj/gopsu/coord"
+)
+
+func TestGeohashString(t *testing.T) {
+	for _, c := range []struct {
+		lng, lat float64
+		hash     string
+	}{
+		{-5.6, 42.6, "ezs42"},
+		{10.40744, 57.64911, "u4pruydqqvj"},
+	} {
+		if h := EncodeString(c.lng, c.lat, len(c.hash)); h != c.hash {
+			t.Fatalf("encode got %s, want %s", h, c.hash)
+		}
+		b, err := DecodeBBox(c.hash)
+		if err != nil || !b.Contains(&coord.Point{Lng: c.lng, Lat: c.lat}) {
+			t.Fatalf("bbox %+v does not contain point: %v", b, err)
+		}
+	}
+	// 与uint64编码的前缀一致
+	lng, lat := 121.4737, 31.2304
+	if h := EncodeString(lng, lat, 12); !strings.HasPrefix(enc.EncodeToString(FromUInt64(Encode(lng, lat))), h) {
+		t.Fatalf("%s is not prefix of uint64 geohash", h)
+	}
+	for _, h := range []string{"", "ezs42a", "1234567890123"} {
+		if _, err := DecodeBBox(h); err == nil {
+			t.Fatalf("%q should fail", h)
+		}
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	ns, err := Neighbours("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ezs48", "ezs49", "ezs43", "ezs41", "ezs40", "ezefp", "ezefr", "ezefx"}
+	for i := range want {
+		if ns[i] != want[i] {
+			t.Fatalf("neighbours %v, want %v", ns, want)
+		}
+	}
+	// 180度经线回绕
+	east := EncodeString(179.99, 0, 4)
+	n, _ := Neighbour(east, East)
+	if b, _ := DecodeBBox(n); b.Min.Lng != -180 {
+		t.Fatalf("east neighbour of %s is %s %+v", east, n, b)
+	}
+	n, _ = Neighbour(EncodeString(0, 89.99, 3), North)
+	if n != "" {
+		t.Fatalf("north of pole cell should be empty, got %s", n)
+	}
+}
+
+// checkCover 覆盖结果互不重叠，包含范围内所有点
+func checkCover(t *testing.T, cells []string, precision int, in func(p *coord.Point) bool, b coord.BBox) {
+	t.Helper()
+	for i, a := range cells {
+		for j, c := range cells {
+			if i != j && strings.HasPrefix(c, a) {
+				t.Fatalf("%s overlaps %s", a, c)
+			}
+		}
+	}
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < 5000; i++ {
+		p := &coord.Point{
+			Lng: b.Min.Lng + r.Float64()*(b.Max.Lng-b.Min.Lng),
+			Lat: b.Min.Lat + r.Float64()*(b.Max.Lat-b.Min.Lat),
+		}
+		if !in(p) {
+			continue
+		}
+		h := EncodeString(p.Lng, p.Lat, precision)
+		found := false
+		for _, c := range cells {
+			if strings.HasPrefix(h, c) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("point %+v not covered", p)
+		}
+	}
+}
+
+func TestCover(t *testing.T) {
+	pg := coord.Polygon{
+		{{Lng: 116.2, Lat: 39.8}, {Lng: 116.6, Lat: 39.8}, {Lng: 116.4, Lat: 40.1}, {Lng: 116.2, Lat: 39.8}},
+		{{Lng: 116.35, Lat: 39.85}, {Lng: 116.45, Lat: 39.85}, {Lng: 116.4, Lat: 39.9}, {Lng: 116.35, Lat: 39.85}},
+	}
+	cells, err := CoverPolygon(pg, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("polygon covered by %d cells", len(cells))
+	b, _ := coord.Bounds(pg)
+	area := 0.0
+	for _, c := range cells {
+		cb, _ := DecodeBBox(c)
+		area += (cb.Max.Lng - cb.Min.Lng) * (cb.Max.Lat - cb.Min.Lat)
+	}
+	// 三角形面积为外接矩形的一半，加上边界格子
+	if area > (b.Max.Lng-b.Min.Lng)*(b.Max.Lat-b.Min.Lat)*0.6 {
+		t.Fatalf("cover area %f is too large", area)
+	}
+	checkCover(t, cells, 6, func(p *coord.Point) bool { return coord.PointInPolygon(p, pg) }, b)
+
+	for _, c := range []*coord.Point{{Lng: 121.47, Lat: 31.23}, {Lng: 179.99, Lat: -16.5}} {
+		radius := 3000.0
+		cells, err = CoverCircle(c, radius, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("circle covered by %d cells", len(cells))
+		in := func(p *coord.Point) bool { return Distance(c.Lng, c.Lat, p.Lng, p.Lat) <= radius }
+		for _, lng := range []float64{c.Lng - 0.05, c.Lng + 0.05 - 360} {
+			b = coord.BBox{Min: coord.Point{Lng: lng, Lat: c.Lat - 0.03}, Max: coord.Point{Lng: lng + 0.1, Lat: c.Lat + 0.03}}
+			checkCover(t, cells, 7, func(p *coord.Point) bool {
+				return in(&coord.Point{Lng: ensureValidLng(p.Lng), Lat: p.Lat})
+			}, b)
+		}
+	}
+	if _, err := CoverCircle(&coord.Point{}, 0, 5); err == nil {
+		t.Fatal("zero radius should fail")
+	}
+	if _, err := CoverPolygon(pg, 13); err == nil {
+		t.Fatal("precision 13 should fail")
+	}
+}
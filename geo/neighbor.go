@@ -17,32 +17,6 @@ func radDeg(ang float64) float64 {
 	return ang / dr
 }
 
-func estimatePrecisionByRadius(radiusMeters float64, latitude float64) uint {
-	if radiusMeters == 0 {
-		return defaultBitSize - 1
-	}
-	var precision uint = 1
-	for radiusMeters < mercatorMax {
-		radiusMeters *= 2
-		precision++
-	}
-	/* Make sure range is included in most of the base cases. */
-	precision -= 2
-	if latitude > 66 || latitude < -66 {
-		precision--
-		if latitude > 80 || latitude < -80 {
-			precision--
-		}
-	}
-	if precision < 1 {
-		precision = 1
-	}
-	if precision > 32 {
-		precision = 32
-	}
-	return precision*2 - 1
-}
-
 // Distance computes the distance between two given coordinates in meter
 func Distance(longitude1, latitude1, longitude2, latitude2 float64) float64 {
 	radLat1 := degRad(latitude1)
@@ -53,22 +27,10 @@ func Distance(longitude1, latitude1, longitude2, latitude2 float64) float64 {
 		math.Cos(radLat1)*math.Cos(radLat2)*math.Pow(math.Sin(b/2), 2)))
 }
 
-// toRange covert geohash prefix to uint64 range
+// toRange covert geohash prefix to uint64 range, upper is inclusive
 func toRange(scope []byte, precision uint) [2]uint64 {
 	lower := ToUInt64(scope)
-	radius := uint64(1 << (64 - precision))
-	upper := lower + radius
-	return [2]uint64{lower, upper}
-}
-
-func ensureValidLat(lat float64) float64 {
-	if lat > 90 {
-		return 90
-	}
-	if lat < -90 {
-		return -90
-	}
-	return lat
+	return [2]uint64{lower, lower | ^uint64(0)>>precision}
 }
 
 func ensureValidLng(lng float64) float64 {
@@ -83,36 +45,61 @@ func ensureValidLng(lng float64) float64 {
 
 // GetNeighbours returns geohash code of blocks within radiusMeters to the given coordinate
 func GetNeighbours(longitude, latitude, radiusMeters float64) [][2]uint64 {
-	precision := estimatePrecisionByRadius(radiusMeters, latitude)
+	dLat := radDeg(radiusMeters / earthRadius)
+	return areasByBox(longitude, latitude, lngSpan(latitude, dLat, radiusMeters), dLat)
+}
+
+// lngSpan 计算中心纬度上下dLat范围内，东西方向halfMeters米对应的最大经度差
+func lngSpan(latitude, dLat, halfMeters float64) float64 {
+	lat := math.Max(math.Abs(latitude-dLat), math.Abs(latitude+dLat))
+	if lat >= 90 {
+		return 360
+	}
+	return math.Min(360, radDeg(halfMeters/earthRadius/math.Cos(degRad(lat))))
+}
 
+// areasByBox 返回覆盖以(longitude,latitude)为中心，经度±dLng，纬度±dLat矩形的geohash区间
+//
+//	选择格子不小于矩形半宽和半高的最大精度，中心格子及其周围8个格子即可覆盖矩形
+func areasByBox(longitude, latitude, dLng, dLat float64) [][2]uint64 {
+	var step uint
+	for step < defaultBitSize/2 && 360/math.Ldexp(1, int(step+1)) >= dLng && 180/math.Ldexp(1, int(step+1)) >= dLat {
+		step++
+	}
+	if step == 0 {
+		return [][2]uint64{{0, ^uint64(0)}}
+	}
+	precision := step * 2
 	center, box := encode0(longitude, latitude, precision)
-	height := box[0][1] - box[0][0]
-	width := box[1][1] - box[1][0]
+	width := box[0][1] - box[0][0]
+	height := box[1][1] - box[1][0]
 	centerLng := (box[0][1] + box[0][0]) / 2
 	centerLat := (box[1][1] + box[1][0]) / 2
-	maxLat := ensureValidLat(centerLat + height)
-	minLat := ensureValidLat(centerLat - height)
-	maxLng := ensureValidLng(centerLng + width)
-	minLng := ensureValidLng(centerLng - width)
-
-	var result [10][2]uint64
-	leftUpper, _ := encode0(maxLat, minLng, precision)
-	result[1] = toRange(leftUpper, precision)
-	upper, _ := encode0(maxLat, centerLng, precision)
-	result[2] = toRange(upper, precision)
-	rightUpper, _ := encode0(maxLat, maxLng, precision)
-	result[3] = toRange(rightUpper, precision)
-	left, _ := encode0(centerLat, minLng, precision)
-	result[4] = toRange(left, precision)
-	result[5] = toRange(center, precision)
-	right, _ := encode0(centerLat, maxLng, precision)
-	result[6] = toRange(right, precision)
-	leftDown, _ := encode0(minLat, minLng, precision)
-	result[7] = toRange(leftDown, precision)
-	down, _ := encode0(minLat, centerLng, precision)
-	result[8] = toRange(down, precision)
-	rightDown, _ := encode0(minLat, maxLng, precision)
-	result[9] = toRange(rightDown, precision)
 
-	return result[1:]
+	result := make([][2]uint64, 0, 9)
+	result = append(result, toRange(center, precision))
+	for _, dy := range []float64{-1, 0, 1} {
+		lat := centerLat + dy*height
+		if lat < -90 || lat > 90 {
+			continue
+		}
+		for _, dx := range []float64{-1, 0, 1} {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			hash, _ := encode0(ensureValidLng(centerLng+dx*width), lat, precision)
+			area := toRange(hash, precision)
+			dup := false
+			for _, a := range result {
+				if a == area {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				result = append(result, area)
+			}
+		}
+	}
+	return result
 }
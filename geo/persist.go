@@ -0,0 +1,388 @@
+package geohash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xyzj/gopsu"
+	"github.com/xyzj/gopsu/geo/sortedset"
+	json "github.com/xyzj/gopsu/json"
+	"github.com/xyzj/gopsu/logger"
+	"github.com/xyzj/gopsu/loopfunc"
+	"github.com/xyzj/gopsu/pathtool"
+)
+
+// 快照文件头，之后是4字节crc32和zlib压缩的json
+var snapshotMagic = []byte("GEO1")
+
+const (
+	opAdd   byte = 'A'
+	opRem   byte = 'R'
+	opClear byte = 'C'
+)
+
+var (
+	// ErrChecksum 快照或日志校验失败
+	ErrChecksum = errors.New("geo: checksum mismatch")
+	// ErrNotOpened 未调用Open开启持久化
+	ErrNotOpened = errors.New("geo: persistence not opened")
+)
+
+// PersistOpt 持久化参数
+type PersistOpt struct {
+	// Dir 数据目录，为空时使用程序所在目录
+	Dir string
+	// SyncInterval 追加日志fsync间隔，默认1秒，小于0时每次写入后立即fsync
+	SyncInterval time.Duration
+	// SnapshotInterval 自动快照间隔，0表示不自动快照，快照后会清空追加日志
+	SnapshotInterval time.Duration
+}
+
+// appendLog GeoAdd/GeoRem的追加日志，记录格式：4字节长度，4字节crc32，内容
+//
+//	写入在GeoCache的写锁内进行，fsync在读锁内进行
+type appendLog struct {
+	f       *os.File
+	syncNow bool
+	dirty   bool
+	err     error
+	buf     []byte
+}
+
+func (l *appendLog) write(op byte, score uint64, name string) {
+	if l.err != nil {
+		return
+	}
+	l.buf = append(l.buf[:0], 0, 0, 0, 0, 0, 0, 0, 0, op)
+	if op == opAdd {
+		l.buf = binary.BigEndian.AppendUint64(l.buf, score)
+	}
+	l.buf = append(l.buf, name...)
+	binary.BigEndian.PutUint32(l.buf, uint32(len(l.buf)-8))
+	binary.BigEndian.PutUint32(l.buf[4:], crc32.ChecksumIEEE(l.buf[8:]))
+	if _, l.err = l.f.Write(l.buf); l.err != nil {
+		return
+	}
+	l.dirty = true
+	if l.syncNow {
+		l.sync()
+	}
+}
+
+func (l *appendLog) sync() {
+	if !l.dirty || l.err != nil {
+		return
+	}
+	l.err = l.f.Sync()
+	l.dirty = false
+}
+
+// filePath 快照文件路径，追加日志为同名的.aof文件，快照过程中旧日志为.aof.1文件
+func (g *GeoCache) filePath() string {
+	if g.dir != "" {
+		return filepath.Join(g.dir, "_geo_"+g.cachename)
+	}
+	return pathtool.JoinPathFromHere("_geo_" + g.cachename)
+}
+
+// Open 开启持久化，加载快照并重放追加日志，之后的GeoAdd，GeoRem和Reset都会写入追加日志
+//
+//	进程崩溃最多丢失SyncInterval内的修改，不再使用时应调用Close
+func (g *GeoCache) Open(opt *PersistOpt) error {
+	if g.cachename == "" {
+		return errors.New("no file name was specified")
+	}
+	if opt == nil {
+		opt = &PersistOpt{}
+	}
+	if opt.SyncInterval == 0 {
+		opt.SyncInterval = time.Second
+	}
+	g.snapLocker.Lock()
+	defer g.snapLocker.Unlock()
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	if g.aof != nil {
+		return errors.New("geo: persistence already opened")
+	}
+	g.dir = opt.Dir
+	fn := g.filePath()
+	points, err := readSnapshot(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	g.sortedset = sortedset.Make()
+	for _, p := range points {
+		g.sortedset.Add(p.Name, Encode(p.Lng, p.Lat))
+	}
+	for _, name := range []string{fn + ".aof.1", fn + ".aof"} {
+		if err := g.replay(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, err := os.OpenFile(fn+".aof", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		return err
+	}
+	g.aof = &appendLog{f: f, syncNow: opt.SyncInterval < 0}
+	g.closeChan = make(chan struct{})
+	g.loopDone = make(chan struct{})
+	go g.persistLoop(opt, g.closeChan, g.loopDone)
+	return nil
+}
+
+func (g *GeoCache) persistLoop(opt *PersistOpt, closeChan, done chan struct{}) {
+	defer close(done)
+	loopfunc.LoopFunc(func(params ...interface{}) {
+		syncInterval := opt.SyncInterval
+		if syncInterval < 0 {
+			syncInterval = time.Minute
+		}
+		tSync := time.NewTicker(syncInterval)
+		defer tSync.Stop()
+		var snap <-chan time.Time
+		if opt.SnapshotInterval > 0 {
+			tSnap := time.NewTicker(opt.SnapshotInterval)
+			defer tSnap.Stop()
+			snap = tSnap.C
+		}
+		for {
+			select {
+			case <-closeChan:
+				return
+			case <-tSync.C:
+				g.locker.RLock()
+				if g.aof != nil {
+					g.aof.sync()
+				}
+				g.locker.RUnlock()
+			case <-snap:
+				g.Snapshot()
+			}
+		}
+	}, "geo cache "+g.cachename, logger.NewConsoleWriter())
+}
+
+// replay 重放追加日志，末尾不完整的记录视为写入时崩溃，截断后继续
+func (g *GeoCache) replay(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	pos := 0
+	for pos+8 <= len(b) {
+		n := int(binary.BigEndian.Uint32(b[pos:]))
+		if n < 1 || pos+8+n > len(b) {
+			break
+		}
+		rec := b[pos+8 : pos+8+n]
+		if crc32.ChecksumIEEE(rec) != binary.BigEndian.Uint32(b[pos+4:]) {
+			return ErrChecksum
+		}
+		switch rec[0] {
+		case opAdd:
+			if len(rec) < 9 {
+				return ErrChecksum
+			}
+			g.sortedset.Add(string(rec[9:]), binary.BigEndian.Uint64(rec[1:9]))
+		case opRem:
+			g.sortedset.Remove(string(rec[1:]))
+		case opClear:
+			g.sortedset = sortedset.Make()
+		default:
+			return ErrChecksum
+		}
+		pos += 8 + n
+	}
+	if pos < len(b) {
+		return os.Truncate(name, int64(pos))
+	}
+	return nil
+}
+
+// Snapshot 保存快照并清空追加日志，需要先调用Open
+//
+//	只在切换追加日志时短暂持有写锁，快照写入临时文件后重命名，任意时刻崩溃都不会丢失数据
+func (g *GeoCache) Snapshot() error {
+	g.snapLocker.Lock()
+	defer g.snapLocker.Unlock()
+	g.locker.Lock()
+	if g.aof == nil {
+		g.locker.Unlock()
+		return ErrNotOpened
+	}
+	fn := g.filePath()
+	points := g.points()
+	err := g.rotate(fn)
+	g.locker.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = writeSnapshot(fn, points); err != nil {
+		return err
+	}
+	if err = os.Remove(fn + ".aof.1"); err != nil {
+		return err
+	}
+	syncDir(fn)
+	return nil
+}
+
+// rotate 将当前追加日志改名为.aof.1并创建新的日志，上次快照失败留下的.aof.1会合并当前日志
+func (g *GeoCache) rotate(fn string) error {
+	g.aof.sync()
+	if g.aof.err != nil {
+		return g.aof.err
+	}
+	g.aof.f.Close()
+	old := fn + ".aof.1"
+	if _, err := os.Stat(old); err == nil {
+		if err := appendFile(old, fn+".aof"); err != nil {
+			return err
+		}
+		if err := os.Remove(fn + ".aof"); err != nil {
+			return err
+		}
+	} else if err := os.Rename(fn+".aof", old); err != nil {
+		return err
+	}
+	syncDir(fn)
+	g.aof.f, g.aof.err = os.OpenFile(fn+".aof", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+	return g.aof.err
+}
+
+// Close 同步并关闭追加日志，返回持久化过程中的错误
+//
+//	会等待持久化线程退出和正在进行的快照完成
+func (g *GeoCache) Close() error {
+	g.locker.Lock()
+	closeChan, done := g.closeChan, g.loopDone
+	g.closeChan = nil
+	g.locker.Unlock()
+	if closeChan != nil {
+		close(closeChan)
+	}
+	// 持久化线程可能在等待snapLocker，需要在加锁前等待其退出
+	if done != nil {
+		<-done
+	}
+	g.snapLocker.Lock()
+	defer g.snapLocker.Unlock()
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	if g.aof == nil {
+		return nil
+	}
+	g.aof.sync()
+	err := g.aof.err
+	if e := g.aof.f.Close(); err == nil {
+		err = e
+	}
+	g.aof = nil
+	return err
+}
+
+// points 返回所有点，需要持有锁
+func (g *GeoCache) points() []*GeoPoint {
+	if g.sortedset.Len() == 0 {
+		return []*GeoPoint{}
+	}
+	elems := g.sortedset.Range(0, g.sortedset.Len(), false)
+	gp := make([]*GeoPoint, len(elems))
+	for k, e := range elems {
+		gp[k] = getPoint(e.Member, e.Score)
+	}
+	return gp
+}
+
+// writeSnapshot 原子写入快照，先写临时文件并fsync，再重命名
+func writeSnapshot(fn string, points []*GeoPoint) error {
+	b, err := json.Marshal(&geoJSON{Points: points})
+	if err != nil {
+		return err
+	}
+	b = gopsu.CompressData(b, gopsu.ArchiveZlib)
+	buf := make([]byte, 0, len(b)+8)
+	buf = append(buf, snapshotMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(b))
+	buf = append(buf, b...)
+	f, err := os.OpenFile(fn+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(fn + ".tmp")
+		return err
+	}
+	if err = os.Rename(fn+".tmp", fn); err != nil {
+		return err
+	}
+	syncDir(fn)
+	return nil
+}
+
+// readSnapshot 读取快照并校验，兼容没有文件头的旧格式
+func readSnapshot(fn string) ([]*GeoPoint, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if bytes.HasPrefix(b, snapshotMagic) {
+		if len(b) < 8 || crc32.ChecksumIEEE(b[8:]) != binary.BigEndian.Uint32(b[4:]) {
+			return nil, ErrChecksum
+		}
+		b = b[8:]
+	}
+	var geojson = &geoJSON{
+		Points: make([]*GeoPoint, 0),
+	}
+	if err = json.Unmarshal(gopsu.UncompressData(b, gopsu.ArchiveZlib), geojson); err != nil {
+		return nil, err
+	}
+	return geojson.Points, nil
+}
+
+// appendFile 将src的内容追加到dst并fsync
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// syncDir fsync文件所在目录，保证重命名和删除落盘，部分系统不支持，忽略错误
+func syncDir(fn string) {
+	d, err := os.Open(filepath.Dir(fn))
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
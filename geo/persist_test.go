@@ -0,0 +1,227 @@
+package geohash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xyzj/gopsu"
+	json "github.com/xyzj/gopsu/json"
+)
+
+// dump 返回所有成员和geohash
+func dump(g *GeoCache) map[string]uint64 {
+	g.locker.RLock()
+	defer g.locker.RUnlock()
+	x := make(map[string]uint64)
+	for _, p := range g.points() {
+		x[p.Name] = Encode(p.Lng, p.Lat)
+	}
+	return x
+}
+
+func sameDump(t *testing.T, a, b *GeoCache) {
+	t.Helper()
+	x, y := dump(a), dump(b)
+	if len(x) != len(y) {
+		t.Fatalf("len %d != %d", len(x), len(y))
+	}
+	for k, v := range x {
+		if y[k] != v {
+			t.Fatalf("%s: %d != %d", k, v, y[k])
+		}
+	}
+}
+
+func TestPersist(t *testing.T) {
+	dir := t.TempDir()
+	opt := &PersistOpt{Dir: dir, SyncInterval: -1}
+	g := randomCache(121.47, 31.23, 0.2, 500)
+	g.cachename, g.dir = "test", dir
+	// 内存中已有的数据在Open时被文件内容替换，先保存快照
+	if err := g.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	g = NewGeoCache("test")
+	if err := g.Open(opt); err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 500 {
+		t.Fatalf("loaded %d points", g.Len())
+	}
+	g.GeoRem("p1", "p2", "p3")
+	g.GeoAdd(&GeoPoint{Name: "p4", Lng: 120, Lat: 30}, &GeoPoint{Name: "new", Lng: -70.5, Lat: -33.4})
+
+	t.Run("crash without close", func(t *testing.T) {
+		g2 := NewGeoCache("test")
+		if err := g2.Open(opt); err != nil {
+			t.Fatal(err)
+		}
+		defer g2.Close()
+		sameDump(t, g, g2)
+	})
+	t.Run("snapshot", func(t *testing.T) {
+		if err := g.Snapshot(); err != nil {
+			t.Fatal(err)
+		}
+		if fi, err := os.Stat(filepath.Join(dir, "_geo_test.aof")); err != nil || fi.Size() != 0 {
+			t.Fatalf("aof should be empty after snapshot: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "_geo_test.aof.1")); !os.IsNotExist(err) {
+			t.Fatal("old aof should be removed")
+		}
+		g.GeoRem("p10")
+		g.Reset()
+		g.GeoAdd(&GeoPoint{Name: "after", Lng: 1, Lat: 2})
+		g2 := NewGeoCache("test")
+		if err := g2.Open(opt); err != nil {
+			t.Fatal(err)
+		}
+		defer g2.Close()
+		sameDump(t, g, g2)
+		if g2.Len() != 1 {
+			t.Fatalf("len %d after reset", g2.Len())
+		}
+	})
+	t.Run("failed snapshot", func(t *testing.T) {
+		// 模拟快照写入前崩溃：旧日志已改名但快照未更新
+		aof := filepath.Join(dir, "_geo_test.aof")
+		os.Rename(aof, aof+".1")
+		os.WriteFile(aof, nil, 0664)
+		g.GeoAdd(&GeoPoint{Name: "during", Lng: 3, Lat: 4})
+		g2 := NewGeoCache("test")
+		if err := g2.Open(opt); err != nil {
+			t.Fatal(err)
+		}
+		defer g2.Close()
+		sameDump(t, g, g2)
+	})
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("torn write", func(t *testing.T) {
+		f, _ := os.OpenFile(filepath.Join(dir, "_geo_test.aof"), os.O_WRONLY|os.O_APPEND, 0664)
+		f.Write([]byte{0, 0, 0, 20, 1, 2, 3})
+		f.Close()
+		g2 := NewGeoCache("test")
+		if err := g2.Open(opt); err != nil {
+			t.Fatal(err)
+		}
+		defer g2.Close()
+		sameDump(t, g, g2)
+	})
+	t.Run("checksum", func(t *testing.T) {
+		fn := filepath.Join(dir, "_geo_test")
+		b, _ := os.ReadFile(fn)
+		b[len(b)-1] ^= 0xff
+		os.WriteFile(fn, b, 0664)
+		if err := NewGeoCache("test").Open(opt); err != ErrChecksum {
+			t.Fatalf("want ErrChecksum, got %v", err)
+		}
+		b[len(b)-1] ^= 0xff
+		os.WriteFile(fn, b, 0664)
+		g2 := NewGeoCache("test")
+		if err := g2.Open(opt); err != nil {
+			t.Fatal(err)
+		}
+		g2.GeoAdd(&GeoPoint{Name: "last", Lng: 5, Lat: 6})
+		g2.Close()
+		aof := filepath.Join(dir, "_geo_test.aof")
+		b, _ = os.ReadFile(aof)
+		b[len(b)-1] ^= 0xff
+		os.WriteFile(aof, b, 0664)
+		if err := NewGeoCache("test").Open(opt); err != ErrChecksum {
+			t.Fatalf("want ErrChecksum, got %v", err)
+		}
+	})
+}
+
+func TestLoadLegacyFile(t *testing.T) {
+	g := NewGeoCache("legacy")
+	g.dir = t.TempDir()
+	b, _ := json.Marshal(&geoJSON{Points: []*GeoPoint{{Name: "a", Lng: 116, Lat: 40}}})
+	os.WriteFile(g.filePath(), gopsu.CompressData(b, gopsu.ArchiveZlib), 0664)
+	if err := g.LoadFromFile(); err != nil {
+		t.Fatal(err)
+	}
+	if gp := g.GeoPos("a"); len(gp) != 1 || Distance(gp[0].Lng, gp[0].Lat, 116, 40) > 0.01 {
+		t.Fatalf("bad point %+v", gp)
+	}
+}
+
+// TestCloseDuringSnapshot Close返回后不再有快照修改数据目录
+func TestCloseDuringSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ls := func() string {
+		fs, _ := os.ReadDir(dir)
+		x := ""
+		for _, f := range fs {
+			info, _ := f.Info()
+			x += fmt.Sprintf("%s %d;", f.Name(), info.Size())
+		}
+		return x
+	}
+	for i := 0; i < 20; i++ {
+		g := NewGeoCache(fmt.Sprintf("close%d", i))
+		if err := g.Open(&PersistOpt{Dir: dir, SnapshotInterval: time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 2000; j++ {
+			g.GeoAdd(&GeoPoint{Name: fmt.Sprintf("p%d", j), Lng: 116 + float64(j)/10000, Lat: 40})
+		}
+		time.Sleep(time.Millisecond * time.Duration(i%3))
+		if err := g.Close(); err != nil {
+			t.Fatal(err)
+		}
+		before := ls()
+		time.Sleep(5 * time.Millisecond)
+		if after := ls(); after != before {
+			t.Fatalf("files changed after close\n%s\n%s", before, after)
+		}
+	}
+}
+
+// TestConcurrent 使用-race运行
+func TestConcurrent(t *testing.T) {
+	g := NewGeoCache("race")
+	if err := g.Open(&PersistOpt{Dir: t.TempDir(), SyncInterval: time.Millisecond, SnapshotInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				name := fmt.Sprintf("w%d-%d", w, i%50)
+				g.GeoAdd(&GeoPoint{Name: name, Lng: 116 + float64(i)/1000, Lat: 40})
+				g.GeoSearch(&SearchOpt{FromLng: 116.2, FromLat: 40, ByRadius: 5000, Count: 10})
+				g.GeoDist(name, "w0-0")
+				if i%3 == 0 {
+					g.GeoRem(name)
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			g.Snapshot()
+		}
+	}()
+	wg.Wait()
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGeoCache("race")
+	if err := g2.Open(&PersistOpt{Dir: g.dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	sameDump(t, g, g2)
+}
@@ -0,0 +1,181 @@
+package geohash
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/xyzj/gopsu/coord"
+	"github.com/xyzj/gopsu/geo/sortedset"
+)
+
+// SortOrder 结果排序方式
+type SortOrder byte
+
+const (
+	// SortNone 不排序
+	SortNone SortOrder = iota
+	// SortAsc 按距离由近到远
+	SortAsc
+	// SortDesc 按距离由远到近
+	SortDesc
+)
+
+// SearchOpt GeoSearch参数，与redis GEOSEARCH一致，另外支持多边形范围
+type SearchOpt struct {
+	// FromMember 以已有成员的位置为中心，不为空时忽略FromLng和FromLat
+	FromMember string
+	// FromLng，FromLat 中心经纬度
+	FromLng float64
+	FromLat float64
+	// ByRadius 圆形范围半径，单位米
+	ByRadius float64
+	// ByWidth，ByHeight 以中心为中心的矩形范围的宽和高，单位米
+	ByWidth  float64
+	ByHeight float64
+	// ByPolygon 多边形范围，距离从中心计算，未指定中心(FromMember为空，FromLng和FromLat均为0)时使用多边形的质心
+	ByPolygon coord.Polygon
+	// Sort 排序方式，指定Count且Any为false时默认为SortAsc
+	Sort SortOrder
+	// Count 最多返回的数量，0表示不限制
+	Count int
+	// Any 找到Count个结果后立即返回，结果不一定是最近的
+	Any bool
+}
+
+// GeoResult GeoSearch结果
+type GeoResult struct {
+	*GeoPoint
+	// Dist 到中心的距离，单位米
+	Dist float64 `json:"dist"`
+}
+
+func (opt *SearchOpt) check() error {
+	shapes := 0
+	if opt.ByRadius > 0 {
+		shapes++
+	}
+	if opt.ByWidth > 0 || opt.ByHeight > 0 {
+		if opt.ByWidth <= 0 || opt.ByHeight <= 0 {
+			return errors.New("box width and height must be positive")
+		}
+		shapes++
+	}
+	if len(opt.ByPolygon) > 0 {
+		if err := coord.ValidateGeometry(opt.ByPolygon); err != nil {
+			return err
+		}
+		shapes++
+	}
+	switch {
+	case opt.ByRadius < 0:
+		return errors.New("radius must be positive")
+	case shapes == 0:
+		return errors.New("one of radius, box or polygon is required")
+	case shapes > 1:
+		return errors.New("only one of radius, box or polygon can be specified")
+	case opt.Count < 0:
+		return errors.New("count must be positive")
+	case opt.Any && opt.Count == 0:
+		return errors.New("any requires count")
+	}
+	return nil
+}
+
+// GeoSearch 查找范围内的点，返回点和到中心的距离
+//
+//	先用中心所在geohash格子及其周围8个格子筛选，再按实际距离或多边形精确过滤
+func (g *GeoCache) GeoSearch(opt *SearchOpt) ([]*GeoResult, error) {
+	if err := opt.check(); err != nil {
+		return nil, err
+	}
+	g.locker.RLock()
+	defer g.locker.RUnlock()
+	lng, lat := opt.FromLng, opt.FromLat
+	if opt.FromMember != "" {
+		elem, ok := g.sortedset.Get(opt.FromMember)
+		if !ok {
+			return nil, errors.New("point not found")
+		}
+		lng, lat = Decode(elem.Score)
+	} else if len(opt.ByPolygon) > 0 && lng == 0 && lat == 0 {
+		c := coord.Centroid(opt.ByPolygon, coord.Geographic)
+		lng, lat = c.Lng, c.Lat
+	}
+
+	var areas [][2]uint64
+	var match func(x, y float64) (float64, bool)
+	switch {
+	case opt.ByRadius > 0:
+		areas = GetNeighbours(lng, lat, opt.ByRadius)
+		match = func(x, y float64) (float64, bool) {
+			d := Distance(lng, lat, x, y)
+			return d, d <= opt.ByRadius
+		}
+	case opt.ByWidth > 0:
+		dLat := radDeg(opt.ByHeight / 2 / earthRadius)
+		areas = areasByBox(lng, lat, lngSpan(lat, dLat, opt.ByWidth/2), dLat)
+		match = func(x, y float64) (float64, bool) {
+			if Distance(lng, y, lng, lat) > opt.ByHeight/2 || Distance(x, y, lng, y) > opt.ByWidth/2 {
+				return 0, false
+			}
+			return Distance(lng, lat, x, y), true
+		}
+	default:
+		b, _ := coord.Bounds(opt.ByPolygon)
+		areas = areasByBox((b.Min.Lng+b.Max.Lng)/2, (b.Min.Lat+b.Max.Lat)/2,
+			(b.Max.Lng-b.Min.Lng)/2, (b.Max.Lat-b.Min.Lat)/2)
+		match = func(x, y float64) (float64, bool) {
+			if !coord.PointInPolygon(&coord.Point{Lng: x, Lat: y}, opt.ByPolygon) {
+				return 0, false
+			}
+			return Distance(lng, lat, x, y), true
+		}
+	}
+
+	gr := make([]*GeoResult, 0)
+	full := func() bool {
+		return opt.Any && len(gr) >= opt.Count
+	}
+	for _, area := range areas {
+		if full() {
+			break
+		}
+		lower := &sortedset.ScoreBorder[uint64]{Value: area[0]}
+		upper := &sortedset.ScoreBorder[uint64]{Value: area[1]}
+		g.sortedset.ForEachByScore(lower, upper, 0, -1, false, func(elem *sortedset.Element[uint64]) bool {
+			gp := getPoint(elem.Member, elem.Score)
+			if d, ok := match(gp.Lng, gp.Lat); ok {
+				gr = append(gr, &GeoResult{GeoPoint: gp, Dist: d})
+			}
+			return !full()
+		})
+	}
+
+	order := opt.Sort
+	if order == SortNone && opt.Count > 0 && !opt.Any {
+		order = SortAsc
+	}
+	switch order {
+	case SortAsc:
+		sort.SliceStable(gr, func(i, j int) bool { return gr[i].Dist < gr[j].Dist })
+	case SortDesc:
+		sort.SliceStable(gr, func(i, j int) bool { return gr[i].Dist > gr[j].Dist })
+	}
+	if opt.Count > 0 && len(gr) > opt.Count {
+		gr = gr[:opt.Count]
+	}
+	return gr, nil
+}
+
+// searchPoints 返回GeoSearch结果中的点，出错时返回nil
+func (g *GeoCache) searchPoints(opt *SearchOpt) []*GeoPoint {
+	gr, err := g.GeoSearch(opt)
+	if err != nil {
+		return nil
+	}
+	gp := make([]*GeoPoint, len(gr))
+	for k, r := range gr {
+		gp[k] = r.GeoPoint
+	}
+	return gp
+}
@@ -0,0 +1,151 @@
+package geohash
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/xyzj/gopsu/coord"
+)
+
+// randomCache 在中心附近随机生成n个点
+func randomCache(lng, lat, span float64, n int) *GeoCache {
+	r := rand.New(rand.NewSource(1))
+	g := NewGeoCache("")
+	for i := 0; i < n; i++ {
+		g.GeoAdd(&GeoPoint{
+			Name: fmt.Sprintf("p%d", i),
+			Lng:  ensureValidLng(lng + (r.Float64()*2-1)*span),
+			Lat:  lat + (r.Float64()*2-1)*span,
+		})
+	}
+	return g
+}
+
+// bruteForce 遍历所有点
+func bruteForce(g *GeoCache, match func(gp *GeoPoint) bool) map[string]bool {
+	x := make(map[string]bool)
+	for _, e := range g.sortedset.Range(0, g.Len(), false) {
+		gp := getPoint(e.Member, e.Score)
+		if match(gp) {
+			x[gp.Name] = true
+		}
+	}
+	return x
+}
+
+func sameNames(t *testing.T, gr []*GeoResult, want map[string]bool) {
+	t.Helper()
+	if len(gr) != len(want) {
+		t.Fatalf("got %d results, want %d", len(gr), len(want))
+	}
+	for _, r := range gr {
+		if !want[r.Name] {
+			t.Fatalf("unexpected result %s", r.Name)
+		}
+	}
+}
+
+func TestGeoSearch(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		lng, lat float64
+	}{
+		{"beijing", 116.39, 39.91},
+		{"antimeridian", 179.99, -16.5},
+		{"polar", 20, 89.5},
+	} {
+		g := randomCache(c.lng, c.lat, 0.5, 3000)
+		t.Run(c.name+" radius", func(t *testing.T) {
+			for _, radius := range []float64{500, 5000, 20000} {
+				gr, err := g.GeoSearch(&SearchOpt{FromLng: c.lng, FromLat: c.lat, ByRadius: radius})
+				if err != nil {
+					t.Fatal(err)
+				}
+				sameNames(t, gr, bruteForce(g, func(gp *GeoPoint) bool {
+					return Distance(c.lng, c.lat, gp.Lng, gp.Lat) <= radius
+				}))
+				if len(g.GeoRadius(c.lng, c.lat, radius)) != len(gr) {
+					t.Fatal("GeoRadius should match GeoSearch")
+				}
+			}
+		})
+		t.Run(c.name+" box", func(t *testing.T) {
+			w, h := 30000.0, 8000.0
+			gr, err := g.GeoSearch(&SearchOpt{FromLng: c.lng, FromLat: c.lat, ByWidth: w, ByHeight: h})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameNames(t, gr, bruteForce(g, func(gp *GeoPoint) bool {
+				return Distance(c.lng, gp.Lat, c.lng, c.lat) <= h/2 && Distance(gp.Lng, gp.Lat, c.lng, gp.Lat) <= w/2
+			}))
+		})
+	}
+
+	g := randomCache(116.39, 39.91, 0.5, 3000)
+	t.Run("polygon", func(t *testing.T) {
+		pg := coord.Polygon{{
+			{Lng: 116.2, Lat: 39.8}, {Lng: 116.6, Lat: 39.8}, {Lng: 116.4, Lat: 40.1}, {Lng: 116.2, Lat: 39.8},
+		}}
+		gr, err := g.GeoSearch(&SearchOpt{ByPolygon: pg, Sort: SortAsc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameNames(t, gr, bruteForce(g, func(gp *GeoPoint) bool {
+			return coord.PointInPolygon(&coord.Point{Lng: gp.Lng, Lat: gp.Lat}, pg)
+		}))
+		c := coord.Centroid(pg, coord.Geographic)
+		for i, r := range gr {
+			if d := Distance(c.Lng, c.Lat, r.Lng, r.Lat); d != r.Dist {
+				t.Fatalf("dist %f, want %f", r.Dist, d)
+			}
+			if i > 0 && gr[i-1].Dist > r.Dist {
+				t.Fatal("not sorted")
+			}
+		}
+	})
+	t.Run("count", func(t *testing.T) {
+		all, _ := g.GeoSearch(&SearchOpt{FromMember: "p0", ByRadius: 10000, Sort: SortDesc})
+		if len(all) < 10 {
+			t.Fatalf("too few results %d", len(all))
+		}
+		for i := 1; i < len(all); i++ {
+			if all[i-1].Dist < all[i].Dist {
+				t.Fatal("not sorted desc")
+			}
+		}
+		// COUNT without ANY returns the nearest
+		gr, _ := g.GeoSearch(&SearchOpt{FromMember: "p0", ByRadius: 10000, Count: 5})
+		if len(gr) != 5 || gr[0].Name != "p0" || gr[0].Dist != 0 || gr[4].Dist != all[len(all)-5].Dist {
+			t.Fatalf("bad nearest results %+v", gr)
+		}
+		gr, _ = g.GeoSearch(&SearchOpt{FromMember: "p0", ByRadius: 10000, Count: 5, Any: true})
+		if len(gr) != 5 {
+			t.Fatalf("any got %d results", len(gr))
+		}
+		for _, r := range gr {
+			if r.Dist > 10000 {
+				t.Fatal("any result out of radius")
+			}
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, opt := range []*SearchOpt{
+			{},
+			{ByRadius: 1, ByWidth: 1, ByHeight: 1},
+			{ByWidth: 1},
+			{ByRadius: -1},
+			{ByRadius: 1, Any: true},
+			{ByRadius: 1, Count: -1},
+			{FromMember: "none", ByRadius: 1},
+			{ByPolygon: coord.Polygon{{{Lng: 1, Lat: 1}, {Lng: 2, Lat: 2}}}},
+		} {
+			if _, err := g.GeoSearch(opt); err == nil {
+				t.Fatalf("%+v should fail", opt)
+			}
+		}
+		if g.GeoRadiusByMember("none", 100) != nil {
+			t.Fatal("unknown member should return nil")
+		}
+	})
+}
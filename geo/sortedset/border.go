@@ -5,6 +5,11 @@ import (
 	"strconv"
 )
 
+// Score 分数类型
+type Score interface {
+	int | int32 | int64 | uint32 | uint64 | float32 | float64
+}
+
 /*
  * ScoreBorder is a struct represents `min` `max` parameter of redis command `ZRANGEBYSCORE`
  * can accept:
@@ -19,15 +24,15 @@ const (
 )
 
 // ScoreBorder represents range of a float value, including: <, <=, >, >=, +inf, -inf
-type ScoreBorder struct {
+type ScoreBorder[S Score] struct {
 	Inf     int8
-	Value   uint64
+	Value   S
 	Exclude bool
 }
 
 // if max.greater(score) then the score is within the upper border
 // do not use min.greater()
-func (border *ScoreBorder) greater(value uint64) bool {
+func (border *ScoreBorder[S]) greater(value S) bool {
 	if border.Inf == negativeInf {
 		return false
 	} else if border.Inf == positiveInf {
@@ -39,7 +44,7 @@ func (border *ScoreBorder) greater(value uint64) bool {
 	return border.Value >= value
 }
 
-func (border *ScoreBorder) less(value uint64) bool {
+func (border *ScoreBorder[S]) less(value S) bool {
 	if border.Inf == negativeInf {
 		return true
 	} else if border.Inf == positiveInf {
@@ -51,40 +56,127 @@ func (border *ScoreBorder) less(value uint64) bool {
 	return border.Value <= value
 }
 
-var positiveInfBorder = &ScoreBorder{
-	Inf: positiveInf,
+// PositiveInf +inf
+func PositiveInf[S Score]() *ScoreBorder[S] {
+	return &ScoreBorder[S]{Inf: positiveInf}
 }
 
-var negativeInfBorder = &ScoreBorder{
-	Inf: negativeInf,
+// NegativeInf -inf
+func NegativeInf[S Score]() *ScoreBorder[S] {
+	return &ScoreBorder[S]{Inf: negativeInf}
 }
 
 // ParseScoreBorder creates ScoreBorder from redis arguments
-func ParseScoreBorder(s string) (*ScoreBorder, error) {
+func ParseScoreBorder[S Score](s string) (*ScoreBorder[S], error) {
 	if s == "inf" || s == "+inf" {
-		return positiveInfBorder, nil
+		return PositiveInf[S](), nil
 	}
 	if s == "-inf" {
-		return negativeInfBorder, nil
-	}
-	if s[0] == '(' {
-		value, err := strconv.ParseUint(s[1:], 10, 64)
-		if err != nil {
-			return nil, errors.New("ERR min or max is not a float")
-		}
-		return &ScoreBorder{
-			Inf:     0,
-			Value:   value,
-			Exclude: true,
-		}, nil
-	}
-	value, err := strconv.ParseUint(s, 10, 64)
+		return NegativeInf[S](), nil
+	}
+	exclude := false
+	if len(s) > 0 && s[0] == '(' {
+		exclude = true
+		s = s[1:]
+	}
+	value, err := parseScore[S](s)
 	if err != nil {
 		return nil, errors.New("ERR min or max is not a float")
 	}
-	return &ScoreBorder{
+	return &ScoreBorder[S]{
 		Inf:     0,
 		Value:   value,
-		Exclude: false,
+		Exclude: exclude,
 	}, nil
 }
+
+// parseScore 按分数类型解析字符串，浮点数不接受NaN
+func parseScore[S Score](s string) (S, error) {
+	var x any
+	var err error
+	switch any(S(0)).(type) {
+	case int:
+		x, err = strconv.Atoi(s)
+	case int32:
+		var v int64
+		v, err = strconv.ParseInt(s, 10, 32)
+		x = int32(v)
+	case int64:
+		x, err = strconv.ParseInt(s, 10, 64)
+	case uint32:
+		var v uint64
+		v, err = strconv.ParseUint(s, 10, 32)
+		x = uint32(v)
+	case uint64:
+		x, err = strconv.ParseUint(s, 10, 64)
+	case float32:
+		var v float64
+		v, err = strconv.ParseFloat(s, 32)
+		x = float32(v)
+	case float64:
+		x, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return 0, err
+	}
+	v := x.(S)
+	if v != v {
+		return 0, errors.New("score is NaN")
+	}
+	return v, nil
+}
+
+/*
+ * LexBorder represents `min` `max` parameter of redis command `ZRANGEBYLEX`
+ * can accept:
+ *   inclusive value: [a
+ *   exclusive value: (a
+ *   infinity: -, +
+ */
+
+// LexBorder represents range of a member, including: <, <=, >, >=, +, -
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+// if max.greater(member) then the member is within the upper border
+func (border *LexBorder) greater(member string) bool {
+	if border.Inf == negativeInf {
+		return false
+	} else if border.Inf == positiveInf {
+		return true
+	}
+	if border.Exclude {
+		return border.Value > member
+	}
+	return border.Value >= member
+}
+
+func (border *LexBorder) less(member string) bool {
+	if border.Inf == negativeInf {
+		return true
+	} else if border.Inf == positiveInf {
+		return false
+	}
+	if border.Exclude {
+		return border.Value < member
+	}
+	return border.Value <= member
+}
+
+// ParseLexBorder creates LexBorder from redis arguments
+func ParseLexBorder(s string) (*LexBorder, error) {
+	switch {
+	case s == "+":
+		return &LexBorder{Inf: positiveInf}, nil
+	case s == "-":
+		return &LexBorder{Inf: negativeInf}, nil
+	case len(s) > 0 && s[0] == '(':
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	case len(s) > 0 && s[0] == '[':
+		return &LexBorder{Value: s[1:]}, nil
+	}
+	return nil, errors.New("ERR min or max not valid string range item")
+}
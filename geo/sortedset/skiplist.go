@@ -7,48 +7,48 @@ const (
 )
 
 // Element is a key-score pair
-type Element struct {
+type Element[S Score] struct {
 	Member string
-	Score  uint64
+	Score  S
 }
 
 // Level aspect of a node
-type Level struct {
-	forward *node // forward node has greater score
+type Level[S Score] struct {
+	forward *node[S] // forward node has greater score
 	span    int64
 }
 
-type node struct {
-	Element
-	backward *node
-	level    []*Level // level[0] is base level
+type node[S Score] struct {
+	Element[S]
+	backward *node[S]
+	level    []*Level[S] // level[0] is base level
 }
 
-type skiplist struct {
-	header *node
-	tail   *node
+type skiplist[S Score] struct {
+	header *node[S]
+	tail   *node[S]
 	length int64
 	level  int16
 }
 
-func makeNode(level int16, score uint64, member string) *node {
-	n := &node{
-		Element: Element{
+func makeNode[S Score](level int16, score S, member string) *node[S] {
+	n := &node[S]{
+		Element: Element[S]{
 			Score:  score,
 			Member: member,
 		},
-		level: make([]*Level, level),
+		level: make([]*Level[S], level),
 	}
 	for i := range n.level {
-		n.level[i] = new(Level)
+		n.level[i] = new(Level[S])
 	}
 	return n
 }
 
-func makeSkiplist() *skiplist {
-	return &skiplist{
+func makeSkiplist[S Score]() *skiplist[S] {
+	return &skiplist[S]{
 		level:  1,
-		header: makeNode(maxLevel, 0, ""),
+		header: makeNode[S](maxLevel, 0, ""),
 	}
 }
 
@@ -63,8 +63,8 @@ func randomLevel() int16 {
 	return maxLevel
 }
 
-func (skiplist *skiplist) insert(member string, score uint64) *node {
-	update := make([]*node, maxLevel) // link new node with node in `update`
+func (skiplist *skiplist[S]) insert(member string, score S) *node[S] {
+	update := make([]*node[S], maxLevel) // link new node with node in `update`
 	rank := make([]int64, maxLevel)
 
 	// find position to insert
@@ -133,7 +133,7 @@ func (skiplist *skiplist) insert(member string, score uint64) *node {
  * param node: node to delete
  * param update: backward node (of target)
  */
-func (skiplist *skiplist) removeNode(node *node, update []*node) {
+func (skiplist *skiplist[S]) removeNode(node *node[S], update []*node[S]) {
 	for i := int16(0); i < skiplist.level; i++ {
 		if update[i].level[i].forward == node {
 			update[i].level[i].span += node.level[i].span - 1
@@ -148,20 +148,26 @@ func (skiplist *skiplist) removeNode(node *node, update []*node) {
 		skiplist.tail = node.backward
 	}
 	for skiplist.level > 1 && skiplist.header.level[skiplist.level-1].forward == nil {
+		skiplist.header.level[skiplist.level-1].span = 0
 		skiplist.level--
 	}
 	skiplist.length--
+	// 断开被删除节点的指针，外部持有该节点时不会连带保留其他已删除的节点
+	node.backward = nil
+	for i := range node.level {
+		node.level[i].forward = nil
+	}
 }
 
 /*
  * return: has found and removed node
  */
-func (skiplist *skiplist) remove(member string, score uint64) bool {
+func (skiplist *skiplist[S]) remove(member string, score S) bool {
 	/*
 	 * find backward node (of target) or last node of each level
 	 * their forward need to be updated
 	 */
-	update := make([]*node, maxLevel)
+	update := make([]*node[S], maxLevel)
 	node := skiplist.header
 	for i := skiplist.level - 1; i >= 0; i-- {
 		for node.level[i].forward != nil &&
@@ -175,7 +181,6 @@ func (skiplist *skiplist) remove(member string, score uint64) bool {
 	node = node.level[0].forward
 	if node != nil && score == node.Score && node.Member == member {
 		skiplist.removeNode(node, update)
-		// free x
 		return true
 	}
 	return false
@@ -184,7 +189,7 @@ func (skiplist *skiplist) remove(member string, score uint64) bool {
 /*
  * return: 1 based rank, 0 means member not found
  */
-func (skiplist *skiplist) getRank(member string, score uint64) int64 {
+func (skiplist *skiplist[S]) getRank(member string, score S) int64 {
 	var rank int64 = 0
 	x := skiplist.header
 	for i := skiplist.level - 1; i >= 0; i-- {
@@ -207,7 +212,7 @@ func (skiplist *skiplist) getRank(member string, score uint64) int64 {
 /*
  * 1-based rank
  */
-func (skiplist *skiplist) getByRank(rank int64) *node {
+func (skiplist *skiplist[S]) getByRank(rank int64) *node[S] {
 	var i int64 = 0
 	n := skiplist.header
 	// scan from top level
@@ -223,7 +228,7 @@ func (skiplist *skiplist) getByRank(rank int64) *node {
 	return nil
 }
 
-func (skiplist *skiplist) hasInRange(min *ScoreBorder, max *ScoreBorder) bool {
+func (skiplist *skiplist[S]) hasInRange(min *ScoreBorder[S], max *ScoreBorder[S]) bool {
 	// min & max = empty
 	if min.Value > max.Value || (min.Value == max.Value && (min.Exclude || max.Exclude)) {
 		return false
@@ -241,7 +246,7 @@ func (skiplist *skiplist) hasInRange(min *ScoreBorder, max *ScoreBorder) bool {
 	return true
 }
 
-func (skiplist *skiplist) getFirstInScoreRange(min *ScoreBorder, max *ScoreBorder) *node {
+func (skiplist *skiplist[S]) getFirstInScoreRange(min *ScoreBorder[S], max *ScoreBorder[S]) *node[S] {
 	if !skiplist.hasInRange(min, max) {
 		return nil
 	}
@@ -261,7 +266,7 @@ func (skiplist *skiplist) getFirstInScoreRange(min *ScoreBorder, max *ScoreBorde
 	return n
 }
 
-func (skiplist *skiplist) getLastInScoreRange(min *ScoreBorder, max *ScoreBorder) *node {
+func (skiplist *skiplist[S]) getLastInScoreRange(min *ScoreBorder[S], max *ScoreBorder[S]) *node[S] {
 	if !skiplist.hasInRange(min, max) {
 		return nil
 	}
@@ -278,12 +283,69 @@ func (skiplist *skiplist) getLastInScoreRange(min *ScoreBorder, max *ScoreBorder
 	return n
 }
 
+func (skiplist *skiplist[S]) hasInLexRange(min *LexBorder, max *LexBorder) bool {
+	// min & max = empty
+	if min.Inf == positiveInf || max.Inf == negativeInf {
+		return false
+	}
+	if min.Inf == 0 && max.Inf == 0 &&
+		(min.Value > max.Value || (min.Value == max.Value && (min.Exclude || max.Exclude))) {
+		return false
+	}
+	// min > tail
+	n := skiplist.tail
+	if n == nil || !min.less(n.Member) {
+		return false
+	}
+	// max < head
+	n = skiplist.header.level[0].forward
+	if n == nil || !max.greater(n.Member) {
+		return false
+	}
+	return true
+}
+
+func (skiplist *skiplist[S]) getFirstInLexRange(min *LexBorder, max *LexBorder) *node[S] {
+	if !skiplist.hasInLexRange(min, max) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for level := skiplist.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && !min.less(n.level[level].forward.Member) {
+			n = n.level[level].forward
+		}
+	}
+	n = n.level[0].forward
+	if !max.greater(n.Member) {
+		return nil
+	}
+	return n
+}
+
+func (skiplist *skiplist[S]) getLastInLexRange(min *LexBorder, max *LexBorder) *node[S] {
+	if !skiplist.hasInLexRange(min, max) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for level := skiplist.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && max.greater(n.level[level].forward.Member) {
+			n = n.level[level].forward
+		}
+	}
+	if !min.less(n.Member) {
+		return nil
+	}
+	return n
+}
+
 /*
  * return removed elements
  */
-func (skiplist *skiplist) RemoveRangeByScore(min *ScoreBorder, max *ScoreBorder) (removed []*Element) {
-	update := make([]*node, maxLevel)
-	removed = make([]*Element, 0)
+func (skiplist *skiplist[S]) RemoveRangeByScore(min *ScoreBorder[S], max *ScoreBorder[S]) (removed []*Element[S]) {
+	update := make([]*node[S], maxLevel)
+	removed = make([]*Element[S], 0)
 	// find backward nodes (of target range) or last node of each level
 	node := skiplist.header
 	for i := skiplist.level - 1; i >= 0; i-- {
@@ -314,10 +376,10 @@ func (skiplist *skiplist) RemoveRangeByScore(min *ScoreBorder, max *ScoreBorder)
 }
 
 // 1-based rank, including start, exclude stop
-func (skiplist *skiplist) RemoveRangeByRank(start int64, stop int64) (removed []*Element) {
+func (skiplist *skiplist[S]) RemoveRangeByRank(start int64, stop int64) (removed []*Element[S]) {
 	var i int64 = 0 // rank of iterator
-	update := make([]*node, maxLevel)
-	removed = make([]*Element, 0)
+	update := make([]*node[S], maxLevel)
+	removed = make([]*Element[S], 0)
 
 	// scan from top level
 	node := skiplist.header
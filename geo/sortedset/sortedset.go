@@ -1,27 +1,41 @@
 package sortedset
 
 import (
+	"errors"
 	"strconv"
 )
 
+const shrinkMin = 1024
+
 // SortedSet is a set which keys sorted by bound score
-type SortedSet struct {
-	dict     map[string]*Element
-	skiplist *skiplist
+type SortedSet[S Score] struct {
+	dict     map[string]*Element[S]
+	skiplist *skiplist[S]
+	// peak dict的最大长度，go的map删除后不会缩小，长度远小于peak时重建
+	peak int
 }
 
-// Make makes a new SortedSet
-func Make() *SortedSet {
-	return &SortedSet{
-		dict:     make(map[string]*Element),
-		skiplist: makeSkiplist(),
+// New makes a new SortedSet with the given score type
+func New[S Score]() *SortedSet[S] {
+	return &SortedSet[S]{
+		dict:     make(map[string]*Element[S]),
+		skiplist: makeSkiplist[S](),
 	}
 }
 
+// Make makes a new SortedSet with uint64 score, used by geohash
+func Make() *SortedSet[uint64] {
+	return New[uint64]()
+}
+
 // Add puts member into set,  and returns whether has inserted new node
-func (sortedSet *SortedSet) Add(member string, score uint64) bool {
+// NaN score is ignored
+func (sortedSet *SortedSet[S]) Add(member string, score S) bool {
+	if score != score {
+		return false
+	}
 	element, ok := sortedSet.dict[member]
-	sortedSet.dict[member] = &Element{
+	sortedSet.dict[member] = &Element[S]{
 		Member: member,
 		Score:  score,
 	}
@@ -33,16 +47,32 @@ func (sortedSet *SortedSet) Add(member string, score uint64) bool {
 		return false
 	}
 	sortedSet.skiplist.insert(member, score)
+	if len(sortedSet.dict) > sortedSet.peak {
+		sortedSet.peak = len(sortedSet.dict)
+	}
 	return true
 }
 
+// shrink 删除大量成员后重建dict，释放map占用的内存
+func (sortedSet *SortedSet[S]) shrink() {
+	if sortedSet.peak < shrinkMin || len(sortedSet.dict) > sortedSet.peak/4 {
+		return
+	}
+	dict := make(map[string]*Element[S], len(sortedSet.dict))
+	for k, v := range sortedSet.dict {
+		dict[k] = v
+	}
+	sortedSet.dict = dict
+	sortedSet.peak = len(dict)
+}
+
 // Len returns number of members in set
-func (sortedSet *SortedSet) Len() int64 {
+func (sortedSet *SortedSet[S]) Len() int64 {
 	return int64(len(sortedSet.dict))
 }
 
 // Get returns the given member
-func (sortedSet *SortedSet) Get(member string) (element *Element, ok bool) {
+func (sortedSet *SortedSet[S]) Get(member string) (element *Element[S], ok bool) {
 	element, ok = sortedSet.dict[member]
 	if !ok {
 		return nil, false
@@ -51,18 +81,19 @@ func (sortedSet *SortedSet) Get(member string) (element *Element, ok bool) {
 }
 
 // Remove removes the given member from set
-func (sortedSet *SortedSet) Remove(member string) bool {
+func (sortedSet *SortedSet[S]) Remove(member string) bool {
 	v, ok := sortedSet.dict[member]
 	if ok {
 		sortedSet.skiplist.remove(member, v.Score)
 		delete(sortedSet.dict, member)
+		sortedSet.shrink()
 		return true
 	}
 	return false
 }
 
 // GetRank returns the rank of the given member, sort by ascending order, rank starts from 0
-func (sortedSet *SortedSet) GetRank(member string, desc bool) (rank int64) {
+func (sortedSet *SortedSet[S]) GetRank(member string, desc bool) (rank int64) {
 	element, ok := sortedSet.dict[member]
 	if !ok {
 		return -1
@@ -77,7 +108,7 @@ func (sortedSet *SortedSet) GetRank(member string, desc bool) (rank int64) {
 }
 
 // ForEach visits each member which rank within [start, stop), sort by ascending order, rank starts from 0
-func (sortedSet *SortedSet) ForEach(start int64, stop int64, desc bool, consumer func(element *Element) bool) {
+func (sortedSet *SortedSet[S]) ForEach(start int64, stop int64, desc bool, consumer func(element *Element[S]) bool) {
 	size := int64(sortedSet.Len())
 	if start < 0 || start >= size {
 		panic("illegal start " + strconv.FormatInt(start, 10))
@@ -87,7 +118,7 @@ func (sortedSet *SortedSet) ForEach(start int64, stop int64, desc bool, consumer
 	}
 
 	// find start node
-	var node *node
+	var node *node[S]
 	if desc {
 		node = sortedSet.skiplist.tail
 		if start > 0 {
@@ -102,7 +133,9 @@ func (sortedSet *SortedSet) ForEach(start int64, stop int64, desc bool, consumer
 
 	sliceSize := int(stop - start)
 	for i := 0; i < sliceSize; i++ {
-		if !consumer(&node.Element) {
+		// 传递副本，避免调用方持有节点
+		e := node.Element
+		if !consumer(&e) {
 			break
 		}
 		if desc {
@@ -114,11 +147,11 @@ func (sortedSet *SortedSet) ForEach(start int64, stop int64, desc bool, consumer
 }
 
 // Range returns members which rank within [start, stop), sort by ascending order, rank starts from 0
-func (sortedSet *SortedSet) Range(start int64, stop int64, desc bool) []*Element {
+func (sortedSet *SortedSet[S]) Range(start int64, stop int64, desc bool) []*Element[S] {
 	sliceSize := int(stop - start)
-	slice := make([]*Element, sliceSize)
+	slice := make([]*Element[S], sliceSize)
 	i := 0
-	sortedSet.ForEach(start, stop, desc, func(element *Element) bool {
+	sortedSet.ForEach(start, stop, desc, func(element *Element[S]) bool {
 		slice[i] = element
 		i++
 		return true
@@ -127,10 +160,10 @@ func (sortedSet *SortedSet) Range(start int64, stop int64, desc bool) []*Element
 }
 
 // Count returns the number of  members which score within the given border
-func (sortedSet *SortedSet) Count(min *ScoreBorder, max *ScoreBorder) int64 {
+func (sortedSet *SortedSet[S]) Count(min *ScoreBorder[S], max *ScoreBorder[S]) int64 {
 	var i int64 = 0
 	// ascending order
-	sortedSet.ForEach(0, sortedSet.Len(), false, func(element *Element) bool {
+	sortedSet.ForEach(0, sortedSet.Len(), false, func(element *Element[S]) bool {
 		gtMin := min.less(element.Score) // greater than min
 		if !gtMin {
 			// has not into range, continue foreach
@@ -149,9 +182,9 @@ func (sortedSet *SortedSet) Count(min *ScoreBorder, max *ScoreBorder) int64 {
 }
 
 // ForEachByScore visits members which score within the given border
-func (sortedSet *SortedSet) ForEachByScore(min *ScoreBorder, max *ScoreBorder, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+func (sortedSet *SortedSet[S]) ForEachByScore(min *ScoreBorder[S], max *ScoreBorder[S], offset int64, limit int64, desc bool, consumer func(element *Element[S]) bool) {
 	// find start node
-	var node *node
+	var node *node[S]
 	if desc {
 		node = sortedSet.skiplist.getLastInScoreRange(min, max)
 	} else {
@@ -169,7 +202,9 @@ func (sortedSet *SortedSet) ForEachByScore(min *ScoreBorder, max *ScoreBorder, o
 
 	// A negative limit returns all elements from the offset
 	for i := 0; (i < int(limit) || limit < 0) && node != nil; i++ {
-		if !consumer(&node.Element) {
+		// 传递副本，避免调用方持有节点
+		e := node.Element
+		if !consumer(&e) {
 			break
 		}
 		if desc {
@@ -190,12 +225,12 @@ func (sortedSet *SortedSet) ForEachByScore(min *ScoreBorder, max *ScoreBorder, o
 
 // RangeByScore returns members which score within the given border
 // param limit: <0 means no limit
-func (sortedSet *SortedSet) RangeByScore(min *ScoreBorder, max *ScoreBorder, offset int64, limit int64, desc bool) []*Element {
+func (sortedSet *SortedSet[S]) RangeByScore(min *ScoreBorder[S], max *ScoreBorder[S], offset int64, limit int64, desc bool) []*Element[S] {
 	if limit == 0 || offset < 0 {
-		return make([]*Element, 0)
+		return make([]*Element[S], 0)
 	}
-	slice := make([]*Element, 0)
-	sortedSet.ForEachByScore(min, max, offset, limit, desc, func(element *Element) bool {
+	slice := make([]*Element[S], 0)
+	sortedSet.ForEachByScore(min, max, offset, limit, desc, func(element *Element[S]) bool {
 		slice = append(slice, element)
 		return true
 	})
@@ -203,20 +238,246 @@ func (sortedSet *SortedSet) RangeByScore(min *ScoreBorder, max *ScoreBorder, off
 }
 
 // RemoveByScore removes members which score within the given border
-func (sortedSet *SortedSet) RemoveByScore(min *ScoreBorder, max *ScoreBorder) int64 {
+func (sortedSet *SortedSet[S]) RemoveByScore(min *ScoreBorder[S], max *ScoreBorder[S]) int64 {
 	removed := sortedSet.skiplist.RemoveRangeByScore(min, max)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
 	}
+	sortedSet.shrink()
 	return int64(len(removed))
 }
 
 // RemoveByRank removes member ranking within [start, stop)
 // sort by ascending order and rank starts from 0
-func (sortedSet *SortedSet) RemoveByRank(start int64, stop int64) int64 {
+func (sortedSet *SortedSet[S]) RemoveByRank(start int64, stop int64) int64 {
 	removed := sortedSet.skiplist.RemoveRangeByRank(start+1, stop+1)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
 	}
+	sortedSet.shrink()
 	return int64(len(removed))
 }
+
+// ForEachByLex visits members which within the given lex border, all members should have the same score
+func (sortedSet *SortedSet[S]) ForEachByLex(min *LexBorder, max *LexBorder, offset int64, limit int64, desc bool, consumer func(element *Element[S]) bool) {
+	var node *node[S]
+	if desc {
+		node = sortedSet.skiplist.getLastInLexRange(min, max)
+	} else {
+		node = sortedSet.skiplist.getFirstInLexRange(min, max)
+	}
+
+	for node != nil && offset > 0 {
+		if desc {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+		offset--
+	}
+
+	// A negative limit returns all elements from the offset
+	for i := 0; (i < int(limit) || limit < 0) && node != nil; i++ {
+		e := node.Element
+		if !consumer(&e) {
+			break
+		}
+		if desc {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+		if node == nil || !min.less(node.Member) || !max.greater(node.Member) {
+			break
+		}
+	}
+}
+
+// RangeByLex returns members which within the given lex border, all members should have the same score
+// param limit: <0 means no limit
+func (sortedSet *SortedSet[S]) RangeByLex(min *LexBorder, max *LexBorder, offset int64, limit int64, desc bool) []*Element[S] {
+	slice := make([]*Element[S], 0)
+	if limit == 0 || offset < 0 {
+		return slice
+	}
+	sortedSet.ForEachByLex(min, max, offset, limit, desc, func(element *Element[S]) bool {
+		slice = append(slice, element)
+		return true
+	})
+	return slice
+}
+
+// CountByLex returns the number of members which within the given lex border
+func (sortedSet *SortedSet[S]) CountByLex(min *LexBorder, max *LexBorder) int64 {
+	var i int64
+	sortedSet.ForEachByLex(min, max, 0, -1, false, func(element *Element[S]) bool {
+		i++
+		return true
+	})
+	return i
+}
+
+// IncrBy increases the score of member by delta and returns the new score, member is added if not exists
+func (sortedSet *SortedSet[S]) IncrBy(member string, delta S) (S, error) {
+	score := delta
+	if element, ok := sortedSet.dict[member]; ok {
+		score += element.Score
+	}
+	if score != score {
+		return 0, errors.New("ERR resulting score is not a number (NaN)")
+	}
+	sortedSet.Add(member, score)
+	return score, nil
+}
+
+// PopMin removes and returns up to count members with the lowest scores, in ascending order
+func (sortedSet *SortedSet[S]) PopMin(count int64) []*Element[S] {
+	if count <= 0 {
+		return make([]*Element[S], 0)
+	}
+	removed := sortedSet.skiplist.RemoveRangeByRank(1, count+1)
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+	}
+	sortedSet.shrink()
+	return removed
+}
+
+// PopMax removes and returns up to count members with the highest scores, in descending order
+func (sortedSet *SortedSet[S]) PopMax(count int64) []*Element[S] {
+	if count <= 0 {
+		return make([]*Element[S], 0)
+	}
+	size := sortedSet.Len()
+	if count > size {
+		count = size
+	}
+	removed := sortedSet.skiplist.RemoveRangeByRank(size-count+1, size+1)
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
+	}
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+	}
+	sortedSet.shrink()
+	return removed
+}
+
+// Aggregate the way to combine scores in Union and Intersect
+type Aggregate byte
+
+const (
+	// AggregateSum sum of scores
+	AggregateSum Aggregate = iota
+	// AggregateMin minimum score
+	AggregateMin
+	// AggregateMax maximum score
+	AggregateMax
+)
+
+// Union returns a new set of all members in sets, like redis `ZUNIONSTORE`
+// score in each set is multiplied by the weight, nil weights means all 1
+func Union[S Score](sets []*SortedSet[S], weights []S, agg Aggregate) (*SortedSet[S], error) {
+	weights, err := checkWeights(sets, weights)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[string]S)
+	for i, set := range sets {
+		for member, element := range set.dict {
+			score := weightedScore(element.Score, weights[i])
+			if old, ok := scores[member]; ok {
+				score = aggregate(old, score, agg)
+			}
+			scores[member] = score
+		}
+	}
+	return fromScores(scores), nil
+}
+
+// Intersect returns a new set of members in all sets, like redis `ZINTERSTORE`
+// score in each set is multiplied by the weight, nil weights means all 1
+func Intersect[S Score](sets []*SortedSet[S], weights []S, agg Aggregate) (*SortedSet[S], error) {
+	weights, err := checkWeights(sets, weights)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[string]S)
+	if len(sets) == 0 {
+		return fromScores(scores), nil
+	}
+	// 从最小的集合开始查找
+	smallest := 0
+	for i, set := range sets {
+		if set.Len() < sets[smallest].Len() {
+			smallest = i
+		}
+	}
+NEXT:
+	for member := range sets[smallest].dict {
+		var score S
+		for i, set := range sets {
+			element, ok := set.dict[member]
+			if !ok {
+				continue NEXT
+			}
+			s := weightedScore(element.Score, weights[i])
+			if i == 0 {
+				score = s
+			} else {
+				score = aggregate(score, s, agg)
+			}
+		}
+		scores[member] = score
+	}
+	return fromScores(scores), nil
+}
+
+func checkWeights[S Score](sets []*SortedSet[S], weights []S) ([]S, error) {
+	if weights == nil {
+		weights = make([]S, len(sets))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(sets) {
+		return nil, errors.New("ERR the number of weights must match the number of sets")
+	}
+	return weights, nil
+}
+
+// weightedScore score*weight，与redis一致，权重为0时结果为0
+func weightedScore[S Score](score, weight S) S {
+	if weight == 0 {
+		return 0
+	}
+	return score * weight
+}
+
+func aggregate[S Score](a, b S, agg Aggregate) S {
+	switch agg {
+	case AggregateMin:
+		if b < a {
+			return b
+		}
+		return a
+	case AggregateMax:
+		if b > a {
+			return b
+		}
+		return a
+	}
+	// +inf + -inf，与redis一致，结果为0
+	if s := a + b; s == s {
+		return s
+	}
+	return 0
+}
+
+func fromScores[S Score](scores map[string]S) *SortedSet[S] {
+	set := New[S]()
+	for member, score := range scores {
+		set.Add(member, score)
+	}
+	return set
+}
@@ -0,0 +1,261 @@
+package sortedset
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestSortedSet(t *testing.T) {
+	ss := Make()
+	for i := 0; i < 100; i++ {
+		ss.Add(fmt.Sprintf("m%03d", i), uint64(100-i))
+	}
+	ss.Add("m000", 1000)
+	if ss.Len() != 100 {
+		t.Fatalf("len %d", ss.Len())
+	}
+	if r := ss.GetRank("m099", false); r != 0 {
+		t.Fatalf("rank %d", r)
+	}
+	if r := ss.GetRank("m000", true); r != 0 {
+		t.Fatalf("desc rank %d", r)
+	}
+	x := ss.RangeByScore(&ScoreBorder[uint64]{Value: 10}, &ScoreBorder[uint64]{Value: 20, Exclude: true}, 0, -1, false)
+	if len(x) != 10 || x[0].Score != 10 || x[9].Score != 19 {
+		t.Fatalf("range by score %v", x)
+	}
+	if n := ss.RemoveByScore(&ScoreBorder[uint64]{Value: 10}, &ScoreBorder[uint64]{Value: 19}); n != 10 {
+		t.Fatalf("removed %d", n)
+	}
+	if n := ss.RemoveByRank(0, 5); n != 5 || ss.Len() != 85 {
+		t.Fatalf("removed %d, len %d", n, ss.Len())
+	}
+	prev := uint64(0)
+	ss.ForEach(0, ss.Len(), false, func(e *Element[uint64]) bool {
+		if e.Score < prev {
+			t.Fatal("not sorted")
+		}
+		prev = e.Score
+		return true
+	})
+}
+
+func TestRemovedNodeUnlinked(t *testing.T) {
+	ss := Make()
+	for i := 0; i < 2000; i++ {
+		ss.Add(fmt.Sprintf("m%d", i), uint64(i))
+	}
+	n := ss.skiplist.getByRank(500)
+	ss.Remove(n.Member)
+	if n.backward != nil {
+		t.Fatal("backward not cleared")
+	}
+	for _, l := range n.level {
+		if l.forward != nil {
+			t.Fatal("forward not cleared")
+		}
+	}
+	// dict在大量删除后重建
+	ss.RemoveByRank(0, ss.Len()-10)
+	if ss.peak != 10 {
+		t.Fatalf("peak %d", ss.peak)
+	}
+}
+
+var heapProfile = flag.String("heapprofile", "", "TestNoLeak失败时写入heap profile的文件，默认在系统临时目录中创建")
+
+// TestNoLeak 反复添加和删除成员，同时持有返回的元素，内存不应随轮数增长
+//
+//	失败时写入heap profile，可用go tool pprof分析，如：go test -run TestNoLeak -heapprofile heap.pprof
+func TestNoLeak(t *testing.T) {
+	const rounds, members = 30, 5000
+	pad := strings.Repeat("x", 200)
+	held := make([]*Element[uint64], 0, rounds)
+	ss := Make()
+	heap := func() uint64 {
+		runtime.GC()
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+	var base uint64
+	for r := 0; r < rounds; r++ {
+		for i := 0; i < members; i++ {
+			ss.Add(fmt.Sprintf("%s%d-%d", pad, r, i), uint64(i))
+		}
+		held = append(held, ss.Range(0, 1, false)[0])
+		held = append(held, ss.RangeByScore(&ScoreBorder[uint64]{Value: 100}, &ScoreBorder[uint64]{Value: 100}, 0, 1, false)[0])
+		ss.RemoveByScore(&ScoreBorder[uint64]{Value: 0}, &ScoreBorder[uint64]{Value: members / 2})
+		ss.ForEach(0, ss.Len(), false, func(e *Element[uint64]) bool {
+			ss.Remove(e.Member)
+			return false
+		})
+		for ss.Len() > 0 {
+			ss.RemoveByRank(0, 100)
+		}
+		if r == 1 {
+			base = heap()
+		}
+	}
+	after := heap()
+	t.Logf("heap after warmup %d, after %d rounds %d", base, rounds, after)
+	if after > base+members*uint64(len(pad)) {
+		var f *os.File
+		var err error
+		if *heapProfile != "" {
+			f, err = os.Create(*heapProfile)
+		} else {
+			f, err = os.CreateTemp("", "sortedset-heap-*.pprof")
+		}
+		if err == nil {
+			pprof.WriteHeapProfile(f)
+			f.Close()
+			t.Logf("heap profile written to %s", f.Name())
+		} else {
+			t.Logf("write heap profile: %v", err)
+		}
+		t.Fatalf("heap grew from %d to %d", base, after)
+	}
+	runtime.KeepAlive(held)
+}
+
+func TestParseScoreBorder(t *testing.T) {
+	f, err := ParseScoreBorder[float64]("(2.5")
+	if err != nil || f.Value != 2.5 || !f.Exclude {
+		t.Fatalf("%+v %v", f, err)
+	}
+	i, err := ParseScoreBorder[int64]("-3")
+	if err != nil || i.Value != -3 || i.Exclude {
+		t.Fatalf("%+v %v", i, err)
+	}
+	if b, _ := ParseScoreBorder[int64]("inf"); b.Inf != positiveInf {
+		t.Fatal("inf")
+	}
+	if b, _ := ParseScoreBorder[float64]("-inf"); b.Inf != negativeInf {
+		t.Fatal("-inf")
+	}
+	for _, s := range []string{"", "(", "1.5x", "nan"} {
+		if _, err := ParseScoreBorder[float64](s); err == nil {
+			t.Fatalf("%q should fail", s)
+		}
+	}
+	if _, err := ParseScoreBorder[uint64]("-1"); err == nil {
+		t.Fatal("negative uint should fail")
+	}
+	if _, err := ParseScoreBorder[int64]("1.5"); err == nil {
+		t.Fatal("float int should fail")
+	}
+}
+
+func TestLex(t *testing.T) {
+	ss := New[float64]()
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		ss.Add(m, 0)
+	}
+	for _, c := range []struct {
+		min, max string
+		want     string
+	}{
+		{"-", "+", "abcdefg"},
+		{"-", "[c", "abc"},
+		{"-", "(c", "ab"},
+		{"[aaa", "(g", "bcdef"},
+		{"(b", "[e", "cde"},
+		{"[z", "+", ""},
+		{"[e", "[b", ""},
+	} {
+		min, _ := ParseLexBorder(c.min)
+		max, _ := ParseLexBorder(c.max)
+		got := ""
+		for _, e := range ss.RangeByLex(min, max, 0, -1, false) {
+			got += e.Member
+		}
+		if got != c.want || ss.CountByLex(min, max) != int64(len(c.want)) {
+			t.Fatalf("%s %s: got %q, want %q", c.min, c.max, got, c.want)
+		}
+	}
+	min, _ := ParseLexBorder("[b")
+	max, _ := ParseLexBorder("+")
+	got := ""
+	for _, e := range ss.RangeByLex(min, max, 1, 3, true) {
+		got += e.Member
+	}
+	if got != "fed" {
+		t.Fatalf("desc with offset got %q", got)
+	}
+	if _, err := ParseLexBorder("b"); err == nil {
+		t.Fatal("lex border without [ or ( should fail")
+	}
+}
+
+func TestIncrByAndPop(t *testing.T) {
+	ss := New[int64]()
+	for i := int64(0); i < 10; i++ {
+		ss.Add(fmt.Sprintf("m%d", i), i*10)
+	}
+	if s, _ := ss.IncrBy("m0", 95); s != 95 {
+		t.Fatalf("incr got %d", s)
+	}
+	if s, _ := ss.IncrBy("new", -5); s != -5 {
+		t.Fatalf("incr new got %d", s)
+	}
+	x := ss.PopMin(2)
+	if len(x) != 2 || x[0].Member != "new" || x[1].Member != "m1" {
+		t.Fatalf("pop min %v", x)
+	}
+	x = ss.PopMax(3)
+	if len(x) != 3 || x[0].Member != "m0" || x[1].Member != "m9" || x[2].Member != "m8" {
+		t.Fatalf("pop max %v", x)
+	}
+	if ss.Len() != 6 {
+		t.Fatalf("len %d", ss.Len())
+	}
+	if x = ss.PopMax(100); len(x) != 6 || ss.Len() != 0 {
+		t.Fatalf("pop all %d", len(x))
+	}
+	fs := New[float64]()
+	fs.Add("a", math.Inf(1))
+	if _, err := fs.IncrBy("a", math.Inf(-1)); err == nil {
+		t.Fatal("NaN result should fail")
+	}
+	if fs.Add("b", math.NaN()) || fs.Len() != 1 {
+		t.Fatal("NaN score should be ignored")
+	}
+}
+
+func TestUnionIntersect(t *testing.T) {
+	a, b := New[float64](), New[float64]()
+	a.Add("x", 1)
+	a.Add("y", 2)
+	b.Add("y", 10)
+	b.Add("z", 20)
+	scores := func(s *SortedSet[float64]) map[string]float64 {
+		m := map[string]float64{}
+		for _, e := range s.Range(0, s.Len(), false) {
+			m[e.Member] = e.Score
+		}
+		return m
+	}
+	u, _ := Union([]*SortedSet[float64]{a, b}, []float64{2, 1}, AggregateSum)
+	if m := scores(u); len(m) != 3 || m["x"] != 2 || m["y"] != 14 || m["z"] != 20 {
+		t.Fatalf("union %v", m)
+	}
+	u, _ = Union([]*SortedSet[float64]{a, b}, nil, AggregateMax)
+	if m := scores(u); m["y"] != 10 {
+		t.Fatalf("union max %v", m)
+	}
+	i, _ := Intersect([]*SortedSet[float64]{a, b}, nil, AggregateMin)
+	if m := scores(i); len(m) != 1 || m["y"] != 2 {
+		t.Fatalf("intersect %v", m)
+	}
+	if _, err := Union([]*SortedSet[float64]{a, b}, []float64{1}, AggregateSum); err == nil {
+		t.Fatal("weights length mismatch should fail")
+	}
+}
@@ -304,7 +304,7 @@ func ReadCachePB2(mydb db.SQLInterface) gin.HandlerFunc {
 // Blacklist IP黑名单
 func Blacklist(excludePath ...string) gin.HandlerFunc {
 	envconfig := config.NewConfig(pathtool.JoinPathFromHere(".env"))
-	bl := strings.Split(envconfig.GetItem("blacklist").String(), ",")
+	bl := envconfig.GetItem("blacklist").TryStringSlice()
 	return func(c *gin.Context) {
 		// 检查是否排除路由
 		for _, v := range excludePath {
@@ -56,6 +56,16 @@ func (m *StructMap[KEY, VALUE]) DeleteMore(keys ...KEY) {
 	m.locker.Unlock()
 }
 
+// Replace 使用新的内容替换全部内容，替换后data由map接管，调用方不应再修改
+func (m *StructMap[KEY, VALUE]) Replace(data map[KEY]*VALUE) {
+	if data == nil {
+		data = make(map[KEY]*VALUE)
+	}
+	m.locker.Lock()
+	m.data = data
+	m.locker.Unlock()
+}
+
 // Clean 清空内容
 func (m *StructMap[KEY, VALUE]) Clean() {
 	m.locker.Lock()
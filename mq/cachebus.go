@@ -0,0 +1,84 @@
+package mq
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheBus 使用mqtt或rabbitmq传输缓存失效消息，实现cache.InvalidationBus接口
+//
+//	使用方法：
+//	bus := mq.NewCacheBus("cache/invalid")
+//	cli, _ := mq.NewMQTTClientV5(&mq.MqttOpt{Subscribe: map[string]byte{"cache/invalid": 1}, ...}, bus.Recv)
+//	bus.BindMQTT(cli)
+//	c := cache.NewSyncCache[*db.QueryData](cache.NewAnyCache[*db.QueryData](time.Minute*30), bus)
+type CacheBus struct {
+	locker sync.RWMutex
+	topic  string
+	send   func(topic string, body []byte) error
+	subs   []func(body []byte)
+}
+
+// NewCacheBus 创建一个缓存失效消息通道
+//
+//	topic: 失效消息使用的mqtt topic或rabbitmq routing key，所有实例应相同并订阅该topic
+func NewCacheBus(topic string) *CacheBus {
+	return &CacheBus{
+		topic: topic,
+		subs:  make([]func(body []byte), 0),
+	}
+}
+
+// BindMQTT 使用mqtt 5.0客户端发送失效消息
+func (b *CacheBus) BindMQTT(client *MqttClientV5) {
+	b.locker.Lock()
+	b.send = func(topic string, body []byte) error {
+		return client.WriteWithQos(topic, body, 1)
+	}
+	b.locker.Unlock()
+}
+
+// BindRMQ 使用rabbitmq生产者发送失效消息
+func (b *CacheBus) BindRMQ(producer *RMQProducer) {
+	b.locker.Lock()
+	b.send = func(topic string, body []byte) error {
+		if !producer.Enable() {
+			return fmt.Errorf("rmq producer is not ready")
+		}
+		producer.Send(topic, body, time.Minute)
+		return nil
+	}
+	b.locker.Unlock()
+}
+
+// Recv 接收失效消息，可直接作为mqtt或rabbitmq的接收回调，也可在已有的接收回调中调用，非指定topic的消息会被忽略
+func (b *CacheBus) Recv(topic string, body []byte) {
+	if topic != b.topic {
+		return
+	}
+	b.locker.RLock()
+	subs := b.subs
+	b.locker.RUnlock()
+	for _, f := range subs {
+		f(body)
+	}
+}
+
+// Publish 广播一条失效消息
+func (b *CacheBus) Publish(body []byte) error {
+	b.locker.RLock()
+	send := b.send
+	b.locker.RUnlock()
+	if send == nil {
+		return fmt.Errorf("cache bus is not bound to any mq client")
+	}
+	return send(b.topic, body)
+}
+
+// Subscribe 设置收到失效消息时的处理方法
+func (b *CacheBus) Subscribe(f func(body []byte)) {
+	b.locker.Lock()
+	b.subs = append(b.subs, f)
+	b.locker.Unlock()
+}